@@ -24,6 +24,7 @@ import (
 
 	extensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	crdclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -39,14 +40,24 @@ var schema = &extensionsv1beta1.JSONSchemaProps{
 		"spec": {
 			Type: "object",
 			Properties: map[string]extensionsv1beta1.JSONSchemaProps{
-				"controller":     {Type: "object"},
-				"driverName":     {Type: "string"},
-				"driverVersion":  {Type: "string"},
-				"node":           {Type: "object"},
-				"secrets":        {Type: "array"},
-				"storageClasses": {Type: "array"},
-				"configMaps":     {Type: "array"},
-				"version":        {Type: "string"},
+				"adoption":              {Type: "object"},
+				"controller":            {Type: "object"},
+				"driverName":            {Type: "string"},
+				"defaultStorageClass":   {Type: "string"},
+				"deletionPolicy":        {Type: "string"},
+				"driverVersion":         {Type: "string"},
+				"metrics":               {Type: "object"},
+				"node":                  {Type: "object"},
+				"secrets":               {Type: "array"},
+				"storageClasses":        {Type: "array"},
+				"storageClassTemplates": {Type: "array"},
+				"topology":              {Type: "object"},
+				"ceph":                  {Type: "object"},
+				"tencentCloud":          {Type: "object"},
+				"nfs":                   {Type: "object"},
+				"lvm":                   {Type: "object"},
+				"configMaps":            {Type: "array"},
+				"version":               {Type: "string"},
 			},
 			Required: []string{"driverName"},
 		},
@@ -87,7 +98,110 @@ var csiCRD = &extensionsv1beta1.CustomResourceDefinition{
 	},
 }
 
-// syncCRD creates and updates the CRD object.
+var storageProfileSchema = &extensionsv1beta1.JSONSchemaProps{
+	Properties: map[string]extensionsv1beta1.JSONSchemaProps{
+		"apiVersion": {Type: "string"},
+		"kind":       {Type: "string"},
+		"metadata":   {Type: "object"},
+		"spec": {
+			Type: "object",
+			Properties: map[string]extensionsv1beta1.JSONSchemaProps{
+				"csi":                   {Type: "object"},
+				"storageClasses":        {Type: "array"},
+				"volumeSnapshotClasses": {Type: "array"},
+			},
+			Required: []string{"csi"},
+		},
+	},
+}
+
+var storageProfileCRD = &extensionsv1beta1.CustomResourceDefinition{
+	ObjectMeta: metav1.ObjectMeta{
+		Name: "storageprofiles." + storage.GroupName,
+	},
+	TypeMeta: metav1.TypeMeta{
+		Kind:       "CustomResourceDefinition",
+		APIVersion: "apiextensions.k8s.io/v1beta1",
+	},
+	Spec: extensionsv1beta1.CustomResourceDefinitionSpec{
+		Group: storage.GroupName,
+		Scope: extensionsv1beta1.ResourceScope("Cluster"),
+		Names: extensionsv1beta1.CustomResourceDefinitionNames{
+			Plural:   "storageprofiles",
+			Singular: "storageprofile",
+			Kind:     "StorageProfile",
+			ListKind: "StorageProfileList",
+		},
+		Version: "v1",
+		Versions: []extensionsv1beta1.CustomResourceDefinitionVersion{
+			{
+				Name:    "v1",
+				Served:  true,
+				Storage: true,
+			},
+		},
+		Validation: &extensionsv1beta1.CustomResourceValidation{
+			OpenAPIV3Schema: storageProfileSchema,
+		},
+		Subresources: &extensionsv1beta1.CustomResourceSubresources{
+			Status: &extensionsv1beta1.CustomResourceSubresourceStatus{},
+		},
+	},
+}
+
+var driverProfileSchema = &extensionsv1beta1.JSONSchemaProps{
+	Properties: map[string]extensionsv1beta1.JSONSchemaProps{
+		"apiVersion": {Type: "string"},
+		"kind":       {Type: "string"},
+		"metadata":   {Type: "object"},
+		"spec": {
+			Type: "object",
+			Properties: map[string]extensionsv1beta1.JSONSchemaProps{
+				"driverName":         {Type: "string"},
+				"versions":           {Type: "array"},
+				"driverTemplate":     {Type: "object"},
+				"parameters":         {Type: "array"},
+				"livenessProbePorts": {Type: "object"},
+				"metricsPorts":       {Type: "object"},
+				"storageClasses":     {Type: "array"},
+			},
+			Required: []string{"driverName", "versions", "driverTemplate"},
+		},
+	},
+}
+
+var driverProfileCRD = &extensionsv1beta1.CustomResourceDefinition{
+	ObjectMeta: metav1.ObjectMeta{
+		Name: "driverprofiles." + storage.GroupName,
+	},
+	TypeMeta: metav1.TypeMeta{
+		Kind:       "CustomResourceDefinition",
+		APIVersion: "apiextensions.k8s.io/v1beta1",
+	},
+	Spec: extensionsv1beta1.CustomResourceDefinitionSpec{
+		Group: storage.GroupName,
+		Scope: extensionsv1beta1.ResourceScope("Cluster"),
+		Names: extensionsv1beta1.CustomResourceDefinitionNames{
+			Plural:   "driverprofiles",
+			Singular: "driverprofile",
+			Kind:     "DriverProfile",
+			ListKind: "DriverProfileList",
+		},
+		Version: "v1",
+		Versions: []extensionsv1beta1.CustomResourceDefinitionVersion{
+			{
+				Name:    "v1",
+				Served:  true,
+				Storage: true,
+			},
+		},
+		Validation: &extensionsv1beta1.CustomResourceValidation{
+			OpenAPIV3Schema: driverProfileSchema,
+		},
+	},
+}
+
+// syncCRD creates and updates the CRD objects.
 func syncCRD(config *rest.Config) error {
 	client, err := apiextensionsclient.NewForConfig(config)
 	if err != nil {
@@ -95,30 +209,41 @@ func syncCRD(config *rest.Config) error {
 	}
 	crdClient := client.ApiextensionsV1beta1().CustomResourceDefinitions()
 
-	oldCRD, err := crdClient.Get(csiCRD.Name, metav1.GetOptions{})
+	for _, crd := range []*extensionsv1beta1.CustomResourceDefinition{csiCRD, storageProfileCRD, driverProfileCRD} {
+		if err := syncOneCRD(crdClient, crd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncOneCRD creates and updates a CRD object.
+func syncOneCRD(crdClient crdclient.CustomResourceDefinitionInterface,
+	crd *extensionsv1beta1.CustomResourceDefinition) error {
+	oldCRD, err := crdClient.Get(crd.Name, metav1.GetOptions{})
 	if err != nil {
 		if !errors.IsNotFound(err) {
-			return fmt.Errorf("get crd failed: %v", err)
+			return fmt.Errorf("get crd %s failed: %v", crd.Name, err)
 		}
-		if _, createErr := crdClient.Create(csiCRD); createErr != nil {
-			return fmt.Errorf("create crd failed: %v", createErr)
+		if _, createErr := crdClient.Create(crd); createErr != nil {
+			return fmt.Errorf("create crd %s failed: %v", crd.Name, createErr)
 		}
-		klog.Info("CRD created")
+		klog.Infof("CRD %s created", crd.Name)
 		return nil
 	}
 
 	// Update the crd if needed.
-	if equality.Semantic.DeepEqual(oldCRD.Spec, csiCRD.Spec) {
-		klog.Info("CRD is already created, no need to update it")
+	if equality.Semantic.DeepEqual(oldCRD.Spec, crd.Spec) {
+		klog.Infof("CRD %s is already created, no need to update it", crd.Name)
 		return nil
 	}
 
-	klog.Info("Try to update crd")
+	klog.Infof("Try to update crd %s", crd.Name)
 	newCRD := oldCRD.DeepCopy()
-	newCRD.Spec = csiCRD.Spec
+	newCRD.Spec = crd.Spec
 	_, err = crdClient.Update(newCRD)
 	if err == nil {
-		klog.Info("CRD updated")
+		klog.Infof("CRD %s updated", crd.Name)
 	}
 
 	return err
@@ -0,0 +1,119 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package v1
+
+import (
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DriverProfileSpec describes a well known driver as data, so that the CSI objects of the driver
+// only need to set the version and the parameters. ${parameter} in the args and the env values of the
+// driver container, and in the parameters and the mount options of the StorageClasses, is replaced by
+// the parameter of the CSI object. ${csi.name} and ${csi.namespace} refer to the CSI object.
+type DriverProfileSpec struct {
+	// DriverName is the driverName of the CSI objects using the profile.
+	DriverName string `json:"driverName" protobuf:"bytes,1,opt,name=driverName"`
+	// Versions are the images of the components of each supported version.
+	Versions []DriverProfileVersion `json:"versions" protobuf:"bytes,2,opt,name=versions"`
+	// DriverTemplate of the CSI objects, with the host mounts and the RBAC rules of the driver.
+	// The image of the driver container defaults to the driver image of the version.
+	DriverTemplate CSIDriverTemplate `json:"driverTemplate" protobuf:"bytes,3,opt,name=driverTemplate"`
+	// Parameters declared by the driver.
+	// +optional
+	Parameters []DriverProfileParameter `json:"parameters,omitempty" protobuf:"bytes,4,opt,name=parameters"`
+	// LivenessProbePorts are the ports of the livenessprobe sidecars.
+	// +optional
+	LivenessProbePorts *DriverProfilePorts `json:"livenessProbePorts,omitempty" protobuf:"bytes,5,opt,name=livenessProbePorts"`
+	// MetricsPorts are the first metrics ports of the components, if metrics are enabled by the CSI objects.
+	// +optional
+	MetricsPorts *DriverProfilePorts `json:"metricsPorts,omitempty" protobuf:"bytes,6,opt,name=metricsPorts"`
+	// StorageClasses generated for the CSI objects. The provisioner is set to the driver name.
+	// +optional
+	StorageClasses []storagev1.StorageClass `json:"storageClasses,omitempty" protobuf:"bytes,7,opt,name=storageClasses"`
+}
+
+// DriverProfileVersion is the set of images of the components of a version. Images without a registry
+// are pulled from the registry of the operator.
+type DriverProfileVersion struct {
+	// Version of the CSI objects.
+	Version CSIVersion `json:"version"`
+	// Driver is the image of the driver.
+	Driver string `json:"driver"`
+	// +optional
+	Provisioner string `json:"provisioner,omitempty"`
+	// +optional
+	Attacher string `json:"attacher,omitempty"`
+	// +optional
+	Resizer string `json:"resizer,omitempty"`
+	// +optional
+	Snapshotter string `json:"snapshotter,omitempty"`
+	// +optional
+	LivenessProbe string `json:"livenessProbe,omitempty"`
+	// +optional
+	NodeRegistrar string `json:"nodeRegistrar,omitempty"`
+	// +optional
+	ClusterRegistrar string `json:"clusterRegistrar,omitempty"`
+}
+
+// DriverProfileParameter is a parameter of the CSI objects of a driver.
+type DriverProfileParameter struct {
+	// Name of the parameter.
+	Name string `json:"name"`
+	// Required parameters must be set by the CSI objects.
+	// +optional
+	Required bool `json:"required,omitempty"`
+	// Default is used if the parameter is not set.
+	// +optional
+	Default string `json:"default,omitempty"`
+}
+
+// DriverProfilePorts are the ports of the node and the controller components.
+type DriverProfilePorts struct {
+	// +optional
+	Node int32 `json:"node,omitempty"`
+	// +optional
+	Controller int32 `json:"controller,omitempty"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DriverProfile is the Schema for the driverprofiles API
+// +k8s:openapi-gen=true
+type DriverProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DriverProfileSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DriverProfileList contains a list of DriverProfile
+type DriverProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DriverProfile `json:"items"`
+}
+
+// init func.
+func init() {
+	SchemeBuilder.Register(&DriverProfile{}, &DriverProfileList{})
+}
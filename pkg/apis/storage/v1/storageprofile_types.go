@@ -0,0 +1,110 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StorageProfileSpec defines the StorageClasses and VolumeSnapshotClasses of a CSI driver,
+// managed separately from the CSI object.
+type StorageProfileSpec struct {
+	// CSI is the CSI object providing the driver, secrets and parameters.
+	CSI CSIReference `json:"csi" protobuf:"bytes,1,opt,name=csi"`
+	// StorageClasses to create. Name is required and must differ from the StorageClasses of
+	// the CSI object, Base refers to a StorageClass of the CSI object whose parameters are
+	// used as defaults. If Base is empty, only the secret parameters of the driver are
+	// injected, which requires all StorageClasses of the CSI object to use the same secrets.
+	// +optional
+	StorageClasses []CSIStorageClassTemplate `json:"storageClasses,omitempty" protobuf:"bytes,2,opt,name=storageClasses"`
+	// VolumeSnapshotClasses to create, the snapshotter secret parameters are injected
+	// from the provisioner secret of the CSI object if they are not set.
+	// +optional
+	VolumeSnapshotClasses []VolumeSnapshotClassTemplate `json:"volumeSnapshotClasses,omitempty" protobuf:"bytes,3,opt,name=volumeSnapshotClasses"`
+}
+
+// CSIReference refers to a CSI object.
+type CSIReference struct {
+	// Namespace of the CSI object.
+	Namespace string `json:"namespace"`
+	// Name of the CSI object.
+	Name string `json:"name"`
+}
+
+// VolumeSnapshotClassTemplate describes a VolumeSnapshotClass of a CSI driver.
+type VolumeSnapshotClassTemplate struct {
+	// Name of the VolumeSnapshotClass.
+	Name string `json:"name"`
+	// Labels of the VolumeSnapshotClass.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations of the VolumeSnapshotClass.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Parameters of the VolumeSnapshotClass, they take precedence over the injected ones.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// DeletionPolicy of the VolumeSnapshotContents, Delete or Retain. Defaults to Delete.
+	// Ignored by clusters only serving snapshot.storage.k8s.io/v1alpha1.
+	// +optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// StorageProfileStatus defines the observed state of StorageProfile.
+type StorageProfileStatus struct {
+	// The generation observed by the operator.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty" protobuf:"bytes,1,opt,name=observedGeneration"`
+	// Represents the latest available observations of a StorageProfile's current state.
+	// +optional
+	Conditions []CSICondition `json:"conditions,omitempty" protobuf:"bytes,2,opt,name=conditions"`
+	// Names of the StorageClasses created.
+	// +optional
+	StorageClasses []string `json:"storageClasses,omitempty" protobuf:"bytes,3,opt,name=storageClasses"`
+	// Names of the VolumeSnapshotClasses created.
+	// +optional
+	VolumeSnapshotClasses []string `json:"volumeSnapshotClasses,omitempty" protobuf:"bytes,4,opt,name=volumeSnapshotClasses"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// StorageProfile is the Schema for the storageprofiles API
+// +k8s:openapi-gen=true
+type StorageProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   StorageProfileSpec   `json:"spec,omitempty"`
+	Status StorageProfileStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// StorageProfileList contains a list of StorageProfile
+type StorageProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []StorageProfile `json:"items"`
+}
+
+// init func.
+func init() {
+	SchemeBuilder.Register(&StorageProfile{}, &StorageProfileList{})
+}
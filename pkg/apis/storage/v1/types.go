@@ -36,6 +36,7 @@ type CSISpec struct {
 	Version CSIVersion `json:"version" protobuf:"bytes,1,opt,name=version"`
 	// Parameters contains global parameters for a well known CSI type and version.
 	// Such as ceph cluster information, etc.
+	// Deprecated: use the typed sections such as Ceph and TencentCloud instead, which take precedence.
 	Parameters map[string]string `json:"parameters" protobuf:"bytes,2,opt,name=parameters"`
 	// Name of the CSI driver.
 	DriverName string `json:"driverName" protobuf:"bytes,3,opt,name=driverName"`
@@ -60,6 +61,379 @@ type CSISpec struct {
 	// ConfigMaps used by csi drivers
 	// +optional
 	ConfigMaps []corev1.ConfigMap `json:"configMaps,omitempty" protobuf:"bytes,10,opt,name=configMaps"`
+	// Metrics configures the metrics endpoints of the driver and sidecars.
+	// +optional
+	Metrics *CSIMetrics `json:"metrics,omitempty" protobuf:"bytes,11,opt,name=metrics"`
+	// DeletionPolicy decides what happens to the StorageClasses and Secrets when the CSI object is deleted.
+	// It can be overridden per object by the storage.tkestack.io/deletion-policy annotation.
+	// Defaults to Delete.
+	// +optional
+	DeletionPolicy CSIDeletionPolicy `json:"deletionPolicy,omitempty" protobuf:"bytes,12,opt,name=deletionPolicy"`
+	// Adoption lets the operator take over objects of a driver installed before.
+	// +optional
+	Adoption *CSIAdoption `json:"adoption,omitempty" protobuf:"bytes,13,opt,name=adoption"`
+	// DefaultStorageClass is the name of a StorageClass in StorageClasses to be marked as
+	// the default StorageClass of the cluster. If more than one CSI object names a default
+	// StorageClass, the oldest one takes effect. The other StorageClasses of all CSI objects are
+	// marked as not default, the annotations set by hand are kept if no CSI object names one.
+	// +optional
+	DefaultStorageClass string `json:"defaultStorageClass,omitempty" protobuf:"bytes,14,opt,name=defaultStorageClass"`
+	// StorageClassTemplates customize the StorageClasses generated for well known drivers.
+	// They are applied in order after the StorageClasses are generated.
+	// +optional
+	StorageClassTemplates []CSIStorageClassTemplate `json:"storageClassTemplates,omitempty" protobuf:"bytes,15,opt,name=storageClassTemplates"`
+	// Topology configures topology-aware provisioning of the driver.
+	// +optional
+	Topology *CSITopology `json:"topology,omitempty" protobuf:"bytes,16,opt,name=topology"`
+	// Ceph configures the well known Ceph drivers of version v1.0 and above.
+	// +optional
+	Ceph *CSICephParameters `json:"ceph,omitempty" protobuf:"bytes,17,opt,name=ceph"`
+	// TencentCloud configures the well known Tencent Cloud drivers.
+	// +optional
+	TencentCloud *CSITencentCloudParameters `json:"tencentCloud,omitempty" protobuf:"bytes,18,opt,name=tencentCloud"`
+	// NFS configures the well known NFS driver.
+	// +optional
+	NFS *CSINFSParameters `json:"nfs,omitempty" protobuf:"bytes,19,opt,name=nfs"`
+	// LVM configures the well known LVM driver.
+	// +optional
+	LVM *CSILVMParameters `json:"lvm,omitempty" protobuf:"bytes,20,opt,name=lvm"`
+}
+
+// CSICephParameters configures the Ceph clusters of a well known Ceph driver.
+type CSICephParameters struct {
+	// Clusters used by the driver, replacing the configs parameter.
+	Clusters []CSICephCluster `json:"clusters"`
+	// Encryption configures the KMS providers of the encrypted images of CephRBD of version v1.1.
+	// +optional
+	Encryption *CSICephEncryption `json:"encryption,omitempty"`
+}
+
+// CSICephEncryption configures the encrypted images of CephRBD.
+type CSICephEncryption struct {
+	// KMS providers of the passphrases of the encrypted images. An encrypted variant of
+	// each StorageClass is generated for each KMS.
+	KMS []CSICephKMS `json:"kms"`
+}
+
+// CSICephKMS is a KMS provider of ceph-csi.
+type CSICephKMS struct {
+	// ID of the KMS, referred by the encryptionKMSID of StorageClasses.
+	ID string `json:"id"`
+	// Type of the KMS, metadata, vault or vaulttokens. metadata and vaulttokens need ceph-csi v3.3.0 or later.
+	Type string `json:"type"`
+	// PassphraseSecret is a Secret in the namespace of the CSI object holding the passphrase
+	// of the metadata KMS under the key encryptionPassphrase.
+	// +optional
+	PassphraseSecret string `json:"passphraseSecret,omitempty"`
+	// Options of the KMS passed to ceph-csi as is, such as vaultAddress.
+	// +optional
+	Options map[string]string `json:"options,omitempty"`
+	// SecretName is a Secret in the namespace of the CSI object mounted into the driver
+	// at /etc/ceph-csi-kms/<id>, such as the CA certificate of Vault.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// TenantTokens allows the driver to read the token Secret and the ConfigMap of tenants
+	// in the namespaces of all PersistentVolumeClaims, it must be set by the vaulttokens KMS.
+	// +optional
+	TenantTokens bool `json:"tenantTokens,omitempty"`
+}
+
+// CSICephCluster is a Ceph cluster used by a well known Ceph driver.
+type CSICephCluster struct {
+	// ClusterID identifies the cluster in StorageClasses. Defaults to the fsid of the imported ceph.conf.
+	// +optional
+	ClusterID string `json:"clusterID,omitempty"`
+	// Monitors of the cluster. Defaults to mon_host of the imported ceph.conf.
+	// +optional
+	Monitors []string `json:"monitors,omitempty"`
+	// AdminID is the user to provision volumes. Defaults to admin with an imported ceph.conf.
+	// +optional
+	AdminID string `json:"adminID,omitempty"`
+	// AdminKey is the key of AdminID. Defaults to the one in the imported keyrings.
+	// +optional
+	AdminKey string `json:"adminKey,omitempty"`
+	// UserID is the restricted user used by nodes. Defaults to AdminID.
+	// +optional
+	UserID string `json:"userID,omitempty"`
+	// UserKey is the key of UserID. Defaults to the one in the imported keyrings.
+	// +optional
+	UserKey string `json:"userKey,omitempty"`
+	// PoolUsers are the users used by nodes for each pool, instead of UserID.
+	// +optional
+	PoolUsers map[string]CSICephUser `json:"poolUsers,omitempty"`
+	// Pools of the cluster, a StorageClass is generated for each pool.
+	Pools []string `json:"pools"`
+	// FSName is the file system of CephFS. Defaults to cephfs.
+	// +optional
+	FSName string `json:"fsName,omitempty"`
+	// SubVolumeGroup of the volumes of CephFS.
+	// +optional
+	SubVolumeGroup string `json:"subvolumeGroup,omitempty"`
+	// CephConfSecret refers a Secret holding ceph.conf and keyrings, in the format of <namespace>/<name>
+	// or <name>. The Secret must be in the namespace of the CSI object.
+	// +optional
+	CephConfSecret string `json:"cephConfSecret,omitempty"`
+	// CephConfConfigMap refers a ConfigMap holding ceph.conf and keyrings, in the format of <namespace>/<name>
+	// or <name>. The ConfigMap must be in the namespace of the CSI object.
+	// +optional
+	CephConfConfigMap string `json:"cephConfConfigMap,omitempty"`
+	// Classes of CephFS, a StorageClass is generated for each class instead of each pool.
+	// +optional
+	Classes []CSICephFSClass `json:"classes,omitempty"`
+}
+
+// CSICephUser is a restricted Ceph user used by nodes to map or mount volumes.
+type CSICephUser struct {
+	UserID  string `json:"userID"`
+	UserKey string `json:"userKey"`
+}
+
+// CSICephFSClass describes a StorageClass of CephFS with its own mounter and options.
+type CSICephFSClass struct {
+	// Name of the StorageClass, such as cephfs-ssd-kernel.
+	Name string `json:"name"`
+	// Pool of the volumes, defaults to the first pool of the cluster.
+	// +optional
+	Pool string `json:"pool,omitempty"`
+	// FSName of the volumes, defaults to the fsName of the cluster.
+	// +optional
+	FSName string `json:"fsName,omitempty"`
+	// Mounter is kernel or fuse, defaults to the choice of ceph-csi.
+	// +optional
+	Mounter string `json:"mounter,omitempty"`
+	// KernelMountOptions are passed to the kernel mounter.
+	// +optional
+	KernelMountOptions string `json:"kernelMountOptions,omitempty"`
+	// FuseMountOptions are passed to ceph-fuse.
+	// +optional
+	FuseMountOptions string `json:"fuseMountOptions,omitempty"`
+	// VolumeNamePrefix is the prefix of the subvolumes.
+	// +optional
+	VolumeNamePrefix string `json:"volumeNamePrefix,omitempty"`
+	// SubVolumeGroup of the volumes, defaults to the subvolumeGroup of the cluster.
+	// +optional
+	SubVolumeGroup string `json:"subvolumeGroup,omitempty"`
+	// MountOptions of the StorageClass.
+	// +optional
+	MountOptions []string `json:"mountOptions,omitempty"`
+}
+
+// CSITencentCloudParameters configures a well known Tencent Cloud driver.
+type CSITencentCloudParameters struct {
+	// SecretID of the Tencent Cloud API. Defaults to the one of the operator.
+	// +optional
+	SecretID string `json:"secretID,omitempty"`
+	// SecretKey of the Tencent Cloud API. Defaults to the one of the operator.
+	// +optional
+	SecretKey string `json:"secretKey,omitempty"`
+	// CFS configures the file systems created by CFS.
+	// +optional
+	CFS *CSITencentCloudCFS `json:"cfs,omitempty"`
+	// COS configures the buckets mounted by COS.
+	// +optional
+	COS *CSITencentCloudCOS `json:"cos,omitempty"`
+}
+
+// CSITencentCloudCFS configures the file systems created by CFS.
+type CSITencentCloudCFS struct {
+	// VPCID is the VPC of the file systems.
+	VPCID string `json:"vpcID"`
+	// SubnetID is the subnet of the file systems.
+	SubnetID string `json:"subnetID"`
+	// Zone of the file systems.
+	// +optional
+	Zone string `json:"zone,omitempty"`
+	// PGroupID is the permission group of the file systems.
+	// +optional
+	PGroupID string `json:"pgroupID,omitempty"`
+}
+
+// CSITencentCloudCOS configures the buckets mounted by COS.
+type CSITencentCloudCOS struct {
+	// Buckets and the Secrets holding their credentials.
+	Buckets []CSICOSBucket `json:"buckets"`
+}
+
+// CSICOSBucket is a COS bucket and the Secret holding its credential.
+type CSICOSBucket struct {
+	// Name of the bucket.
+	Name string `json:"name"`
+	// SecretRef refers the Secret holding SecretId and SecretKey of the bucket,
+	// which must be in the namespace of the CSI object.
+	SecretRef corev1.SecretReference `json:"secretRef"`
+}
+
+// CSINFSParameters configures the NFS exports consumed by the NFS driver.
+type CSINFSParameters struct {
+	// Shares exported by the NFS servers, a StorageClass is generated for each share.
+	Shares []CSINFSShare `json:"shares"`
+}
+
+// CSINFSShare is an NFS export and the StorageClass provisioning volumes in it.
+type CSINFSShare struct {
+	// Name of the StorageClass.
+	Name string `json:"name"`
+	// Server is the address of the NFS server.
+	Server string `json:"server"`
+	// Share is the exported path. Defaults to /.
+	// +optional
+	Share string `json:"share,omitempty"`
+	// SubDir is the directory of each volume in the share, which may contain ${pvc.metadata.name},
+	// ${pvc.metadata.namespace} and ${pv.metadata.name}. Defaults to the name of the PV.
+	// Only supported by version v1.1.
+	// +optional
+	SubDir string `json:"subDir,omitempty"`
+	// MountOptions of the volumes, such as nfsvers=4.1.
+	// +optional
+	MountOptions []string `json:"mountOptions,omitempty"`
+	// ReclaimPolicy of the StorageClass. Defaults to Delete.
+	// +optional
+	ReclaimPolicy *corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+}
+
+// CSILVMParameters configures the storage nodes and the volume groups of the LVM driver.
+type CSILVMParameters struct {
+	// NodeSelector selects the storage nodes running the node driver.
+	// Defaults to the nodes labelled with storage.tkestack.io/lvm=true.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// DeviceClasses are the volume groups of the storage nodes, a StorageClass is generated for each device class.
+	DeviceClasses []CSILVMDeviceClass `json:"deviceClasses"`
+}
+
+// CSILVMDeviceClass is a volume group of the storage nodes and the StorageClass provisioning volumes in it.
+type CSILVMDeviceClass struct {
+	// Name of the device class and the StorageClass.
+	Name string `json:"name"`
+	// VolumeGroup is the name of the LVM volume group on the storage nodes.
+	VolumeGroup string `json:"volumeGroup"`
+	// Default is true for the device class of the volumes not requesting one. Defaults to the first device class.
+	// +optional
+	Default bool `json:"default,omitempty"`
+	// SpareGB is the capacity in GiB of the volume group not used by volumes.
+	// +optional
+	SpareGB *int64 `json:"spareGB,omitempty"`
+	// FSType of the volumes. Defaults to xfs.
+	// +optional
+	FSType string `json:"fsType,omitempty"`
+}
+
+// CSITopology configures topology-aware provisioning. A driver is zonal if Key is set,
+// or if it is a well known driver reporting a zone topology key, such as Tencent Cloud CBS.
+type CSITopology struct {
+	// Enabled turns on the Topology feature of the provisioner, so that volumes
+	// are provisioned in the topology of the selected node.
+	Enabled bool `json:"enabled"`
+	// Strict makes the provisioner only use the topology of the selected node,
+	// instead of all topologies allowed by the StorageClass.
+	// +optional
+	Strict bool `json:"strict,omitempty"`
+	// Key is the node label key of the zone reported by the driver.
+	// Defaults to the key of well known zonal drivers.
+	// +optional
+	Key string `json:"key,omitempty"`
+	// VolumeBindingMode is set to the StorageClasses without a volume binding mode.
+	// Defaults to WaitForFirstConsumer for zonal drivers.
+	// +optional
+	VolumeBindingMode *storagev1.VolumeBindingMode `json:"volumeBindingMode,omitempty"`
+	// PerZoneStorageClasses generates a StorageClass named <name>-<zone> restricted to the zone
+	// for each StorageClass and each zone of the nodes the driver is registered on.
+	// The StorageClasses of a zone are kept until the zone has no nodes.
+	// +optional
+	PerZoneStorageClasses bool `json:"perZoneStorageClasses,omitempty"`
+}
+
+// CSIStorageClassTemplate is merged onto the StorageClasses generated for a well known driver,
+// so that the generated secret, cluster and pool parameters are kept.
+type CSIStorageClassTemplate struct {
+	// Base is the name of the generated StorageClass the template starts from.
+	// If empty, the template is applied to all generated StorageClasses.
+	// +optional
+	Base string `json:"base,omitempty"`
+	// Name of a new StorageClass generated from Base. If empty, the base StorageClasses
+	// are modified in place. Base must be set if Name is set.
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Labels merged into the labels of the StorageClass.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations merged into the annotations of the StorageClass.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Parameters merged into the parameters of the StorageClass, the template takes precedence.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// ReclaimPolicy overrides the reclaim policy of the StorageClass.
+	// +optional
+	ReclaimPolicy *corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	// MountOptions overrides the mount options of the StorageClass.
+	// +optional
+	MountOptions []string `json:"mountOptions,omitempty"`
+	// AllowVolumeExpansion overrides whether the StorageClass allows volume expansion.
+	// +optional
+	AllowVolumeExpansion *bool `json:"allowVolumeExpansion,omitempty"`
+	// VolumeBindingMode overrides the volume binding mode of the StorageClass.
+	// +optional
+	VolumeBindingMode *storagev1.VolumeBindingMode `json:"volumeBindingMode,omitempty"`
+	// AllowedTopologies overrides the allowed topologies of the StorageClass.
+	// +optional
+	AllowedTopologies []corev1.TopologySelectorTerm `json:"allowedTopologies,omitempty"`
+}
+
+// CSIAdoption describes the objects of a driver installed without the operator.
+// StorageClasses, Secrets, ConfigMaps and the CSIDriver object with the same names as the
+// generated ones are claimed, if they are not owned by another CSI object or controller.
+type CSIAdoption struct {
+	// DaemonSets in the namespace of the CSI object which run the node driver before.
+	// They are deleted once adopted, before the generated node driver is ready.
+	// +optional
+	DaemonSets []string `json:"daemonSets,omitempty"`
+	// Deployments in the namespace of the CSI object which run the controller driver before.
+	// They are deleted once adopted, before the generated controller driver is ready.
+	// +optional
+	Deployments []string `json:"deployments,omitempty"`
+}
+
+// CSIDeletionPolicy describes how to handle children of a CSI object when it is deleted.
+type CSIDeletionPolicy string
+
+const (
+	// CSIDeletionDelete deletes the children with the CSI object.
+	CSIDeletionDelete = "Delete"
+	// CSIDeletionOrphan removes the owner information from the children and leaves them unmanaged.
+	CSIDeletionOrphan = "Orphan"
+	// CSIDeletionRetain removes the owner information from the children and marks them,
+	// so that a CSI object with the same namespace and name will adopt them again.
+	CSIDeletionRetain = "Retain"
+)
+
+// CSIMonitorType is the kind of prometheus-operator monitor created for a CSI object.
+type CSIMonitorType string
+
+const (
+	// CSIServiceMonitor indicates creating a ServiceMonitor for the metrics Services.
+	CSIServiceMonitor = "ServiceMonitor"
+	// CSIPodMonitor indicates creating a PodMonitor for the driver pods.
+	CSIPodMonitor = "PodMonitor"
+)
+
+// CSIMetrics is the configuration of the metrics endpoints of the driver and sidecars.
+type CSIMetrics struct {
+	// Enabled turns on the metrics endpoints and creates Services for the node
+	// driver and controller driver pods.
+	Enabled bool `json:"enabled"`
+	// NodePort is the first metrics port of the node driver pods. The driver uses this port if
+	// its args refer the METRICS_ADDRESS or METRICS_PORT env, which are :<port> and <port>.
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
+	// ControllerPort is the first metrics port of the controller driver pods. The driver
+	// uses this port, the provisioner, attacher, resizer and snapshotter use the following ones.
+	// +optional
+	ControllerPort int32 `json:"controllerPort,omitempty"`
+	// Monitor is the kind of prometheus-operator monitor to create, ServiceMonitor or PodMonitor.
+	// It is ignored if the CRD of the monitor is not present.
+	// +optional
+	Monitor CSIMonitorType `json:"monitor,omitempty"`
 }
 
 const (
@@ -73,6 +447,10 @@ const (
 	CSIDriverTencentCOS = "csi-tencent-cloud-cos"
 	// CSIDriverTencentCFS indicates the Tencent Cloud CFS storage type.
 	CSIDriverTencentCFS = "csi-tencent-cloud-cfs"
+	// CSIDriverNFS indicates the NFS storage type.
+	CSIDriverNFS = "nfs.csi.k8s.io"
+	// CSIDriverLVM indicates the LVM storage type of the node local volumes, provided by TopoLVM.
+	CSIDriverLVM = "topolvm.io"
 )
 
 // CSIVersion indicates the version of CSI external components.
@@ -83,20 +461,30 @@ const (
 	CSIVersionV0 = "v0.0"
 	// CSIVersionV1 indicates the 1.x version of CSI.
 	CSIVersionV1 = "v1.0"
-	// CSIVersionV1p1 indicates the 1.1+ version of CSI in tencent cloud cvm,
-	// which does not need to use secret id and key.
+	// CSIVersionV1p1 indicates the 1.1+ version of CSI. For TencentCloud CBS, it runs in
+	// tencent cloud cvm, which does not need to use secret id and key. For CephRBD, it runs
+	// ceph-csi 3.x with one or more Ceph clusters.
 	CSIVersionV1p1 = "v1.1"
 )
 
 // CSIDriverTemplate is the definition of the Driver container.
 type CSIDriverTemplate struct {
-	// Should contain one and only one container which is the concrete driver.
+	// Should contain one and only one container which is the concrete driver, other containers
+	// of the node driver pods are set in NodeSidecars.
 	// The container should use the CSI_ENDPOINT env to get the CSI socket in the command.
 	// It should only contain CSI un-related volumes and volumeMounts, such as /sys, /lib/modules, etc.
 	Template corev1.PodTemplateSpec `json:"template,omitempty" protobuf:"bytes,1,opt,name=template"`
 	// Special Cluster rules needed by the driver.
 	// +optional
 	Rules []rbacv1.PolicyRule `json:"rules,omitempty" protobuf:"bytes,2,opt,name=rules"`
+	// Template of the controller driver, for the drivers running another command in the controller.
+	// The controller driver is granted the Rules too if set. Defaults to Template.
+	// +optional
+	ControllerTemplate *corev1.PodTemplateSpec `json:"controllerTemplate,omitempty" protobuf:"bytes,3,opt,name=controllerTemplate"`
+	// Containers running beside the driver in the node driver pods, such as the mount
+	// daemons which must survive the restart of the driver container.
+	// +optional
+	NodeSidecars []corev1.Container `json:"nodeSidecars,omitempty" protobuf:"bytes,4,rep,name=nodeSidecars"`
 }
 
 // CSIController is the configuration of the controller sidecars.
@@ -172,6 +560,98 @@ type CSIStatus struct {
 
 	// Represents the latest available observations of a CSI's current state.
 	Conditions []CSICondition `json:"conditions,omitempty" protobuf:"bytes,4,opt,name=conditions"`
+
+	// Registration of the driver on the nodes running the node driver, as reported by CSINode objects.
+	// +optional
+	NodeRegistration *CSINodeRegistration `json:"nodeRegistration,omitempty" protobuf:"bytes,5,opt,name=nodeRegistration"`
+
+	// Failing containers of the node driver and controller driver pods.
+	// +optional
+	ContainerFailures []CSIContainerFailure `json:"containerFailures,omitempty" protobuf:"bytes,6,opt,name=containerFailures"`
+
+	// Objects installed without the operator and adopted by the CSI object.
+	// +optional
+	Adopted []CSIAdoptedObject `json:"adopted,omitempty" protobuf:"bytes,7,opt,name=adopted"`
+
+	// Entry of the version catalog used by a well known driver.
+	// +optional
+	Catalog *CSICatalogStatus `json:"catalog,omitempty" protobuf:"bytes,8,opt,name=catalog"`
+}
+
+// CSICatalogStatus is the entry of the version catalog used by a CSI object.
+type CSICatalogStatus struct {
+	// Source of the entry, builtin or the path of the catalog file overriding it.
+	Source string `json:"source"`
+	// Revision of the catalog file.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// Entry used by the CSI object, in the form of <driverName>/<version>.
+	Entry string `json:"entry"`
+	// DriverUpdate is the driver image of the entry, if the CSI object pins an older driver by driverVersion.
+	// +optional
+	DriverUpdate string `json:"driverUpdate,omitempty"`
+}
+
+// CSIAdoptedObject is an object adopted by a CSI object.
+type CSIAdoptedObject struct {
+	// Kind of the adopted object.
+	Kind string `json:"kind"`
+	// Namespace of the adopted object, empty for cluster scoped objects.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the adopted object.
+	Name string `json:"name"`
+	// Replaced is true if the object is deleted and replaced by the generated one.
+	// +optional
+	Replaced bool `json:"replaced,omitempty"`
+}
+
+// CSIContainerFailure aggregates the failures of a driver or sidecar container across pods.
+type CSIContainerFailure struct {
+	// Component is the workload the container belongs to, node or controller.
+	Component string `json:"component"`
+	// Container is the name of the failing container.
+	Container string `json:"container"`
+	// Reason is the reason why the container is not running, such as CrashLoopBackOff.
+	Reason string `json:"reason"`
+	// Pods is the number of pods in which the container fails with this reason.
+	Pods int32 `json:"pods"`
+	// RestartCount is the highest restart count of the container among these pods.
+	RestartCount int32 `json:"restartCount"`
+	// Message is the last termination message of the container on the sample node.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Node is a sample node on which the container fails.
+	// +optional
+	Node string `json:"node,omitempty"`
+}
+
+// CSINodeRegistration describes how many nodes have registered the driver with kubelet.
+type CSINodeRegistration struct {
+	// DesiredNodes is the number of nodes that should be running the node driver.
+	DesiredNodes int32 `json:"desiredNodes"`
+	// RegisteredNodes is the number of nodes whose CSINode object advertises the driver.
+	RegisteredNodes int32 `json:"registeredNodes"`
+	// MissingNodes lists the nodes running a node driver pod on which kubelet has not
+	// registered the driver yet. The list is truncated if too many nodes are missing.
+	// +optional
+	MissingNodes []string `json:"missingNodes,omitempty"`
+	// Nodes lists the driver information advertised by registered nodes.
+	// The list is truncated if too many nodes are registered.
+	// +optional
+	Nodes []CSINodeDriverInfo `json:"nodes,omitempty"`
+}
+
+// CSINodeDriverInfo is the driver information advertised by a CSINode object.
+type CSINodeDriverInfo struct {
+	// Name of the node.
+	Name string `json:"name"`
+	// Allocatable is the maximum number of volumes of the driver that can be used on the node.
+	// +optional
+	Allocatable *int32 `json:"allocatable,omitempty"`
+	// TopologyKeys is the list of topology keys supported by the driver on the node.
+	// +optional
+	TopologyKeys []string `json:"topologyKeys,omitempty"`
 }
 
 // Generation keeps track of the generation for a given object.
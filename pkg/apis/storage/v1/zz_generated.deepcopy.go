@@ -55,6 +55,232 @@ func (in *CSI) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSIAdoptedObject) DeepCopyInto(out *CSIAdoptedObject) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSIAdoptedObject.
+func (in *CSIAdoptedObject) DeepCopy() *CSIAdoptedObject {
+	if in == nil {
+		return nil
+	}
+	out := new(CSIAdoptedObject)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSIAdoption) DeepCopyInto(out *CSIAdoption) {
+	*out = *in
+	if in.DaemonSets != nil {
+		in, out := &in.DaemonSets, &out.DaemonSets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Deployments != nil {
+		in, out := &in.Deployments, &out.Deployments
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSIAdoption.
+func (in *CSIAdoption) DeepCopy() *CSIAdoption {
+	if in == nil {
+		return nil
+	}
+	out := new(CSIAdoption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSICOSBucket) DeepCopyInto(out *CSICOSBucket) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSICOSBucket.
+func (in *CSICOSBucket) DeepCopy() *CSICOSBucket {
+	if in == nil {
+		return nil
+	}
+	out := new(CSICOSBucket)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSICatalogStatus) DeepCopyInto(out *CSICatalogStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSICatalogStatus.
+func (in *CSICatalogStatus) DeepCopy() *CSICatalogStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CSICatalogStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSICephCluster) DeepCopyInto(out *CSICephCluster) {
+	*out = *in
+	if in.Monitors != nil {
+		in, out := &in.Monitors, &out.Monitors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PoolUsers != nil {
+		in, out := &in.PoolUsers, &out.PoolUsers
+		*out = make(map[string]CSICephUser, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Pools != nil {
+		in, out := &in.Pools, &out.Pools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Classes != nil {
+		in, out := &in.Classes, &out.Classes
+		*out = make([]CSICephFSClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSICephCluster.
+func (in *CSICephCluster) DeepCopy() *CSICephCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(CSICephCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSICephFSClass) DeepCopyInto(out *CSICephFSClass) {
+	*out = *in
+	if in.MountOptions != nil {
+		in, out := &in.MountOptions, &out.MountOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSICephFSClass.
+func (in *CSICephFSClass) DeepCopy() *CSICephFSClass {
+	if in == nil {
+		return nil
+	}
+	out := new(CSICephFSClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSICephEncryption) DeepCopyInto(out *CSICephEncryption) {
+	*out = *in
+	if in.KMS != nil {
+		in, out := &in.KMS, &out.KMS
+		*out = make([]CSICephKMS, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSICephEncryption.
+func (in *CSICephEncryption) DeepCopy() *CSICephEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(CSICephEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSICephKMS) DeepCopyInto(out *CSICephKMS) {
+	*out = *in
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSICephKMS.
+func (in *CSICephKMS) DeepCopy() *CSICephKMS {
+	if in == nil {
+		return nil
+	}
+	out := new(CSICephKMS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSICephParameters) DeepCopyInto(out *CSICephParameters) {
+	*out = *in
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]CSICephCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(CSICephEncryption)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSICephParameters.
+func (in *CSICephParameters) DeepCopy() *CSICephParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(CSICephParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSICephUser) DeepCopyInto(out *CSICephUser) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSICephUser.
+func (in *CSICephUser) DeepCopy() *CSICephUser {
+	if in == nil {
+		return nil
+	}
+	out := new(CSICephUser)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CSIComponent) DeepCopyInto(out *CSIComponent) {
 	*out = *in
@@ -96,6 +322,22 @@ func (in *CSICondition) DeepCopy() *CSICondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSIContainerFailure) DeepCopyInto(out *CSIContainerFailure) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSIContainerFailure.
+func (in *CSIContainerFailure) DeepCopy() *CSIContainerFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(CSIContainerFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CSIController) DeepCopyInto(out *CSIController) {
 	*out = *in
@@ -153,6 +395,18 @@ func (in *CSIDriverTemplate) DeepCopyInto(out *CSIDriverTemplate) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ControllerTemplate != nil {
+		in, out := &in.ControllerTemplate, &out.ControllerTemplate
+		*out = new(corev1.PodTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSidecars != nil {
+		in, out := &in.NodeSidecars, &out.NodeSidecars
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -166,6 +420,57 @@ func (in *CSIDriverTemplate) DeepCopy() *CSIDriverTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSILVMDeviceClass) DeepCopyInto(out *CSILVMDeviceClass) {
+	*out = *in
+	if in.SpareGB != nil {
+		in, out := &in.SpareGB, &out.SpareGB
+		*out = new(int64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSILVMDeviceClass.
+func (in *CSILVMDeviceClass) DeepCopy() *CSILVMDeviceClass {
+	if in == nil {
+		return nil
+	}
+	out := new(CSILVMDeviceClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSILVMParameters) DeepCopyInto(out *CSILVMParameters) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DeviceClasses != nil {
+		in, out := &in.DeviceClasses, &out.DeviceClasses
+		*out = make([]CSILVMDeviceClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSILVMParameters.
+func (in *CSILVMParameters) DeepCopy() *CSILVMParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(CSILVMParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CSIList) DeepCopyInto(out *CSIList) {
 	*out = *in
@@ -200,65 +505,27 @@ func (in *CSIList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CSINode) DeepCopyInto(out *CSINode) {
+func (in *CSIMetrics) DeepCopyInto(out *CSIMetrics) {
 	*out = *in
-	if in.NodeRegistrar != nil {
-		in, out := &in.NodeRegistrar, &out.NodeRegistrar
-		*out = new(CSIComponent)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.LivenessProbe != nil {
-		in, out := &in.LivenessProbe, &out.LivenessProbe
-		*out = new(CSIComponent)
-		(*in).DeepCopyInto(*out)
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSINode.
-func (in *CSINode) DeepCopy() *CSINode {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSIMetrics.
+func (in *CSIMetrics) DeepCopy() *CSIMetrics {
 	if in == nil {
 		return nil
 	}
-	out := new(CSINode)
+	out := new(CSIMetrics)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CSISpec) DeepCopyInto(out *CSISpec) {
+func (in *CSINFSParameters) DeepCopyInto(out *CSINFSParameters) {
 	*out = *in
-	if in.Parameters != nil {
-		in, out := &in.Parameters, &out.Parameters
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.DriverTemplate != nil {
-		in, out := &in.DriverTemplate, &out.DriverTemplate
-		*out = new(CSIDriverTemplate)
-		(*in).DeepCopyInto(*out)
-	}
-	in.Node.DeepCopyInto(&out.Node)
-	in.Controller.DeepCopyInto(&out.Controller)
-	if in.Secrets != nil {
-		in, out := &in.Secrets, &out.Secrets
-		*out = make([]corev1.Secret, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.StorageClasses != nil {
-		in, out := &in.StorageClasses, &out.StorageClasses
-		*out = make([]storagev1.StorageClass, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.ConfigMaps != nil {
-		in, out := &in.ConfigMaps, &out.ConfigMaps
-		*out = make([]corev1.ConfigMap, len(*in))
+	if in.Shares != nil {
+		in, out := &in.Shares, &out.Shares
+		*out = make([]CSINFSShare, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -266,20 +533,235 @@ func (in *CSISpec) DeepCopyInto(out *CSISpec) {
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSISpec.
-func (in *CSISpec) DeepCopy() *CSISpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSINFSParameters.
+func (in *CSINFSParameters) DeepCopy() *CSINFSParameters {
 	if in == nil {
 		return nil
 	}
-	out := new(CSISpec)
+	out := new(CSINFSParameters)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CSIStatus) DeepCopyInto(out *CSIStatus) {
+func (in *CSINFSShare) DeepCopyInto(out *CSINFSShare) {
 	*out = *in
-	if in.Children != nil {
+	if in.MountOptions != nil {
+		in, out := &in.MountOptions, &out.MountOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReclaimPolicy != nil {
+		in, out := &in.ReclaimPolicy, &out.ReclaimPolicy
+		*out = new(corev1.PersistentVolumeReclaimPolicy)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSINFSShare.
+func (in *CSINFSShare) DeepCopy() *CSINFSShare {
+	if in == nil {
+		return nil
+	}
+	out := new(CSINFSShare)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSINode) DeepCopyInto(out *CSINode) {
+	*out = *in
+	if in.NodeRegistrar != nil {
+		in, out := &in.NodeRegistrar, &out.NodeRegistrar
+		*out = new(CSIComponent)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(CSIComponent)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSINode.
+func (in *CSINode) DeepCopy() *CSINode {
+	if in == nil {
+		return nil
+	}
+	out := new(CSINode)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSINodeDriverInfo) DeepCopyInto(out *CSINodeDriverInfo) {
+	*out = *in
+	if in.Allocatable != nil {
+		in, out := &in.Allocatable, &out.Allocatable
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TopologyKeys != nil {
+		in, out := &in.TopologyKeys, &out.TopologyKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSINodeDriverInfo.
+func (in *CSINodeDriverInfo) DeepCopy() *CSINodeDriverInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(CSINodeDriverInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSINodeRegistration) DeepCopyInto(out *CSINodeRegistration) {
+	*out = *in
+	if in.MissingNodes != nil {
+		in, out := &in.MissingNodes, &out.MissingNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]CSINodeDriverInfo, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSINodeRegistration.
+func (in *CSINodeRegistration) DeepCopy() *CSINodeRegistration {
+	if in == nil {
+		return nil
+	}
+	out := new(CSINodeRegistration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSIReference) DeepCopyInto(out *CSIReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSIReference.
+func (in *CSIReference) DeepCopy() *CSIReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CSIReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSISpec) DeepCopyInto(out *CSISpec) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DriverTemplate != nil {
+		in, out := &in.DriverTemplate, &out.DriverTemplate
+		*out = new(CSIDriverTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Node.DeepCopyInto(&out.Node)
+	in.Controller.DeepCopyInto(&out.Controller)
+	if in.Secrets != nil {
+		in, out := &in.Secrets, &out.Secrets
+		*out = make([]corev1.Secret, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StorageClasses != nil {
+		in, out := &in.StorageClasses, &out.StorageClasses
+		*out = make([]storagev1.StorageClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ConfigMaps != nil {
+		in, out := &in.ConfigMaps, &out.ConfigMaps
+		*out = make([]corev1.ConfigMap, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = new(CSIMetrics)
+		**out = **in
+	}
+	if in.Adoption != nil {
+		in, out := &in.Adoption, &out.Adoption
+		*out = new(CSIAdoption)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StorageClassTemplates != nil {
+		in, out := &in.StorageClassTemplates, &out.StorageClassTemplates
+		*out = make([]CSIStorageClassTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Topology != nil {
+		in, out := &in.Topology, &out.Topology
+		*out = new(CSITopology)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ceph != nil {
+		in, out := &in.Ceph, &out.Ceph
+		*out = new(CSICephParameters)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TencentCloud != nil {
+		in, out := &in.TencentCloud, &out.TencentCloud
+		*out = new(CSITencentCloudParameters)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NFS != nil {
+		in, out := &in.NFS, &out.NFS
+		*out = new(CSINFSParameters)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LVM != nil {
+		in, out := &in.LVM, &out.LVM
+		*out = new(CSILVMParameters)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSISpec.
+func (in *CSISpec) DeepCopy() *CSISpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CSISpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSIStatus) DeepCopyInto(out *CSIStatus) {
+	*out = *in
+	if in.Children != nil {
 		in, out := &in.Children, &out.Children
 		*out = make([]Generation, len(*in))
 		copy(*out, *in)
@@ -291,6 +773,26 @@ func (in *CSIStatus) DeepCopyInto(out *CSIStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.NodeRegistration != nil {
+		in, out := &in.NodeRegistration, &out.NodeRegistration
+		*out = new(CSINodeRegistration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ContainerFailures != nil {
+		in, out := &in.ContainerFailures, &out.ContainerFailures
+		*out = make([]CSIContainerFailure, len(*in))
+		copy(*out, *in)
+	}
+	if in.Adopted != nil {
+		in, out := &in.Adopted, &out.Adopted
+		*out = make([]CSIAdoptedObject, len(*in))
+		copy(*out, *in)
+	}
+	if in.Catalog != nil {
+		in, out := &in.Catalog, &out.Catalog
+		*out = new(CSICatalogStatus)
+		**out = **in
+	}
 	return
 }
 
@@ -304,6 +806,306 @@ func (in *CSIStatus) DeepCopy() *CSIStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSIStorageClassTemplate) DeepCopyInto(out *CSIStorageClassTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ReclaimPolicy != nil {
+		in, out := &in.ReclaimPolicy, &out.ReclaimPolicy
+		*out = new(corev1.PersistentVolumeReclaimPolicy)
+		**out = **in
+	}
+	if in.MountOptions != nil {
+		in, out := &in.MountOptions, &out.MountOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowVolumeExpansion != nil {
+		in, out := &in.AllowVolumeExpansion, &out.AllowVolumeExpansion
+		*out = new(bool)
+		**out = **in
+	}
+	if in.VolumeBindingMode != nil {
+		in, out := &in.VolumeBindingMode, &out.VolumeBindingMode
+		*out = new(storagev1.VolumeBindingMode)
+		**out = **in
+	}
+	if in.AllowedTopologies != nil {
+		in, out := &in.AllowedTopologies, &out.AllowedTopologies
+		*out = make([]corev1.TopologySelectorTerm, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSIStorageClassTemplate.
+func (in *CSIStorageClassTemplate) DeepCopy() *CSIStorageClassTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(CSIStorageClassTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSITencentCloudCFS) DeepCopyInto(out *CSITencentCloudCFS) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSITencentCloudCFS.
+func (in *CSITencentCloudCFS) DeepCopy() *CSITencentCloudCFS {
+	if in == nil {
+		return nil
+	}
+	out := new(CSITencentCloudCFS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSITencentCloudCOS) DeepCopyInto(out *CSITencentCloudCOS) {
+	*out = *in
+	if in.Buckets != nil {
+		in, out := &in.Buckets, &out.Buckets
+		*out = make([]CSICOSBucket, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSITencentCloudCOS.
+func (in *CSITencentCloudCOS) DeepCopy() *CSITencentCloudCOS {
+	if in == nil {
+		return nil
+	}
+	out := new(CSITencentCloudCOS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSITencentCloudParameters) DeepCopyInto(out *CSITencentCloudParameters) {
+	*out = *in
+	if in.CFS != nil {
+		in, out := &in.CFS, &out.CFS
+		*out = new(CSITencentCloudCFS)
+		**out = **in
+	}
+	if in.COS != nil {
+		in, out := &in.COS, &out.COS
+		*out = new(CSITencentCloudCOS)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSITencentCloudParameters.
+func (in *CSITencentCloudParameters) DeepCopy() *CSITencentCloudParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(CSITencentCloudParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSITopology) DeepCopyInto(out *CSITopology) {
+	*out = *in
+	if in.VolumeBindingMode != nil {
+		in, out := &in.VolumeBindingMode, &out.VolumeBindingMode
+		*out = new(storagev1.VolumeBindingMode)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSITopology.
+func (in *CSITopology) DeepCopy() *CSITopology {
+	if in == nil {
+		return nil
+	}
+	out := new(CSITopology)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverProfile) DeepCopyInto(out *DriverProfile) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverProfile.
+func (in *DriverProfile) DeepCopy() *DriverProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DriverProfile) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverProfileList) DeepCopyInto(out *DriverProfileList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DriverProfile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverProfileList.
+func (in *DriverProfileList) DeepCopy() *DriverProfileList {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverProfileList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DriverProfileList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverProfileParameter) DeepCopyInto(out *DriverProfileParameter) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverProfileParameter.
+func (in *DriverProfileParameter) DeepCopy() *DriverProfileParameter {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverProfileParameter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverProfilePorts) DeepCopyInto(out *DriverProfilePorts) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverProfilePorts.
+func (in *DriverProfilePorts) DeepCopy() *DriverProfilePorts {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverProfilePorts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverProfileSpec) DeepCopyInto(out *DriverProfileSpec) {
+	*out = *in
+	if in.Versions != nil {
+		in, out := &in.Versions, &out.Versions
+		*out = make([]DriverProfileVersion, len(*in))
+		copy(*out, *in)
+	}
+	in.DriverTemplate.DeepCopyInto(&out.DriverTemplate)
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make([]DriverProfileParameter, len(*in))
+		copy(*out, *in)
+	}
+	if in.LivenessProbePorts != nil {
+		in, out := &in.LivenessProbePorts, &out.LivenessProbePorts
+		*out = new(DriverProfilePorts)
+		**out = **in
+	}
+	if in.MetricsPorts != nil {
+		in, out := &in.MetricsPorts, &out.MetricsPorts
+		*out = new(DriverProfilePorts)
+		**out = **in
+	}
+	if in.StorageClasses != nil {
+		in, out := &in.StorageClasses, &out.StorageClasses
+		*out = make([]storagev1.StorageClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverProfileSpec.
+func (in *DriverProfileSpec) DeepCopy() *DriverProfileSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverProfileSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverProfileVersion) DeepCopyInto(out *DriverProfileVersion) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverProfileVersion.
+func (in *DriverProfileVersion) DeepCopy() *DriverProfileVersion {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverProfileVersion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Generation) DeepCopyInto(out *Generation) {
 	*out = *in
@@ -319,3 +1121,165 @@ func (in *Generation) DeepCopy() *Generation {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageProfile) DeepCopyInto(out *StorageProfile) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageProfile.
+func (in *StorageProfile) DeepCopy() *StorageProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *StorageProfile) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageProfileList) DeepCopyInto(out *StorageProfileList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]StorageProfile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageProfileList.
+func (in *StorageProfileList) DeepCopy() *StorageProfileList {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageProfileList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *StorageProfileList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageProfileSpec) DeepCopyInto(out *StorageProfileSpec) {
+	*out = *in
+	out.CSI = in.CSI
+	if in.StorageClasses != nil {
+		in, out := &in.StorageClasses, &out.StorageClasses
+		*out = make([]CSIStorageClassTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeSnapshotClasses != nil {
+		in, out := &in.VolumeSnapshotClasses, &out.VolumeSnapshotClasses
+		*out = make([]VolumeSnapshotClassTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageProfileSpec.
+func (in *StorageProfileSpec) DeepCopy() *StorageProfileSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageProfileSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageProfileStatus) DeepCopyInto(out *StorageProfileStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]CSICondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StorageClasses != nil {
+		in, out := &in.StorageClasses, &out.StorageClasses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.VolumeSnapshotClasses != nil {
+		in, out := &in.VolumeSnapshotClasses, &out.VolumeSnapshotClasses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageProfileStatus.
+func (in *StorageProfileStatus) DeepCopy() *StorageProfileStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageProfileStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotClassTemplate) DeepCopyInto(out *VolumeSnapshotClassTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotClassTemplate.
+func (in *VolumeSnapshotClassTemplate) DeepCopy() *VolumeSnapshotClassTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotClassTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -19,6 +19,7 @@ package config
 
 import (
 	"flag"
+	"time"
 )
 
 // Config is a bunch of global configurable parameters.
@@ -33,6 +34,10 @@ type Config struct {
 	Filesystems string
 	// NeedDefaultSC indicates whether the cluster need to create default storage classes.
 	NeedDefaultSc bool
+	// Path to the version catalog file overriding the built-in images of the well known drivers.
+	VersionCatalog string
+	// Period to reload the version catalog file.
+	VersionCatalogPeriod time.Duration
 }
 
 // AddFlags add the configurations to global flag.
@@ -45,6 +50,10 @@ func (config *Config) AddFlags() {
 		"xfs,ext4", "Supported file systems for well known block volumes")
 	flag.BoolVar(&config.NeedDefaultSc, "need-default-sc", true,
 		"NeedDefaultSC indicates whether the cluster need to create default storage classes")
+	flag.StringVar(&config.VersionCatalog, "version-catalog", "",
+		"Path to the version catalog file overriding the built-in images of the well known drivers")
+	flag.DurationVar(&config.VersionCatalogPeriod, "version-catalog-period", time.Minute,
+		"Period to reload the version catalog file")
 	config.CephConfig.AddFlags()
 	config.TencentCloudConfig.AddFlags()
 }
@@ -0,0 +1,218 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"fmt"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/types"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+)
+
+// adoptionCandidate is an object which may be adopted by a CSI.
+type adoptionCandidate struct {
+	kind string
+	key  k8stypes.NamespacedName
+	obj  runtime.Object
+	// replace means the object is deleted after adopted instead of being updated.
+	replace bool
+}
+
+// adoptObjects claims the objects installed without the operator, so that the following
+// syncs update them instead of failing to create them.
+func (r *ReconcileCSI) adoptObjects(csiDeploy *csiv1.CSI) error {
+	if csiDeploy.Spec.Adoption == nil {
+		return nil
+	}
+
+	var errs types.ErrorList
+	for _, candidate := range r.adoptionCandidates(csiDeploy) {
+		if err := r.adoptCandidate(csiDeploy, candidate); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// adoptionCandidates returns all objects which may be adopted by a CSI.
+func (r *ReconcileCSI) adoptionCandidates(csiDeploy *csiv1.CSI) []adoptionCandidate {
+	var candidates []adoptionCandidate
+	// StorageClasses are not synced if not needed, adopting them would get them deleted.
+	for i := 0; r.config.NeedDefaultSc && i < len(csiDeploy.Spec.StorageClasses); i++ {
+		sc := &csiDeploy.Spec.StorageClasses[i]
+		candidates = append(candidates, adoptionCandidate{
+			kind: "StorageClass",
+			key:  k8stypes.NamespacedName{Name: sc.Name},
+			obj:  &storagev1.StorageClass{},
+		})
+	}
+	for _, secret := range csiDeploy.Spec.Secrets {
+		candidates = append(candidates, adoptionCandidate{
+			kind: "Secret",
+			key:  k8stypes.NamespacedName{Namespace: secret.Namespace, Name: secret.Name},
+			obj:  &corev1.Secret{},
+		})
+	}
+	for _, configMap := range csiDeploy.Spec.ConfigMaps {
+		candidates = append(candidates, adoptionCandidate{
+			kind: "ConfigMap",
+			key:  k8stypes.NamespacedName{Namespace: configMap.Namespace, Name: configMap.Name},
+			obj:  &corev1.ConfigMap{},
+		})
+	}
+	candidates = append(candidates, adoptionCandidate{
+		kind: "CSIDriver",
+		key:  k8stypes.NamespacedName{Name: csiDeploy.Spec.DriverName},
+		obj:  &storagev1beta1.CSIDriver{},
+	})
+	// The replaced workloads are deleted before the generated ones are created, as both would serve
+	// the same driver socket on the nodes. Provisioning and mounting stop until the new pods are ready.
+	for _, name := range append(csiDeploy.Spec.Adoption.DaemonSets, csiDeploy.Name+"-"+nodeComponent) {
+		candidates = append(candidates, adoptionCandidate{
+			kind:    "DaemonSet",
+			key:     k8stypes.NamespacedName{Namespace: csiDeploy.Namespace, Name: name},
+			obj:     &appsv1.DaemonSet{},
+			replace: true,
+		})
+	}
+	for _, name := range append(csiDeploy.Spec.Adoption.Deployments, csiDeploy.Name+"-"+controllerComponent) {
+		candidates = append(candidates, adoptionCandidate{
+			kind:    "Deployment",
+			key:     k8stypes.NamespacedName{Namespace: csiDeploy.Namespace, Name: name},
+			obj:     &appsv1.Deployment{},
+			replace: true,
+		})
+	}
+	return candidates
+}
+
+// adoptCandidate adopts a single object if it exists and is not managed by any CSI.
+func (r *ReconcileCSI) adoptCandidate(csiDeploy *csiv1.CSI, candidate adoptionCandidate) error {
+	if err := r.getObject(candidate.key, candidate.obj); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("get %s %s failed: %s", candidate.kind, candidate.key.String(), err.Error())
+	}
+	object, err := meta.Accessor(candidate.obj)
+	if err != nil {
+		return err
+	}
+
+	if isOwnedBy(object, csiDeploy) {
+		return nil
+	}
+	if owner := otherOwner(object, csiDeploy); owner != "" {
+		message := fmt.Sprintf("Refuse to adopt %s %s owned by %s", candidate.kind, candidate.key.String(), owner)
+		r.recorder.Event(csiDeploy, corev1.EventTypeWarning, types.AdoptionRefused, message)
+		return fmt.Errorf("%s", message)
+	}
+
+	if candidate.replace {
+		if err := r.deleteObject(candidate.obj); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("delete %s %s failed: %s", candidate.kind, candidate.key.String(), err.Error())
+		}
+	} else {
+		objLabels := object.GetLabels()
+		if objLabels == nil {
+			objLabels = make(map[string]string)
+		}
+		delete(objLabels, retainedName)
+		delete(objLabels, retainedNamespace)
+		objLabels[ownerName] = csiDeploy.Name
+		objLabels[ownerNamespace] = csiDeploy.Namespace
+		object.SetLabels(objLabels)
+		if candidate.key.Namespace != "" {
+			object.SetOwnerReferences(append(object.GetOwnerReferences(), ownerReference(csiDeploy)...))
+		}
+		if err := r.updateObject(candidate.obj); err != nil {
+			return fmt.Errorf("adopt %s %s failed: %s", candidate.kind, candidate.key.String(), err.Error())
+		}
+	}
+
+	klog.Infof("%s %s adopted by %s/%s, replaced: %t", candidate.kind, candidate.key.String(),
+		csiDeploy.Namespace, csiDeploy.Name, candidate.replace)
+	r.recorder.Eventf(csiDeploy, corev1.EventTypeNormal, types.ObjectAdopted, "%s %s adopted",
+		candidate.kind, candidate.key.String())
+	recordAdopted(csiDeploy, csiv1.CSIAdoptedObject{
+		Kind:      candidate.kind,
+		Namespace: candidate.key.Namespace,
+		Name:      candidate.key.Name,
+		Replaced:  candidate.replace,
+	})
+	return nil
+}
+
+// isOwnedBy returns true if an object is already managed by the CSI.
+func isOwnedBy(object metav1.Object, csiDeploy *csiv1.CSI) bool {
+	objLabels := object.GetLabels()
+	if objLabels[ownerName] == csiDeploy.Name && objLabels[ownerNamespace] == csiDeploy.Namespace {
+		return true
+	}
+	for _, ref := range object.GetOwnerReferences() {
+		if ref.UID == csiDeploy.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// otherOwner returns the CSI managing or retaining an object, or the controller owning it,
+// or an empty string if the object is not managed by any of them.
+func otherOwner(object metav1.Object, csiDeploy *csiv1.CSI) string {
+	objLabels := object.GetLabels()
+	if name, found := objLabels[ownerName]; found {
+		return objLabels[ownerNamespace] + "/" + name
+	}
+	if name, found := objLabels[retainedName]; found &&
+		(name != csiDeploy.Name || objLabels[retainedNamespace] != csiDeploy.Namespace) {
+		return objLabels[retainedNamespace] + "/" + name
+	}
+	// An object has at most one controller reference, so it can not be claimed by the CSI.
+	for _, ref := range object.GetOwnerReferences() {
+		if ref.Controller != nil && *ref.Controller {
+			return ref.Kind + " " + ref.Name
+		}
+	}
+	return ""
+}
+
+// recordAdopted records an adopted object in the status of CSI.
+func recordAdopted(csiDeploy *csiv1.CSI, adopted csiv1.CSIAdoptedObject) {
+	for _, exist := range csiDeploy.Status.Adopted {
+		if exist == adopted {
+			return
+		}
+	}
+	csiDeploy.Status.Adopted = append(csiDeploy.Status.Adopted, adopted)
+}
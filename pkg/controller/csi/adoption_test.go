@@ -0,0 +1,175 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"reflect"
+	"testing"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/config"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAdoptObjects(t *testing.T) {
+	isController := true
+	csiDeploy := &csiv1.CSI{
+		ObjectMeta: metav1.ObjectMeta{Name: "rbd", Namespace: "kube-system", UID: "uid"},
+		Spec: csiv1.CSISpec{
+			DriverName:     "csi-rbd",
+			StorageClasses: []storagev1.StorageClass{{ObjectMeta: metav1.ObjectMeta{Name: "rbd"}}},
+			Secrets:        []corev1.Secret{{ObjectMeta: metav1.ObjectMeta{Name: "rbd", Namespace: "kube-system"}}},
+			Adoption:       &csiv1.CSIAdoption{DaemonSets: []string{"csi-rbdplugin"}},
+		},
+	}
+	ownerLabels := map[string]string{ownerName: "rbd", ownerNamespace: "kube-system"}
+
+	testCases := []struct {
+		name           string
+		objects        []runtime.Object
+		expectErr      bool
+		expectAdopted  []csiv1.CSIAdoptedObject
+		expectLabeled  []runtime.Object
+		expectNotFound []runtime.Object
+	}{
+		{
+			name: "nothing to adopt",
+		},
+		{
+			name: "adopt objects installed by hand",
+			objects: []runtime.Object{
+				&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "rbd"}},
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "rbd", Namespace: "kube-system"}},
+				&storagev1beta1.CSIDriver{ObjectMeta: metav1.ObjectMeta{Name: "csi-rbd"}},
+			},
+			expectAdopted: []csiv1.CSIAdoptedObject{
+				{Kind: "StorageClass", Name: "rbd"},
+				{Kind: "Secret", Namespace: "kube-system", Name: "rbd"},
+				{Kind: "CSIDriver", Name: "csi-rbd"},
+			},
+			expectLabeled: []runtime.Object{
+				&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "rbd"}},
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "rbd", Namespace: "kube-system"}},
+				&storagev1beta1.CSIDriver{ObjectMeta: metav1.ObjectMeta{Name: "csi-rbd"}},
+			},
+		},
+		{
+			name: "replace workloads installed by hand",
+			objects: []runtime.Object{
+				&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "csi-rbdplugin", Namespace: "kube-system"}},
+				&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "kube-system"}},
+			},
+			expectAdopted: []csiv1.CSIAdoptedObject{
+				{Kind: "DaemonSet", Namespace: "kube-system", Name: "csi-rbdplugin", Replaced: true},
+			},
+			expectNotFound: []runtime.Object{
+				&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "csi-rbdplugin", Namespace: "kube-system"}},
+			},
+		},
+		{
+			name: "skip objects already owned",
+			objects: []runtime.Object{
+				&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "rbd", Labels: ownerLabels}},
+			},
+		},
+		{
+			name: "refuse objects of another CSI",
+			objects: []runtime.Object{
+				&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{
+					Name:   "rbd",
+					Labels: map[string]string{ownerName: "other", ownerNamespace: "kube-system"},
+				}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "refuse objects retained by another CSI",
+			objects: []runtime.Object{
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+					Name:      "rbd",
+					Namespace: "kube-system",
+					Labels:    map[string]string{retainedName: "other", retainedNamespace: "kube-system"},
+				}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "refuse workloads of another controller",
+			objects: []runtime.Object{
+				&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{
+					Name:      "csi-rbdplugin",
+					Namespace: "kube-system",
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: "Operator", Name: "rbd", UID: "other", Controller: &isController},
+					},
+				}},
+			},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		r := &ReconcileCSI{
+			client:   fake.NewFakeClientWithScheme(scheme.Scheme, tc.objects...),
+			config:   &config.Config{NeedDefaultSc: true},
+			recorder: record.NewFakeRecorder(10),
+		}
+		newCSIDeploy := csiDeploy.DeepCopy()
+		err := r.adoptObjects(newCSIDeploy)
+		if (err != nil) != tc.expectErr {
+			t.Errorf("case %d(%s): expect error %t, got %v", i, tc.name, tc.expectErr, err)
+		}
+		if !reflect.DeepEqual(newCSIDeploy.Status.Adopted, tc.expectAdopted) {
+			t.Errorf("case %d(%s): expect adopted %+v, got %+v",
+				i, tc.name, tc.expectAdopted, newCSIDeploy.Status.Adopted)
+		}
+		for _, obj := range tc.expectLabeled {
+			object := obj.(metav1.Object)
+			key := k8stypes.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+			if err := r.getObject(key, obj); err != nil {
+				t.Errorf("case %d(%s): get %s failed: %v", i, tc.name, key, err)
+				continue
+			}
+			if !isOwnedBy(object, newCSIDeploy) {
+				t.Errorf("case %d(%s): %s not adopted, labels %v", i, tc.name, key, object.GetLabels())
+			}
+			if len(object.GetNamespace()) > 0 && len(object.GetOwnerReferences()) != 1 {
+				t.Errorf("case %d(%s): expect owner reference of %s, got %+v",
+					i, tc.name, key, object.GetOwnerReferences())
+			}
+		}
+		for _, obj := range tc.expectNotFound {
+			object := obj.(metav1.Object)
+			key := k8stypes.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+			if err := r.getObject(key, obj); !errors.IsNotFound(err) {
+				t.Errorf("case %d(%s): expect %s deleted, got %v", i, tc.name, key, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"fmt"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/config"
+	"tkestack.io/csi-operator/pkg/controller/csi/enhancer"
+	"tkestack.io/csi-operator/pkg/controller/util"
+
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// watchCatalog loads the version catalog, and reconciles all CSI objects each time it is reloaded.
+func watchCatalog(mgr manager.Manager, c controller.Controller, cfg *config.Config) error {
+	if len(cfg.VersionCatalog) == 0 {
+		return nil
+	}
+	if _, err := enhancer.LoadCatalog(cfg.VersionCatalog); err != nil {
+		return fmt.Errorf("load version catalog failed: %s", err.Error())
+	}
+
+	events := make(chan event.GenericEvent)
+	if err := c.Watch(&source.Channel{Source: events}, &handler.EnqueueRequestForObject{}); err != nil {
+		return err
+	}
+	return mgr.Add(manager.RunnableFunc(func(stopCh <-chan struct{}) error {
+		enhancer.WatchCatalog(cfg.VersionCatalog, cfg.VersionCatalogPeriod, stopCh, func() {
+			enqueueCSIObjects(mgr.GetClient(), events, stopCh)
+		})
+		return nil
+	}))
+}
+
+// enqueueCSIObjects sends an event for each CSI object.
+func enqueueCSIObjects(c client.Client, events chan<- event.GenericEvent, stopCh <-chan struct{}) {
+	ctx, cancel := util.GetContext()
+	defer cancel()
+	csiList := &csiv1.CSIList{}
+	if err := c.List(ctx, csiList); err != nil {
+		klog.Errorf("List CSI objects failed: %v", err)
+		return
+	}
+
+	for i := range csiList.Items {
+		csiDeploy := &csiList.Items[i]
+		select {
+		case events <- event.GenericEvent{Meta: csiDeploy, Object: csiDeploy}:
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -19,43 +19,14 @@ package csi
 
 import (
 	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/util"
 
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // updateCondition updates a CSI object's condition.
 func updateCondition(
 	csiDeploy *csiv1.CSI,
 	typ, message string, status corev1.ConditionStatus) {
-	exist := findCondition(csiDeploy.Status.Conditions, typ)
-	if exist == nil {
-		csiDeploy.Status.Conditions = append(csiDeploy.Status.Conditions, generateCondition(typ, message, status))
-	} else {
-		exist.Message = message
-		if exist.Status != status {
-			exist.Status = status
-			exist.LastTransitionTime = metav1.Now()
-		}
-	}
-}
-
-// findCondition returns a specific condition from a set of conditions.
-func findCondition(conditions []csiv1.CSICondition, typ string) *csiv1.CSICondition {
-	for i := range conditions {
-		if conditions[i].Type == typ {
-			return &conditions[i]
-		}
-	}
-	return nil
-}
-
-// generateCondition is an utility function to create a condition.
-func generateCondition(typ, message string, status corev1.ConditionStatus) csiv1.CSICondition {
-	return csiv1.CSICondition{
-		Type:               typ,
-		Status:             status,
-		Message:            message,
-		LastTransitionTime: metav1.Now(),
-	}
+	util.UpdateCondition(&csiDeploy.Status.Conditions, typ, message, status)
 }
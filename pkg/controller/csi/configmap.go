@@ -25,6 +25,7 @@ import (
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/util"
 	"tkestack.io/csi-operator/pkg/types"
 )
 
@@ -91,7 +92,7 @@ func (r *ReconcileCSI) syncConfigMap(
 	updateObj.TypeMeta = exist.TypeMeta
 	updateObj.ObjectMeta = exist.ObjectMeta
 	updated := !equality.Semantic.DeepEqual(updateObj, exist)
-	if mergeObjectMeta(&configMap.ObjectMeta, &updateObj.ObjectMeta) {
+	if util.MergeObjectMeta(&configMap.ObjectMeta, &updateObj.ObjectMeta) {
 		updated = true
 	}
 	if updated {
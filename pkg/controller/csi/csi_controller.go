@@ -19,6 +19,8 @@ package csi
 
 import (
 	"fmt"
+	"time"
+
 	"k8s.io/apimachinery/pkg/api/equality"
 
 	"tkestack.io/csi-operator/pkg/controller/csi/enhancer"
@@ -31,6 +33,7 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -45,7 +48,7 @@ import (
 // Add creates a new CSI Controller and adds it to the Manager with default RBAC.
 // The Manager will set fields on the Controller and Start it when the Manager is Started.
 func Add(mgr manager.Manager, cfg *config.Config) error {
-	return add(mgr, newReconciler(mgr, cfg))
+	return add(mgr, newReconciler(mgr, cfg), cfg)
 }
 
 // newReconciler returns a new reconcile.Reconciler
@@ -54,12 +57,13 @@ func newReconciler(mgr manager.Manager, cfg *config.Config) reconcile.Reconciler
 		client:   mgr.GetClient(),
 		config:   cfg,
 		recorder: mgr.GetEventRecorderFor("csi-operator"),
-		enhancer: enhancer.New(cfg),
+		mapper:   mgr.GetRESTMapper(),
+		enhancer: enhancer.New(cfg, mgr.GetClient()),
 	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+func add(mgr manager.Manager, r reconcile.Reconciler, cfg *config.Config) error {
 	// Create a new controller
 	c, err := controller.New("csi-controller", mgr, controller.Options{Reconciler: r})
 	if err != nil {
@@ -90,6 +94,12 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for Services which expose the metrics endpoints.
+	err = c.Watch(&source.Kind{Type: &corev1.Service{}}, ownerRefHandler)
+	if err != nil {
+		return err
+	}
+
 	// Watch for Secrets which used to operate the CSI volume.
 	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, ownerRefHandler)
 	if err != nil {
@@ -102,6 +112,17 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for CSI objects and StorageClasses which affect the default StorageClass of other CSI objects.
+	defaultSCHandler := newDefaultStorageClassHandler(mgr.GetClient())
+	err = c.Watch(&source.Kind{Type: &csiv1.CSI{}}, defaultSCHandler)
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &storagev1.StorageClass{}}, defaultSCHandler)
+	if err != nil {
+		return err
+	}
+
 	// Watch for RBAC related objects.
 	err = c.Watch(&source.Kind{Type: &corev1.ServiceAccount{}}, ownerRefHandler)
 	if err != nil {
@@ -116,7 +137,72 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
-	return nil
+	// Watch for Pods of node drivers and controller drivers to report failing containers.
+	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, newDriverPodHandler())
+	if err != nil {
+		return err
+	}
+
+	// Watch for CSINodes which reflect the registration of drivers on each node.
+	err = c.Watch(&source.Kind{Type: &storagev1.CSINode{}}, newDriverNameHandler(mgr.GetClient(), csiNodeDriverNames))
+	if err != nil {
+		return err
+	}
+
+	// Watch for PersistentVolumes and VolumeAttachments which block the deletion of CSI objects.
+	err = c.Watch(&source.Kind{Type: &corev1.PersistentVolume{}},
+		newDriverNameHandler(mgr.GetClient(), persistentVolumeDriverNames))
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &storagev1.VolumeAttachment{}},
+		newDriverNameHandler(mgr.GetClient(), volumeAttachmentDriverNames))
+	if err != nil {
+		return err
+	}
+
+	// Watch for Secrets and ConfigMaps read by the enhancer, so that the objects copied from them are updated.
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, newReferenceHandler(mgr.GetClient(), enhancer.ReferredSecrets))
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}},
+		newReferenceHandler(mgr.GetClient(), enhancer.ReferredConfigMaps))
+	if err != nil {
+		return err
+	}
+
+	// Watch for DriverProfiles which describe the drivers of CSI objects.
+	err = c.Watch(&source.Kind{Type: &csiv1.DriverProfile{}},
+		newDriverNameHandler(mgr.GetClient(), driverProfileDriverNames))
+	if err != nil {
+		return err
+	}
+
+	// Watch for the version catalog which overrides the images of well known drivers.
+	return watchCatalog(mgr, c, cfg)
+}
+
+// driverProfileDriverNames returns the name of the driver described by a DriverProfile.
+func driverProfileDriverNames(object handler.MapObject) []string {
+	profile, ok := object.Object.(*csiv1.DriverProfile)
+	if !ok {
+		return nil
+	}
+	return []string{profile.Spec.DriverName}
+}
+
+// csiNodeDriverNames returns the names of drivers registered in a CSINode object.
+func csiNodeDriverNames(object handler.MapObject) []string {
+	csiNode, ok := object.Object.(*storagev1.CSINode)
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(csiNode.Spec.Drivers))
+	for _, driver := range csiNode.Spec.Drivers {
+		names = append(names, driver.Name)
+	}
+	return names
 }
 
 var _ reconcile.Reconciler = &ReconcileCSI{}
@@ -127,8 +213,9 @@ type ReconcileCSI struct {
 
 	config   *config.Config
 	recorder record.EventRecorder
+	mapper   meta.RESTMapper
 
-	enhancer enhancer.Enhancer
+	enhancer enhancer.Interface
 }
 
 // Reconcile reads that state of the cluster for a CSI object and makes changes based on the state read
@@ -140,6 +227,7 @@ func (r *ReconcileCSI) Reconcile(request reconcile.Request) (reconcile.Result, e
 		if errors.IsNotFound(err) {
 			// Object not found, return.  Created objects are automatically garbage collected.
 			// For additional cleanup logic use finalizers.
+			forgetCSIMetrics(request.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object, record the event and requeue the request.
@@ -173,6 +261,7 @@ func (r *ReconcileCSI) handle(csiDeploy *csiv1.CSI) error {
 	if errToRecord != nil {
 		r.recorder.Event(csiDeploy, corev1.EventTypeWarning, types.SyncError, errToRecord.Error())
 	}
+	recordStatusMetrics(newCSIDeploy)
 
 	err := r.updateCSIStatus(csiDeploy, newCSIDeploy)
 	if err != nil {
@@ -185,8 +274,19 @@ func (r *ReconcileCSI) handle(csiDeploy *csiv1.CSI) error {
 // - StorageClass
 // - ClusterRole
 // - ClusterRoleBinding
+// StorageClasses and Secrets are released instead if their deletion policy is Orphan or Retain.
 // And remove the csiDeploymentFinalizer of CSI.
+// Nothing is deleted while volumes of the driver still exist.
 func (r *ReconcileCSI) clearCSIDeployment(csiDeploy *csiv1.CSI) error {
+	released, err := r.checkVolumesReleased(csiDeploy)
+	if err != nil {
+		return err
+	}
+	if !released {
+		klog.V(4).Infof("Deletion of %s/%s is blocked by volumes", csiDeploy.Namespace, csiDeploy.Name)
+		return nil
+	}
+
 	var errs types.ErrorList
 	klog.Infof("Clear %s/%s", csiDeploy.Namespace, csiDeploy.Name)
 
@@ -194,6 +294,10 @@ func (r *ReconcileCSI) clearCSIDeployment(csiDeploy *csiv1.CSI) error {
 		errs = append(errs, err)
 	}
 
+	if err := r.clearSecrets(csiDeploy); err != nil {
+		errs = append(errs, err)
+	}
+
 	if err := r.clearRBACObjects(csiDeploy); err != nil {
 		errs = append(errs, err)
 	}
@@ -223,28 +327,48 @@ func (r *ReconcileCSI) syncCSI(csiDeploy *csiv1.CSI) error {
 		syncCSIStatus(csiDeploy, nil, nil, err)
 		return err
 	}
+	csiDeploy.Status.Catalog = r.enhancer.CatalogStatus(csiDeploy)
 
 	var errs types.ErrorList
 
-	if updated, err := r.syncRBACObjects(csiDeploy); err != nil {
+	start := time.Now()
+	err := r.adoptObjects(csiDeploy)
+	observeStage(stageAdoption, start, err)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	start = time.Now()
+	updated, err := r.syncRBACObjects(csiDeploy)
+	observeStage(stageRBAC, start, err)
+	if err != nil {
 		errs = append(errs, err)
 	} else if updated {
 		r.recorder.Event(csiDeploy, corev1.EventTypeNormal, types.RBACSynced, "RBAC resources has been synced")
 	}
 
-	if updated, err := r.syncSecrets(csiDeploy); err != nil {
+	start = time.Now()
+	updated, err = r.syncSecrets(csiDeploy)
+	observeStage(stageSecrets, start, err)
+	if err != nil {
 		errs = append(errs, err)
 	} else if updated {
 		r.recorder.Event(csiDeploy, corev1.EventTypeNormal, types.SecretsSynced, "Secrets has been synced")
 	}
 
-	if updated, err := r.syncStorageClasses(csiDeploy); err != nil {
+	start = time.Now()
+	updated, err = r.syncStorageClasses(csiDeploy)
+	observeStage(stageStorageClasses, start, err)
+	if err != nil {
 		errs = append(errs, err)
 	} else if updated {
 		r.recorder.Event(csiDeploy, corev1.EventTypeNormal, types.StorageClassesSynced, "StorageClasses has been synced")
 	}
 
-	if updated, err := r.syncConfigMaps(csiDeploy); err != nil {
+	start = time.Now()
+	updated, err = r.syncConfigMaps(csiDeploy)
+	observeStage(stageConfigMaps, start, err)
+	if err != nil {
 		errs = append(errs, err)
 	} else if updated {
 		r.recorder.Event(csiDeploy, corev1.EventTypeNormal, types.ConfigMapsSynced, "ConfigMaps have been synced")
@@ -252,7 +376,9 @@ func (r *ReconcileCSI) syncCSI(csiDeploy *csiv1.CSI) error {
 
 	var children []csiv1.Generation
 
+	start = time.Now()
 	nodeDriver, updated, nodeSyncErr := r.syncNodeDriver(csiDeploy)
+	observeStage(stageNode, start, nodeSyncErr)
 	if nodeSyncErr != nil {
 		errs = append(errs, nodeSyncErr)
 	} else {
@@ -268,9 +394,14 @@ func (r *ReconcileCSI) syncCSI(csiDeploy *csiv1.CSI) error {
 		if updated {
 			r.recorder.Event(csiDeploy, corev1.EventTypeNormal, types.NodeDriverSynced, "Node Drivers has been synced")
 		}
+		if err := r.syncNodeRegistration(csiDeploy, nodeDriver); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
+	start = time.Now()
 	controllerDriver, updated, ctrlSyncErr := r.syncControllerDriver(csiDeploy)
+	observeStage(stageController, start, ctrlSyncErr)
 	if ctrlSyncErr != nil {
 		errs = append(errs, ctrlSyncErr)
 	} else {
@@ -289,15 +420,29 @@ func (r *ReconcileCSI) syncCSI(csiDeploy *csiv1.CSI) error {
 		}
 	}
 
-	var err error
+	start = time.Now()
+	updated, err = r.syncMetricsServices(csiDeploy)
+	observeStage(stageMetrics, start, err)
+	if err != nil {
+		errs = append(errs, err)
+	} else if updated {
+		r.recorder.Event(csiDeploy, corev1.EventTypeNormal, types.MetricsServicesSynced, "Metrics Services have been synced")
+	}
+
+	if err := r.syncContainerFailures(csiDeploy); err != nil {
+		errs = append(errs, err)
+	}
+
+	var syncErr error
 	if len(errs) > 0 {
-		err = errs
+		syncErr = errs
 	}
 
+	recordWorkloadMetrics(csiDeploy, nodeDriver, controllerDriver)
 	csiDeploy.Status.Children = children
-	syncCSIStatus(csiDeploy, nodeDriver, controllerDriver, err)
+	syncCSIStatus(csiDeploy, nodeDriver, controllerDriver, syncErr)
 
-	return err
+	return syncErr
 }
 
 // enhance enhances a CSI object for well known CSI.
@@ -310,6 +455,7 @@ func (r *ReconcileCSI) enhance(csiDeploy *csiv1.CSI) error {
 	tmpCSI := csiDeploy.DeepCopy()
 
 	if err := r.enhancer.Enhance(tmpCSI); err != nil {
+		enhanceFailures.WithLabelValues(csiDeploy.Spec.DriverName).Inc()
 		return newNoNeedRetryError(fmt.Sprintf("enhance failed: %v", err))
 	}
 	if equality.Semantic.DeepEqual(tmpCSI.Spec, csiDeploy.Spec) {
@@ -0,0 +1,126 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/types"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxReportedNodes limits the number of nodes listed in the status of a CSI object,
+// so that the status stays small in large clusters.
+const maxReportedNodes = 50
+
+// syncNodeRegistration reports the registration of the driver on the nodes
+// running the node driver, based on the CSINode objects maintained by kubelet.
+func (r *ReconcileCSI) syncNodeRegistration(csiDeploy *csiv1.CSI, nodeDriver *appsv1.DaemonSet) error {
+	if nodeDriver == nil {
+		csiDeploy.Status.NodeRegistration = nil
+		updateCondition(csiDeploy, types.NodeRegistered, "", corev1.ConditionUnknown)
+		return nil
+	}
+
+	pods := &corev1.PodList{}
+	err := r.listObjects(pods, &client.ListOptions{
+		Namespace:     csiDeploy.Namespace,
+		LabelSelector: labels.SelectorFromSet(nodeDriver.Spec.Selector.MatchLabels),
+	})
+	if err != nil {
+		return fmt.Errorf("list node driver pods failed: %s", err.Error())
+	}
+
+	csiNodes := &storagev1.CSINodeList{}
+	if err := r.listObjects(csiNodes, &client.ListOptions{}); err != nil {
+		return fmt.Errorf("list CSINodes failed: %s", err.Error())
+	}
+
+	registered := make(map[string]*storagev1.CSINodeDriver, len(csiNodes.Items))
+	for i := range csiNodes.Items {
+		csiNode := &csiNodes.Items[i]
+		for j := range csiNode.Spec.Drivers {
+			if csiNode.Spec.Drivers[j].Name == csiDeploy.Spec.DriverName {
+				registered[csiNode.Name] = &csiNode.Spec.Drivers[j]
+				break
+			}
+		}
+	}
+
+	var missing []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		if _, exist := registered[pod.Spec.NodeName]; !exist {
+			missing = append(missing, pod.Spec.NodeName)
+		}
+	}
+	sort.Strings(missing)
+
+	nodeNames := make([]string, 0, len(registered))
+	for name := range registered {
+		nodeNames = append(nodeNames, name)
+	}
+	sort.Strings(nodeNames)
+
+	registration := &csiv1.CSINodeRegistration{
+		DesiredNodes:    nodeDriver.Status.DesiredNumberScheduled,
+		RegisteredNodes: int32(len(registered)),
+	}
+	for i, name := range missing {
+		if i >= maxReportedNodes {
+			break
+		}
+		registration.MissingNodes = append(registration.MissingNodes, name)
+	}
+	for i, name := range nodeNames {
+		if i >= maxReportedNodes {
+			break
+		}
+		driver := registered[name]
+		info := csiv1.CSINodeDriverInfo{
+			Name:         name,
+			TopologyKeys: driver.TopologyKeys,
+		}
+		if driver.Allocatable != nil && driver.Allocatable.Count != nil {
+			count := *driver.Allocatable.Count
+			info.Allocatable = &count
+		}
+		registration.Nodes = append(registration.Nodes, info)
+	}
+	csiDeploy.Status.NodeRegistration = registration
+
+	message, status := "", corev1.ConditionTrue
+	if len(missing) > 0 {
+		message, status = fmt.Sprintf("Driver is not registered on %d node(s) running the node driver: %s",
+			len(missing), strings.Join(registration.MissingNodes, ",")), corev1.ConditionFalse
+	}
+	updateCondition(csiDeploy, types.NodeRegistered, message, status)
+
+	return nil
+}
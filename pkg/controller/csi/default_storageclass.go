@@ -0,0 +1,301 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/util"
+	"tkestack.io/csi-operator/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	isDefaultClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaIsDefaultClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+	// defaultClassManagedAnnotation marks the StorageClasses whose default annotations are set by the operator.
+	defaultClassManagedAnnotation = "storage.tkestack.io/default-class-managed"
+)
+
+// resolveDefaultStorageClass returns the name of the StorageClass to be marked as default by the CSI,
+// or an empty string if the CSI should not mark any StorageClass as default. managed is true if any CSI
+// names a default StorageClass, then the StorageClasses of all CSI objects are marked as default or not.
+func (r *ReconcileCSI) resolveDefaultStorageClass(csiDeploy *csiv1.CSI) (name string, managed bool, err error) {
+	csiList := &csiv1.CSIList{}
+	if err := r.listObjects(csiList, &client.ListOptions{}); err != nil {
+		return "", false, fmt.Errorf("list CSI objects failed: %s", err.Error())
+	}
+	winner := defaultStorageClassOwner(csiList.Items)
+	managed = winner != nil
+
+	name = csiDeploy.Spec.DefaultStorageClass
+	if name == "" {
+		if util.FindCondition(csiDeploy.Status.Conditions, types.DefaultStorageClass) != nil {
+			updateCondition(csiDeploy, types.DefaultStorageClass, "No default StorageClass named", corev1.ConditionFalse)
+		}
+		return "", managed, nil
+	}
+	if !hasStorageClass(csiDeploy, name) {
+		updateCondition(csiDeploy, types.DefaultStorageClass,
+			fmt.Sprintf("StorageClass %s is not generated by the CSI", name), corev1.ConditionFalse)
+		return "", managed, nil
+	}
+	if winner != nil && !isSameCSI(winner, csiDeploy) {
+		updateCondition(csiDeploy, types.DefaultStorageClass,
+			fmt.Sprintf("Default StorageClass %s of %s/%s takes effect",
+				winner.Spec.DefaultStorageClass, winner.Namespace, winner.Name), corev1.ConditionFalse)
+		return "", managed, nil
+	}
+
+	storageClasses := &storagev1.StorageClassList{}
+	if err := r.listObjects(storageClasses, &client.ListOptions{}); err != nil {
+		return "", false, fmt.Errorf("list StorageClasses failed: %s", err.Error())
+	}
+	var others []string
+	for i := range storageClasses.Items {
+		sc := &storageClasses.Items[i]
+		if sc.Name == name || !isDefaultStorageClass(sc) {
+			continue
+		}
+		if _, owned := sc.Labels[ownerName]; !owned {
+			others = append(others, sc.Name)
+		}
+	}
+	if len(others) > 0 {
+		sort.Strings(others)
+		updateCondition(csiDeploy, types.DefaultStorageClass,
+			fmt.Sprintf("StorageClasses not managed by the operator are also marked as default: %s",
+				strings.Join(others, ", ")), corev1.ConditionFalse)
+	} else {
+		updateCondition(csiDeploy, types.DefaultStorageClass, "", corev1.ConditionTrue)
+	}
+	return name, true, nil
+}
+
+// defaultStorageClassOwner returns the oldest CSI object naming a default StorageClass.
+func defaultStorageClassOwner(csiDeploys []csiv1.CSI) *csiv1.CSI {
+	var owner *csiv1.CSI
+	for i := range csiDeploys {
+		csiDeploy := &csiDeploys[i]
+		if csiDeploy.Spec.DefaultStorageClass == "" || isTerminating(csiDeploy) {
+			continue
+		}
+		if owner == nil || csiDeploy.CreationTimestamp.Before(&owner.CreationTimestamp) ||
+			(csiDeploy.CreationTimestamp.Equal(&owner.CreationTimestamp) &&
+				csiDeploy.Namespace+"/"+csiDeploy.Name < owner.Namespace+"/"+owner.Name) {
+			owner = csiDeploy
+		}
+	}
+	return owner
+}
+
+// setDefaultClassAnnotations marks a StorageClass as default or not, and records that the annotations
+// are set by the operator.
+func setDefaultClassAnnotations(sc *storagev1.StorageClass, isDefault bool) {
+	annotations := make(map[string]string, len(sc.Annotations)+3)
+	for k, v := range sc.Annotations {
+		annotations[k] = v
+	}
+	value := "false"
+	if isDefault {
+		value = "true"
+	}
+	annotations[isDefaultClassAnnotation] = value
+	annotations[betaIsDefaultClassAnnotation] = value
+	annotations[defaultClassManagedAnnotation] = "true"
+	sc.Annotations = annotations
+}
+
+// defaultClassManaged returns true if the default annotations of a StorageClass are set by the operator.
+func defaultClassManaged(sc *storagev1.StorageClass) bool {
+	return sc != nil && sc.Annotations[defaultClassManagedAnnotation] == "true"
+}
+
+// isDefaultStorageClass returns true if a StorageClass is marked as default.
+func isDefaultStorageClass(sc *storagev1.StorageClass) bool {
+	return sc.Annotations[isDefaultClassAnnotation] == "true" ||
+		sc.Annotations[betaIsDefaultClassAnnotation] == "true"
+}
+
+// defaultStorageClassHandler enqueues Requests for the CSI objects whose default StorageClass
+// is changed by an event of another CSI object or of a StorageClass not managed by the operator.
+type defaultStorageClassHandler struct {
+	client client.Client
+}
+
+var _ handler.EventHandler = &defaultStorageClassHandler{}
+
+// newDefaultStorageClassHandler creates a defaultStorageClassHandler.
+func newDefaultStorageClassHandler(c client.Client) handler.EventHandler {
+	return &defaultStorageClassHandler{client: c}
+}
+
+// Create implements handler.EventHandler.
+func (h *defaultStorageClassHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(nil, e.Object, q)
+}
+
+// Update implements handler.EventHandler.
+func (h *defaultStorageClassHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(e.ObjectOld, e.ObjectNew, q)
+}
+
+// Delete implements handler.EventHandler.
+func (h *defaultStorageClassHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(e.Object, nil, q)
+}
+
+// Generic implements handler.EventHandler.
+func (h *defaultStorageClassHandler) Generic(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+}
+
+// enqueue enqueues the CSI objects affected by the change of an object from oldObj to newObj.
+func (h *defaultStorageClassHandler) enqueue(oldObj, newObj runtime.Object, q workqueue.RateLimitingInterface) {
+	oldCSI, oldIsCSI := oldObj.(*csiv1.CSI)
+	newCSI, newIsCSI := newObj.(*csiv1.CSI)
+	oldSC, _ := oldObj.(*storagev1.StorageClass)
+	newSC, _ := newObj.(*storagev1.StorageClass)
+	if oldIsCSI || newIsCSI {
+		if namedDefault(oldCSI) == namedDefault(newCSI) {
+			return
+		}
+	} else if !isDefaultChanged(oldSC, newSC) {
+		return
+	}
+
+	ctx, cancel := util.GetContext()
+	defer cancel()
+	csiList := &csiv1.CSIList{}
+	if err := h.client.List(ctx, csiList); err != nil {
+		klog.Errorf("List CSI objects failed: %v", err)
+		return
+	}
+
+	var requests []reconcile.Request
+	if oldIsCSI || newIsCSI {
+		requests = defaultChoiceRequests(csiList.Items, oldCSI, newCSI)
+	} else if owner := defaultStorageClassOwner(csiList.Items); owner != nil {
+		// The owner reports the other StorageClasses marked as default.
+		requests = []reconcile.Request{csiRequest(owner)}
+	}
+	for _, request := range requests {
+		q.Add(request)
+	}
+}
+
+// namedDefault returns the default StorageClass named by a CSI object taking part in the choice
+// of the default StorageClass, or an empty string if it does not.
+func namedDefault(csiDeploy *csiv1.CSI) string {
+	if csiDeploy == nil || isTerminating(csiDeploy) {
+		return ""
+	}
+	return csiDeploy.Spec.DefaultStorageClass
+}
+
+// isDefaultChanged returns true if a StorageClass not managed by the operator is marked
+// or unmarked as default.
+func isDefaultChanged(oldSC, newSC *storagev1.StorageClass) bool {
+	isDefault := make([]bool, 0, 2)
+	for _, sc := range []*storagev1.StorageClass{oldSC, newSC} {
+		if sc == nil {
+			isDefault = append(isDefault, false)
+			continue
+		}
+		if _, owned := sc.Labels[ownerName]; owned {
+			return false
+		}
+		isDefault = append(isDefault, isDefaultStorageClass(sc))
+	}
+	return isDefault[0] != isDefault[1]
+}
+
+// defaultChoiceRequests returns the Requests of the CSI objects whose default StorageClass is changed,
+// when a CSI object changes from oldCSI to newCSI. The CSI object itself is enqueued by its own handler.
+// If the first default StorageClass is named or the last one is gone, all CSI objects mark their
+// StorageClasses, otherwise only the ones naming a default StorageClass are affected by a new owner.
+func defaultChoiceRequests(csiDeploys []csiv1.CSI, oldCSI, newCSI *csiv1.CSI) []reconcile.Request {
+	self := oldCSI
+	if self == nil {
+		self = newCSI
+	}
+	if self == nil {
+		return nil
+	}
+	oldOwner := defaultStorageClassOwner(replaceCSI(csiDeploys, self, oldCSI))
+	after := replaceCSI(csiDeploys, self, newCSI)
+	newOwner := defaultStorageClassOwner(after)
+	if (oldOwner == nil && newOwner == nil) ||
+		(oldOwner != nil && newOwner != nil && isSameCSI(oldOwner, newOwner)) {
+		return nil
+	}
+
+	flipped := oldOwner == nil || newOwner == nil
+	var requests []reconcile.Request
+	for i := range after {
+		csiDeploy := &after[i]
+		if isSameCSI(csiDeploy, self) {
+			continue
+		}
+		if flipped || csiDeploy.Spec.DefaultStorageClass != "" {
+			requests = append(requests, csiRequest(csiDeploy))
+		}
+	}
+	return requests
+}
+
+// replaceCSI returns a copy of csiDeploys with the CSI object of the same namespace and name
+// as self replaced by csiDeploy, or removed if csiDeploy is nil.
+func replaceCSI(csiDeploys []csiv1.CSI, self, csiDeploy *csiv1.CSI) []csiv1.CSI {
+	result := make([]csiv1.CSI, 0, len(csiDeploys)+1)
+	for i := range csiDeploys {
+		if !isSameCSI(&csiDeploys[i], self) {
+			result = append(result, csiDeploys[i])
+		}
+	}
+	if csiDeploy != nil {
+		result = append(result, *csiDeploy)
+	}
+	return result
+}
+
+// isSameCSI returns true if a and b have the same namespace and name.
+func isSameCSI(a, b *csiv1.CSI) bool {
+	return a.Namespace == b.Namespace && a.Name == b.Name
+}
+
+// csiRequest returns the Request of a CSI object.
+func csiRequest(csiDeploy *csiv1.CSI) reconcile.Request {
+	return reconcile.Request{
+		NamespacedName: k8stypes.NamespacedName{
+			Namespace: csiDeploy.Namespace,
+			Name:      csiDeploy.Name,
+		}}
+}
@@ -0,0 +1,237 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/config"
+
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func defaultCSI(name string, created time.Time, defaultSC string, scNames ...string) *csiv1.CSI {
+	csiDeploy := &csiv1.CSI{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system", CreationTimestamp: metav1.NewTime(created)},
+		Spec:       csiv1.CSISpec{DriverName: "csi-" + name, DefaultStorageClass: defaultSC},
+	}
+	for _, scName := range scNames {
+		csiDeploy.Spec.StorageClasses = append(csiDeploy.Spec.StorageClasses,
+			storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: scName}})
+	}
+	return csiDeploy
+}
+
+func ownedStorageClass(name, owner string, annotations map[string]string) *storagev1.StorageClass {
+	return &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{ownerName: owner, ownerNamespace: "kube-system"},
+			Annotations: annotations,
+		},
+		Provisioner: "csi-" + owner,
+	}
+}
+
+func TestSyncDefaultStorageClassAnnotations(t *testing.T) {
+	earlier := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+	markedDefault := func(value string) map[string]string {
+		return map[string]string{
+			isDefaultClassAnnotation:      value,
+			betaIsDefaultClassAnnotation:  value,
+			defaultClassManagedAnnotation: "true",
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		csiDeploy *csiv1.CSI
+		objects   []runtime.Object
+		expected  map[string]map[string]string
+	}{
+		{
+			name:      "no default named",
+			csiDeploy: defaultCSI("rbd", later, "", "a", "b"),
+			objects: []runtime.Object{
+				ownedStorageClass("a", "rbd", map[string]string{isDefaultClassAnnotation: "true"}),
+			},
+			expected: map[string]map[string]string{
+				"a": {isDefaultClassAnnotation: "true"},
+				"b": nil,
+			},
+		},
+		{
+			name:      "default set by the operator before",
+			csiDeploy: defaultCSI("rbd", later, "", "a", "b"),
+			objects: []runtime.Object{
+				ownedStorageClass("a", "rbd", markedDefault("true")),
+			},
+			expected: map[string]map[string]string{
+				"a": markedDefault("false"),
+				"b": nil,
+			},
+		},
+		{
+			name:      "default named by the CSI",
+			csiDeploy: defaultCSI("rbd", later, "b", "a", "b"),
+			objects: []runtime.Object{
+				ownedStorageClass("a", "rbd", map[string]string{isDefaultClassAnnotation: "true"}),
+			},
+			expected: map[string]map[string]string{
+				"a": markedDefault("false"),
+				"b": markedDefault("true"),
+			},
+		},
+		{
+			name:      "default named by an older CSI",
+			csiDeploy: defaultCSI("rbd", later, "", "a"),
+			objects: []runtime.Object{
+				defaultCSI("cephfs", earlier, "c", "c"),
+				ownedStorageClass("a", "rbd", map[string]string{isDefaultClassAnnotation: "true"}),
+			},
+			expected: map[string]map[string]string{
+				"a": markedDefault("false"),
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		objects := append([]runtime.Object{tc.csiDeploy}, tc.objects...)
+		r := &ReconcileCSI{
+			client:   fake.NewFakeClientWithScheme(newTestScheme(t), objects...),
+			config:   &config.Config{NeedDefaultSc: true},
+			recorder: record.NewFakeRecorder(10),
+		}
+		if _, err := r.syncStorageClasses(tc.csiDeploy.DeepCopy()); err != nil {
+			t.Errorf("case %d(%s): sync StorageClasses failed: %v", i, tc.name, err)
+			continue
+		}
+		for name, expected := range tc.expected {
+			sc := &storagev1.StorageClass{}
+			if err := r.getObject(k8stypes.NamespacedName{Name: name}, sc); err != nil {
+				t.Errorf("case %d(%s): get StorageClass %s failed: %v", i, tc.name, name, err)
+				continue
+			}
+			if !reflect.DeepEqual(sc.Annotations, expected) {
+				t.Errorf("case %d(%s): expected annotations of %s %v, got %v",
+					i, tc.name, name, expected, sc.Annotations)
+			}
+		}
+	}
+}
+
+func TestDefaultChoiceRequests(t *testing.T) {
+	earlier := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+	request := func(name string) reconcile.Request {
+		return reconcile.Request{NamespacedName: k8stypes.NamespacedName{Namespace: "kube-system", Name: name}}
+	}
+
+	testCases := []struct {
+		name     string
+		existing []*csiv1.CSI
+		oldCSI   *csiv1.CSI
+		newCSI   *csiv1.CSI
+		expected []reconcile.Request
+	}{
+		{
+			name:     "first default named",
+			existing: []*csiv1.CSI{defaultCSI("a", earlier, ""), defaultCSI("b", later, "")},
+			oldCSI:   defaultCSI("c", later, ""),
+			newCSI:   defaultCSI("c", later, "sc"),
+			expected: []reconcile.Request{request("a"), request("b")},
+		},
+		{
+			name:     "last default gone",
+			existing: []*csiv1.CSI{defaultCSI("a", earlier, ""), defaultCSI("b", later, "")},
+			oldCSI:   defaultCSI("c", later, "sc"),
+			expected: []reconcile.Request{request("a"), request("b")},
+		},
+		{
+			name:     "owner unchanged",
+			existing: []*csiv1.CSI{defaultCSI("a", earlier, "sc"), defaultCSI("b", later, "")},
+			newCSI:   defaultCSI("c", later, "sc"),
+		},
+		{
+			name:     "owner changed",
+			existing: []*csiv1.CSI{defaultCSI("a", earlier, ""), defaultCSI("b", later, "sc"), defaultCSI("d", later, "")},
+			newCSI:   defaultCSI("c", earlier, "sc"),
+			expected: []reconcile.Request{request("b")},
+		},
+		{
+			name:     "default of the owner changed",
+			existing: []*csiv1.CSI{defaultCSI("a", later, ""), defaultCSI("b", later, "sc")},
+			oldCSI:   defaultCSI("c", earlier, "sc"),
+			newCSI:   defaultCSI("c", earlier, "other"),
+		},
+	}
+
+	for i, tc := range testCases {
+		var csiDeploys []csiv1.CSI
+		for _, csiDeploy := range tc.existing {
+			csiDeploys = append(csiDeploys, *csiDeploy)
+		}
+		if tc.newCSI != nil {
+			csiDeploys = append(csiDeploys, *tc.newCSI)
+		}
+		requests := defaultChoiceRequests(csiDeploys, tc.oldCSI, tc.newCSI)
+		if !reflect.DeepEqual(requests, tc.expected) {
+			t.Errorf("case %d(%s): expected %v, got %v", i, tc.name, tc.expected, requests)
+		}
+	}
+}
+
+func TestIsDefaultChanged(t *testing.T) {
+	unmanaged := func(isDefault string) *storagev1.StorageClass {
+		return &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{
+			Name:        "sc",
+			Annotations: map[string]string{isDefaultClassAnnotation: isDefault},
+		}}
+	}
+
+	testCases := []struct {
+		name     string
+		oldSC    *storagev1.StorageClass
+		newSC    *storagev1.StorageClass
+		expected bool
+	}{
+		{"default created", nil, unmanaged("true"), true},
+		{"not default created", nil, unmanaged("false"), false},
+		{"marked as default", unmanaged("false"), unmanaged("true"), true},
+		{"default unchanged", unmanaged("true"), unmanaged("true"), false},
+		{"default deleted", unmanaged("true"), nil, true},
+		{"managed marked as default", nil, ownedStorageClass("sc", "rbd", map[string]string{
+			isDefaultClassAnnotation: "true"}), false},
+	}
+
+	for _, tc := range testCases {
+		if changed := isDefaultChanged(tc.oldSC, tc.newSC); changed != tc.expected {
+			t.Errorf("%s: expected %t, got %t", tc.name, tc.expected, changed)
+		}
+	}
+}
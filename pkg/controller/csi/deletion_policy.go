@@ -0,0 +1,137 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"fmt"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/types"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+)
+
+const (
+	// retainedName is name of label with name of the CSI which retained an object.
+	retainedName = "storage.tkestack.io/retained-name"
+	// retainedNamespace is name of label with namespace of the CSI which retained an object.
+	retainedNamespace = "storage.tkestack.io/retained-namespace"
+)
+
+// getDeletionPolicy returns the deletion policy of a child, the annotation of the child takes precedence.
+func getDeletionPolicy(csiDeploy *csiv1.CSI, object metav1.Object) csiv1.CSIDeletionPolicy {
+	switch policy := object.GetAnnotations()[types.DeletionPolicyKey]; policy {
+	case csiv1.CSIDeletionDelete, csiv1.CSIDeletionOrphan, csiv1.CSIDeletionRetain:
+		return csiv1.CSIDeletionPolicy(policy)
+	case "":
+	default:
+		klog.Warningf("Unknown deletion policy %s of %s, use the policy of %s/%s",
+			policy, object.GetName(), csiDeploy.Namespace, csiDeploy.Name)
+	}
+	if csiDeploy.Spec.DeletionPolicy != "" {
+		return csiDeploy.Spec.DeletionPolicy
+	}
+	return csiv1.CSIDeletionDelete
+}
+
+// releaseOrDelete deletes a child no longer needed, or removes its owner information
+// if the deletion policy is Orphan or Retain.
+func (r *ReconcileCSI) releaseOrDelete(csiDeploy *csiv1.CSI, obj runtime.Object) error {
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+
+	policy := getDeletionPolicy(csiDeploy, object)
+	if policy == csiv1.CSIDeletionDelete {
+		if err := r.deleteObject(obj); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("delete %s failed: %s", object.GetName(), err.Error())
+		}
+		klog.V(4).Infof("%s of %s/%s deleted", object.GetName(), csiDeploy.Namespace, csiDeploy.Name)
+		return nil
+	}
+
+	releaseObject(object, csiDeploy, policy == csiv1.CSIDeletionRetain)
+	if err := r.updateObject(obj); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("release %s failed: %s", object.GetName(), err.Error())
+	}
+	klog.Infof("%s of %s/%s released by deletion policy %s",
+		object.GetName(), csiDeploy.Namespace, csiDeploy.Name, policy)
+	return nil
+}
+
+// releaseObject removes the owner labels and references of a CSI from an object.
+// Retained objects are labelled so that they can be adopted again.
+func releaseObject(object metav1.Object, csiDeploy *csiv1.CSI, retain bool) {
+	objLabels := object.GetLabels()
+	delete(objLabels, ownerName)
+	delete(objLabels, ownerNamespace)
+	if retain {
+		if objLabels == nil {
+			objLabels = make(map[string]string)
+		}
+		objLabels[retainedName] = csiDeploy.Name
+		objLabels[retainedNamespace] = csiDeploy.Namespace
+	}
+	object.SetLabels(objLabels)
+
+	var refs []metav1.OwnerReference
+	for _, ref := range object.GetOwnerReferences() {
+		if ref.UID != csiDeploy.UID {
+			refs = append(refs, ref)
+		}
+	}
+	object.SetOwnerReferences(refs)
+}
+
+// retainedLabelSelector returns a selector to select objects retained by a specific CSI.
+func retainedLabelSelector(csiDeploy *csiv1.CSI) labels.Selector {
+	return labels.SelectorFromSet(labels.Set{
+		retainedName:      csiDeploy.Name,
+		retainedNamespace: csiDeploy.Namespace,
+	})
+}
+
+// adoptObject takes over an object retained by a deleted CSI with the same namespace and name.
+func (r *ReconcileCSI) adoptObject(csiDeploy *csiv1.CSI, obj runtime.Object, namespaced bool) error {
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+
+	objLabels := object.GetLabels()
+	delete(objLabels, retainedName)
+	delete(objLabels, retainedNamespace)
+	objLabels[ownerName] = csiDeploy.Name
+	objLabels[ownerNamespace] = csiDeploy.Namespace
+	object.SetLabels(objLabels)
+	if namespaced {
+		object.SetOwnerReferences(append(object.GetOwnerReferences(), ownerReference(csiDeploy)...))
+	}
+
+	if err := r.updateObject(obj); err != nil {
+		return fmt.Errorf("adopt %s failed: %s", object.GetName(), err.Error())
+	}
+	klog.Infof("%s adopted by %s/%s", object.GetName(), csiDeploy.Namespace, csiDeploy.Name)
+	return nil
+}
@@ -25,6 +25,8 @@ import (
 	"strings"
 
 	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/csi/enhancer"
+	"tkestack.io/csi-operator/pkg/controller/util"
 	"tkestack.io/csi-operator/pkg/types"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -91,7 +93,7 @@ func (r *ReconcileCSI) syncNodeDriver(csiDeploy *csiv1.CSI) (*appsv1.DaemonSet,
 
 	updateDS := existDS.DeepCopy()
 
-	if mergeObjectMeta(&desired.ObjectMeta, &updateDS.ObjectMeta) ||
+	if util.MergeObjectMeta(&desired.ObjectMeta, &updateDS.ObjectMeta) ||
 		// This may be due to someone has changed the object manually.
 		!hasSameGeneration(existDS, updateDS.GroupVersionKind(), csiDeploy) ||
 		// The CSI object changed, we can't determine which field are changed,
@@ -127,6 +129,10 @@ func (r *ReconcileCSI) generateNodeDriver(csiDeploy *csiv1.CSI) *appsv1.DaemonSe
 		// Inject LivenessProbe container.
 		template.Spec.Containers = append(template.Spec.Containers, r.generateLivenessProbe(csiDeploy, false))
 	}
+	for i := range csiDeploy.Spec.DriverTemplate.NodeSidecars {
+		template.Spec.Containers = append(template.Spec.Containers,
+			*csiDeploy.Spec.DriverTemplate.NodeSidecars[i].DeepCopy())
+	}
 	// Set tolerations for csi-node
 	template.Spec.Tolerations = []corev1.Toleration{
 		{
@@ -143,6 +149,9 @@ func (r *ReconcileCSI) generateNodeDriver(csiDeploy *csiv1.CSI) *appsv1.DaemonSe
 	if csiDeploy.Spec.Node.LivenessProbe != nil {
 		injectLivenessProbe(driverContainer, csiDeploy.Spec.Node.LivenessProbe.Parameters, false)
 	}
+	if enhancer.MetricsEnabled(csiDeploy) {
+		injectMetrics(csiDeploy, &template.Spec, false)
+	}
 
 	name := csiDeploy.Name + "-node"
 	selectedLabels := map[string]string{nodeDriverLabel: name}
@@ -322,7 +331,7 @@ func (r *ReconcileCSI) syncControllerDriver(csiDeploy *csiv1.CSI) (*appsv1.Deplo
 
 	updateDeploy := existDeploy.DeepCopy()
 
-	if mergeObjectMeta(&desired.ObjectMeta, &updateDeploy.ObjectMeta) ||
+	if util.MergeObjectMeta(&desired.ObjectMeta, &updateDeploy.ObjectMeta) ||
 		// This may be due to someone has changed the object manually.
 		!hasSameGeneration(existDeploy, updateDeploy.GroupVersionKind(), csiDeploy) ||
 		// The CSI object changed, we can't determine which field are changed,
@@ -342,6 +351,9 @@ func (r *ReconcileCSI) syncControllerDriver(csiDeploy *csiv1.CSI) (*appsv1.Deplo
 // generateControllerDriver generates the content of Controller Driver.
 func (r *ReconcileCSI) generateControllerDriver(csiDeploy *csiv1.CSI) *appsv1.Deployment {
 	template := csiDeploy.Spec.DriverTemplate.Template.DeepCopy()
+	if controllerTemplate := csiDeploy.Spec.DriverTemplate.ControllerTemplate; controllerTemplate != nil {
+		template = controllerTemplate.DeepCopy()
+	}
 
 	if csiDeploy.Namespace == systemNamespace {
 		// Make controller as system critical.
@@ -385,6 +397,9 @@ func (r *ReconcileCSI) generateControllerDriver(csiDeploy *csiv1.CSI) *appsv1.De
 	if csiDeploy.Spec.Controller.LivenessProbe != nil {
 		injectLivenessProbe(driverContainer, csiDeploy.Spec.Controller.LivenessProbe.Parameters, true)
 	}
+	if enhancer.MetricsEnabled(csiDeploy) {
+		injectMetrics(csiDeploy, &template.Spec, true)
+	}
 
 	name := csiDeploy.Name + "-controller"
 	selectedLabels := map[string]string{controllerDriverLabel: name}
@@ -425,8 +440,7 @@ func (r *ReconcileCSI) generateProvisioner(csiDeploy *csiv1.CSI) corev1.Containe
 		Args: []string{
 			"--v=5",
 			"--csi-address=$(ADDRESS)",
-			"--enable-leader-election=true",
-			"--feature-gates=Topology=true",
+			fmt.Sprintf("--feature-gates=Topology=%t", topologyEnabled(csiDeploy)),
 		},
 		Resources:    csiDeploy.Spec.Controller.Provisioner.Resources,
 		Env:          sidecarEnvs(),
@@ -438,11 +452,43 @@ func (r *ReconcileCSI) generateProvisioner(csiDeploy *csiv1.CSI) corev1.Containe
 	if !v.AtLeast(csiV1) {
 		provisioner.Args = append(provisioner.Args, "--provisioner="+csiDeploy.Spec.DriverName)
 	}
+	// --enable-leader-election is removed since v2.0.
+	if v.AtLeast(csiV2) {
+		provisioner.Args = append(provisioner.Args, "--leader-election")
+	} else {
+		provisioner.Args = append(provisioner.Args, "--enable-leader-election=true")
+	}
+	if topology := csiDeploy.Spec.Topology; topology != nil && topology.Enabled && topology.Strict &&
+		v.AtLeast(csiV11) {
+		provisioner.Args = append(provisioner.Args, "--strict-topology")
+	}
+	if csiDeploy.Spec.Controller.Provisioner.Parameters[types.ExtraCreateMetadataKey] == "true" {
+		provisioner.Args = append(provisioner.Args, "--extra-create-metadata")
+	}
+	if storageCapacityEnabled(csiDeploy) {
+		// The CSIStorageCapacity objects are owned by the controller Deployment.
+		provisioner.Args = append(provisioner.Args, "--enable-capacity", "--capacity-ownerref-level=2")
+		provisioner.Env = append(provisioner.Env,
+			corev1.EnvVar{
+				Name:      "NAMESPACE",
+				ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"}},
+			},
+			corev1.EnvVar{
+				Name:      "POD_NAME",
+				ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}},
+			})
+	}
 
 	copySecurityContext(csiDeploy, &provisioner)
 	return provisioner
 }
 
+// storageCapacityEnabled returns true if the provisioner publishes the capacity reported by the driver.
+func storageCapacityEnabled(csiDeploy *csiv1.CSI) bool {
+	provisioner := csiDeploy.Spec.Controller.Provisioner
+	return provisioner != nil && provisioner.Parameters[types.StorageCapacityKey] == "true"
+}
+
 // generateAttacher generates the content of Attacher container.
 func (r *ReconcileCSI) generateAttacher(csiDeploy *csiv1.CSI) corev1.Container {
 	image := csiDeploy.Spec.Controller.Attacher.Image
@@ -484,18 +530,35 @@ func (r *ReconcileCSI) generateAttacher(csiDeploy *csiv1.CSI) corev1.Container {
 
 // generateSnapshotter generates the content of Snapshotter container.
 func (r *ReconcileCSI) generateSnapshotter(csiDeploy *csiv1.CSI) corev1.Container {
+	image := csiDeploy.Spec.Controller.Snapshotter.Image
 	snapshotter := corev1.Container{
 		Name:  "csi-snapshotter",
-		Image: csiDeploy.Spec.Controller.Snapshotter.Image,
+		Image: image,
 		Args: []string{
 			"--v=5",
 			"--csi-address=$(ADDRESS)",
-			"--connection-timeout=1m",
 		},
 		Resources:    csiDeploy.Spec.Controller.Snapshotter.Resources,
 		Env:          sidecarEnvs(),
 		VolumeMounts: sidecarVolumeMounts(),
 	}
+
+	// --connection-timeout is deprecated by --timeout since v2.0, which also elects a leader.
+	v := version.MustParseGeneric(image[strings.LastIndex(image, ":")+1:])
+	if v.AtLeast(csiV2) {
+		snapshotter.Args = append(snapshotter.Args,
+			"--timeout=1m",
+			"--leader-election",
+			"--leader-election-namespace=$(MY_NAMESPACE)")
+		snapshotter.Env = append([]corev1.EnvVar{
+			{
+				Name:      "MY_NAMESPACE",
+				ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"}},
+			},
+		}, snapshotter.Env...)
+	} else {
+		snapshotter.Args = append(snapshotter.Args, "--connection-timeout=1m")
+	}
 	copySecurityContext(csiDeploy, &snapshotter)
 	return snapshotter
 }
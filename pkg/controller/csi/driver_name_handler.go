@@ -0,0 +1,68 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/util"
+)
+
+// driverNamesFunc returns the names of CSI drivers an object refers to.
+type driverNamesFunc func(object handler.MapObject) []string
+
+// newDriverNameHandler enqueues Requests for all CSI objects deploying a driver the object refers to.
+// It is used for objects maintained by kubelet or external components, which carry no owner information.
+func newDriverNameHandler(c client.Client, driverNames driverNamesFunc) handler.EventHandler {
+	mapper := func(object handler.MapObject) []reconcile.Request {
+		names := driverNames(object)
+		if len(names) == 0 {
+			return nil
+		}
+
+		ctx, cancel := util.GetContext()
+		defer cancel()
+		csiList := &csiv1.CSIList{}
+		if err := c.List(ctx, csiList); err != nil {
+			klog.Errorf("List CSI objects failed: %v", err)
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, csiDeploy := range csiList.Items {
+			for _, name := range names {
+				if csiDeploy.Spec.DriverName == name {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{
+							Namespace: csiDeploy.Namespace,
+							Name:      csiDeploy.Name,
+						}})
+					break
+				}
+			}
+		}
+		return requests
+	}
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(mapper),
+	}
+}
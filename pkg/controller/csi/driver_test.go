@@ -0,0 +1,74 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"reflect"
+	"testing"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGenerateSnapshotter(t *testing.T) {
+	testCases := []struct {
+		image        string
+		expectedArgs []string
+		expectedEnvs []string
+	}{
+		{
+			image:        "csi-snapshotter:v1.2.2",
+			expectedArgs: []string{"--v=5", "--csi-address=$(ADDRESS)", "--connection-timeout=1m"},
+			expectedEnvs: []string{"ADDRESS"},
+		},
+		{
+			image: "csi-snapshotter:v6.3.3",
+			expectedArgs: []string{"--v=5", "--csi-address=$(ADDRESS)", "--timeout=1m",
+				"--leader-election", "--leader-election-namespace=$(MY_NAMESPACE)"},
+			expectedEnvs: []string{"MY_NAMESPACE", "ADDRESS"},
+		},
+	}
+
+	r := &ReconcileCSI{}
+	for _, tc := range testCases {
+		csiDeploy := &csiv1.CSI{
+			Spec: csiv1.CSISpec{
+				DriverTemplate: &csiv1.CSIDriverTemplate{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "driver"}}},
+					},
+				},
+				Controller: csiv1.CSIController{
+					Snapshotter: &csiv1.CSIComponent{Image: tc.image},
+				},
+			},
+		}
+		snapshotter := r.generateSnapshotter(csiDeploy)
+		if !reflect.DeepEqual(snapshotter.Args, tc.expectedArgs) {
+			t.Errorf("image %s: expected args %v, got %v", tc.image, tc.expectedArgs, snapshotter.Args)
+		}
+		var envs []string
+		for _, env := range snapshotter.Env {
+			envs = append(envs, env.Name)
+		}
+		if !reflect.DeepEqual(envs, tc.expectedEnvs) {
+			t.Errorf("image %s: expected envs %v, got %v", tc.image, tc.expectedEnvs, envs)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+)
+
+// catalogFile is the format of the version catalog file, such as:
+//
+//	revision: 2020-06
+//	drivers:
+//	  csi-rbd:
+//	    v1.1:
+//	      provisioner: csi-provisioner:v1.6.1
+//	      driver: cephcsi:v3.3.2
+//
+// Only the images set in the file override the built-in ones.
+type catalogFile struct {
+	// Revision of the catalog, the hash of the file is used if empty.
+	Revision string `json:"revision,omitempty"`
+	// Drivers are the images of the versions of the registered drivers.
+	Drivers map[string]map[csiv1.CSIVersion]*ComponentImages `json:"drivers,omitempty"`
+}
+
+// catalogSource is the catalog file overriding the built-in versions of a driver.
+type catalogSource struct {
+	Path     string
+	Revision string
+}
+
+// versionCatalog is the version catalog loaded from a file.
+type versionCatalog struct {
+	lock    sync.RWMutex
+	hash    string
+	source  *catalogSource
+	drivers map[string]map[csiv1.CSIVersion]*ComponentImages
+}
+
+// catalog is the version catalog shared by all CSI objects.
+var catalog = &versionCatalog{}
+
+// LoadCatalog loads the version catalog from a file, and returns true if the catalog is changed.
+// The catalog is kept as is if the file is invalid.
+func LoadCatalog(path string) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("read %s failed: %s", path, err.Error())
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	catalog.lock.RLock()
+	unchanged := catalog.hash == hash && catalog.source != nil && catalog.source.Path == path
+	catalog.lock.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	file := &catalogFile{}
+	if err := yaml.UnmarshalStrict(data, file); err != nil {
+		return false, fmt.Errorf("parse %s failed: %s", path, err.Error())
+	}
+	for name, versions := range file.Drivers {
+		driver := getDriver(name)
+		if driver == nil {
+			return false, fmt.Errorf("unknown driver %s in %s", name, path)
+		}
+		for version, images := range versions {
+			if _, exist := driver.Versions[version]; !exist {
+				return false, fmt.Errorf("unknown version %s of driver %s in %s", version, name, path)
+			}
+			if images == nil {
+				return false, fmt.Errorf("no images of version %s of driver %s in %s", version, name, path)
+			}
+		}
+	}
+
+	revision := file.Revision
+	if len(revision) == 0 {
+		revision = hash[:12]
+	}
+
+	catalog.lock.Lock()
+	defer catalog.lock.Unlock()
+	catalog.hash = hash
+	catalog.source = &catalogSource{Path: path, Revision: revision}
+	catalog.drivers = file.Drivers
+	klog.Infof("Version catalog %s of revision %s loaded", path, revision)
+
+	return true, nil
+}
+
+// WatchCatalog reloads the version catalog from a file periodically until stopCh is closed.
+// onChange is called each time the catalog is changed.
+func WatchCatalog(path string, period time.Duration, stopCh <-chan struct{}, onChange func()) {
+	wait.Until(func() {
+		changed, err := LoadCatalog(path)
+		if err != nil {
+			klog.Errorf("Reload version catalog failed: %v", err)
+			return
+		}
+		if changed {
+			onChange()
+		}
+	}, period, stopCh)
+}
+
+// catalogImages returns a copy of the images of a version of a driver, and the catalog file overriding
+// the built-in images, or nil if the images are built-in.
+func catalogImages(driver *Driver, version csiv1.CSIVersion) (*ComponentImages, *catalogSource, bool) {
+	images, exist := driver.Versions[version]
+	if !exist {
+		return nil, nil, false
+	}
+	// Copy the images, as the versions are shared by all CSI objects of the driver.
+	result := *images
+
+	catalog.lock.RLock()
+	defer catalog.lock.RUnlock()
+	override := catalog.drivers[driver.Name][version]
+	if override == nil {
+		return &result, nil, true
+	}
+	mergeImages(&result, override)
+	return &result, catalog.source, true
+}
+
+// mergeImages overrides the images by the ones set in override.
+func mergeImages(images *ComponentImages, override *ComponentImages) {
+	value := reflect.ValueOf(images).Elem()
+	overrideValue := reflect.ValueOf(override).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if image := overrideValue.Field(i).String(); len(image) > 0 {
+			value.Field(i).SetString(image)
+		}
+	}
+}
+
+// imageVersion parses the tag of an image, such as cephcsi:v3.3.1, or returns nil if the tag is not a version.
+func imageVersion(image string) *version.Version {
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return nil
+	}
+	v, err := version.ParseGeneric(image[i+1:])
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// catalogStatus returns the entry of the version catalog used by a CSI object of a registered driver,
+// or nil if the driver or the version is unknown.
+func catalogStatus(csiDeploy *csiv1.CSI) *csiv1.CSICatalogStatus {
+	driver := getDriver(csiDeploy.Spec.DriverName)
+	if driver == nil {
+		return nil
+	}
+	images, source, exist := catalogImages(driver, csiDeploy.Spec.Version)
+	if !exist {
+		return nil
+	}
+
+	status := &csiv1.CSICatalogStatus{
+		Source: "builtin",
+		Entry:  fmt.Sprintf("%s/%s", driver.Name, csiDeploy.Spec.Version),
+	}
+	if source != nil {
+		status.Source = source.Path
+		status.Revision = source.Revision
+	}
+	if len(csiDeploy.Spec.DriverVersion) > 0 && isNewerImage(images.Driver, csiDeploy.Spec.DriverVersion) {
+		status.DriverUpdate = images.Driver
+	}
+	return status
+}
+
+// isNewerImage returns true if the tag of image is a higher version than the one of running. Images
+// whose tags are not versions are never reported as newer, as their order is unknown.
+func isNewerImage(image, running string) bool {
+	v, runningVersion := imageVersion(image), imageVersion(running)
+	if v == nil || runningVersion == nil {
+		return false
+	}
+	return runningVersion.LessThan(v)
+}
@@ -0,0 +1,252 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catalog")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { catalog = &versionCatalog{} }()
+
+	builtin := *csiVersionMap[csiv1.CSIDriverCephRBD][csiv1.CSIVersionV1p1]
+	overridden := builtin
+	overridden.Provisioner = "csi-provisioner:v1.6.1"
+	overridden.Driver = "cephcsi:v3.2.1"
+	driverOnly := builtin
+	driverOnly.Driver = "cephcsi:v3.2.1"
+
+	testCases := []struct {
+		name             string
+		data             string
+		missing          bool
+		reload           bool
+		expectChanged    bool
+		expectErr        bool
+		expectRevision   string
+		expectImages     *ComponentImages
+		expectOverridden bool
+	}{
+		{
+			name: "override images",
+			data: `revision: 2020-06
+drivers:
+  csi-rbd:
+    v1.1:
+      provisioner: csi-provisioner:v1.6.1
+      driver: cephcsi:v3.2.1
+`,
+			expectChanged:    true,
+			expectRevision:   "2020-06",
+			expectImages:     &overridden,
+			expectOverridden: true,
+		},
+		{
+			name: "unchanged file",
+			data: `revision: 2020-06
+drivers:
+  csi-rbd:
+    v1.1:
+      provisioner: csi-provisioner:v1.6.1
+      driver: cephcsi:v3.2.1
+`,
+			reload:           true,
+			expectRevision:   "2020-06",
+			expectImages:     &overridden,
+			expectOverridden: true,
+		},
+		{
+			name: "hash as revision",
+			data: `drivers:
+  csi-rbd:
+    v1.1:
+      driver: cephcsi:v3.2.1
+`,
+			expectChanged:    true,
+			expectRevision:   "hash",
+			expectImages:     &driverOnly,
+			expectOverridden: true,
+		},
+		{
+			name:         "missing file",
+			missing:      true,
+			expectErr:    true,
+			expectImages: &builtin,
+		},
+		{
+			name:         "invalid yaml",
+			data:         "drivers: [",
+			expectErr:    true,
+			expectImages: &builtin,
+		},
+		{
+			name:         "unknown field",
+			data:         "revision: 2020-06\nunknown: true\n",
+			expectErr:    true,
+			expectImages: &builtin,
+		},
+		{
+			name: "unknown driver",
+			data: `drivers:
+  csi-unknown:
+    v1.1:
+      driver: unknown:v1.0.0
+`,
+			expectErr:    true,
+			expectImages: &builtin,
+		},
+		{
+			name: "unknown version",
+			data: `drivers:
+  csi-rbd:
+    v9.9:
+      driver: cephcsi:v3.2.1
+`,
+			expectErr:    true,
+			expectImages: &builtin,
+		},
+		{
+			name: "no images",
+			data: `drivers:
+  csi-rbd:
+    v1.1:
+`,
+			expectErr:    true,
+			expectImages: &builtin,
+		},
+	}
+
+	for i, testCase := range testCases {
+		catalog = &versionCatalog{}
+		path := filepath.Join(dir, testCase.name)
+		if !testCase.missing {
+			if err := ioutil.WriteFile(path, []byte(testCase.data), 0644); err != nil {
+				t.Fatalf("write %s failed: %v", path, err)
+			}
+		}
+		if testCase.reload {
+			if _, err := LoadCatalog(path); err != nil {
+				t.Fatalf("case %d(%s): first load failed: %v", i, testCase.name, err)
+			}
+		}
+
+		changed, err := LoadCatalog(path)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("case %d(%s): expect error %t, got %v", i, testCase.name, testCase.expectErr, err)
+		}
+		if changed != testCase.expectChanged {
+			t.Errorf("case %d(%s): expect changed %t, got %t", i, testCase.name, testCase.expectChanged, changed)
+		}
+
+		images, source, _ := catalogImages(getDriver(csiv1.CSIDriverCephRBD), csiv1.CSIVersionV1p1)
+		if (source != nil) != testCase.expectOverridden {
+			t.Errorf("case %d(%s): expect overridden %t, got source %v",
+				i, testCase.name, testCase.expectOverridden, source)
+			continue
+		}
+		if source != nil {
+			if source.Path != path {
+				t.Errorf("case %d(%s): expect path %s, got %s", i, testCase.name, path, source.Path)
+			}
+			if testCase.expectRevision == "hash" {
+				if len(source.Revision) != 12 {
+					t.Errorf("case %d(%s): expect hash revision, got %s", i, testCase.name, source.Revision)
+				}
+			} else if source.Revision != testCase.expectRevision {
+				t.Errorf("case %d(%s): expect revision %s, got %s",
+					i, testCase.name, testCase.expectRevision, source.Revision)
+			}
+		}
+		if !reflect.DeepEqual(images, testCase.expectImages) {
+			t.Errorf("case %d(%s): expect images %+v, got %+v", i, testCase.name, testCase.expectImages, images)
+		}
+	}
+}
+
+func TestMergeImages(t *testing.T) {
+	testCases := []struct {
+		images   ComponentImages
+		override ComponentImages
+		expected ComponentImages
+	}{
+		{
+			images:   ComponentImages{Provisioner: "p:v1", Driver: "d:v1"},
+			override: ComponentImages{},
+			expected: ComponentImages{Provisioner: "p:v1", Driver: "d:v1"},
+		},
+		{
+			images:   ComponentImages{Provisioner: "p:v1", Driver: "d:v1"},
+			override: ComponentImages{Driver: "d:v2", Resizer: "r:v1"},
+			expected: ComponentImages{Provisioner: "p:v1", Driver: "d:v2", Resizer: "r:v1"},
+		},
+	}
+
+	for i, testCase := range testCases {
+		images := testCase.images
+		mergeImages(&images, &testCase.override)
+		if !reflect.DeepEqual(images, testCase.expected) {
+			t.Errorf("case %d: expect %+v, got %+v", i, testCase.expected, images)
+		}
+	}
+}
+
+func TestCatalogStatusDriverUpdate(t *testing.T) {
+	builtin := csiVersionMap[csiv1.CSIDriverCephRBD][csiv1.CSIVersionV1p1].Driver
+
+	testCases := []struct {
+		driverVersion string
+		expected      string
+	}{
+		{driverVersion: "", expected: ""},
+		{driverVersion: builtin, expected: ""},
+		{driverVersion: "cephcsi:v3.2.0", expected: builtin},
+		{driverVersion: "registry.local/ceph/cephcsi:v3.3.0", expected: builtin},
+		{driverVersion: "cephcsi:v3.4.0", expected: ""},
+		{driverVersion: "cephcsi:canary", expected: ""},
+		{driverVersion: "registry.local:5000/cephcsi", expected: ""},
+	}
+
+	for _, testCase := range testCases {
+		csiDeploy := &csiv1.CSI{
+			Spec: csiv1.CSISpec{
+				DriverName:    csiv1.CSIDriverCephRBD,
+				Version:       csiv1.CSIVersionV1p1,
+				DriverVersion: testCase.driverVersion,
+			},
+		}
+		status := catalogStatus(csiDeploy)
+		if status == nil {
+			t.Fatalf("driver %q: expect a catalog status", testCase.driverVersion)
+		}
+		if status.DriverUpdate != testCase.expected {
+			t.Errorf("driver %q: expect update %q, got %q",
+				testCase.driverVersion, testCase.expected, status.DriverUpdate)
+		}
+	}
+}
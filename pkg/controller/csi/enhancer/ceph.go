@@ -30,6 +30,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
@@ -39,10 +40,20 @@ const (
 	adminIDKey = "adminID"
 	// Key used to specify ceph admin keyring in StorageClass.
 	adminKeyringKey = "adminKey"
+	// Key used to specify the ceph user used by nodes to map or mount volumes.
+	userIDKey = "userID"
+	// Key used to specify the keyring of the ceph user used by nodes.
+	userKeyringKey = "userKey"
 	// Key used to specify ceph pools in StorageClass.
 	poolsKey = "pools"
 	// Key used to specify ceph config used by ceph-csi of cephFS versions 3.2.0 and above.
 	configsKey = "configs"
+	// Key used to specify the cluster ID of the monitors, adminID and adminKey parameters,
+	// when they are used by ceph-csi versions 3.2.0 and above.
+	clusterIDKey = "clusterID"
+
+	// defaultClusterID is the cluster ID used if clusterID is not set with the monitors parameter.
+	defaultClusterID = "default"
 )
 
 var (
@@ -52,6 +63,10 @@ var (
 			Name:      "csi.storage.k8s.io/controller-expand-secret-name",
 			Namespace: "csi.storage.k8s.io/controller-expand-secret-namespace",
 		},
+		csiv1.CSIVersionV1p1: {
+			Name:      "csi.storage.k8s.io/controller-expand-secret-name",
+			Namespace: "csi.storage.k8s.io/controller-expand-secret-namespace",
+		},
 		csiv1.CSIVersionV0: {
 			Name:      "csiControllerExpandSecretName",
 			Namespace: "csiControllerExpandSecretNamespace",
@@ -62,6 +77,10 @@ var (
 			Name:      "csi.storage.k8s.io/controller-publish-secret-name",
 			Namespace: "csi.storage.k8s.io/controller-publish-secret-namespace",
 		},
+		csiv1.CSIVersionV1p1: {
+			Name:      "csi.storage.k8s.io/controller-publish-secret-name",
+			Namespace: "csi.storage.k8s.io/controller-publish-secret-namespace",
+		},
 		csiv1.CSIVersionV0: {
 			Name:      "csiControllerPublishSecretName",
 			Namespace: "csiControllerPublishSecretNamespace",
@@ -72,12 +91,26 @@ var (
 			Name:      "csi.storage.k8s.io/provisioner-secret-name",
 			Namespace: "csi.storage.k8s.io/provisioner-secret-namespace",
 		},
+		csiv1.CSIVersionV1p1: {
+			Name:      "csi.storage.k8s.io/provisioner-secret-name",
+			Namespace: "csi.storage.k8s.io/provisioner-secret-namespace",
+		},
 		csiv1.CSIVersionV0: {
 			Name:      "csiProvisionerSecretName",
 			Namespace: "csiProvisionerSecretNamespace",
 		},
 	}
 	nodeSecretKey = map[csiv1.CSIVersion]map[string]keySet{
+		csiv1.CSIVersionV1p1: {
+			csiv1.CSIDriverCephRBD: {
+				Name:      "csi.storage.k8s.io/node-stage-secret-name",
+				Namespace: "csi.storage.k8s.io/node-stage-secret-namespace",
+			},
+			csiv1.CSIDriverCephFS: {
+				Name:      "csi.storage.k8s.io/node-stage-secret-name",
+				Namespace: "csi.storage.k8s.io/node-stage-secret-namespace",
+			},
+		},
 		csiv1.CSIVersionV1: {
 			csiv1.CSIDriverCephRBD: {
 				Name:      "csi.storage.k8s.io/node-publish-secret-name",
@@ -101,14 +134,27 @@ var (
 	}
 )
 
+func init() {
+	for _, name := range []string{csiv1.CSIDriverCephRBD, csiv1.CSIDriverCephFS} {
+		Register(&Driver{
+			Name:        name,
+			Versions:    csiVersionMap[name],
+			NewEnhancer: newCephEnhancer,
+			Validate:    validateCephParameters,
+		})
+	}
+}
+
 // newCephEnhancer creates a cephEnhancer.
-func newCephEnhancer(config *config.Config) Enhancer {
-	return &cephEnhancer{config}
+func newCephEnhancer(config *config.Config, reader client.Reader) Enhancer {
+	return &cephEnhancer{config: config, reader: reader}
 }
 
 // cephEnhancer is a Enhancer for ceph.
 type cephEnhancer struct {
 	config *config.Config
+	// reader is used to read the imported ceph.conf and keyrings.
+	reader client.Reader
 }
 
 // Enhance enhances a well known CSI type.
@@ -124,22 +170,10 @@ func (e *cephEnhancer) Enhance(csiDeploy *csiv1.CSI) error {
 
 // enhanceCephRBD enhances a CephRBD volume.
 func (e *cephEnhancer) enhanceCephRBD(csiDeploy *csiv1.CSI) error {
-	csiVersion, err := getCSIVersion(csiDeploy)
+	csiVersion, err := EnhanceComponents(e.config, csiDeploy, cephRBDLivenessProbePorts, cephRBDMetricsPorts)
 	if err != nil {
 		return err
 	}
-	enhanceExternalComponents(e.config, csiDeploy, csiVersion)
-	// Fill livenessProbe ports.
-	if csiDeploy.Spec.Node.LivenessProbe != nil {
-		csiDeploy.Spec.Node.LivenessProbe.Parameters = map[string]string{
-			types.LivenessProbePortKey: cephRBDLivenessProbePorts.Node,
-		}
-	}
-	if csiDeploy.Spec.Controller.LivenessProbe != nil {
-		csiDeploy.Spec.Controller.LivenessProbe.Parameters = map[string]string{
-			types.LivenessProbePortKey: cephRBDLivenessProbePorts.Controller,
-		}
-	}
 
 	csiDeploy.Spec.DriverTemplate = &csiv1.CSIDriverTemplate{
 		Template: corev1.PodTemplateSpec{
@@ -163,7 +197,7 @@ func (e *cephEnhancer) enhanceCephRBD(csiDeploy *csiv1.CSI) error {
 							},
 							AllowPrivilegeEscalation: boolPtr(true),
 						},
-						Image: getImage(e.config.RegistryDomain, csiVersion.Driver),
+						Image: GetImage(e.config.RegistryDomain, csiVersion.Driver),
 						Args: []string{
 							"--nodeid=$(NODE_ID)",
 							"--endpoint=$(CSI_ENDPOINT)",
@@ -187,7 +221,31 @@ func (e *cephEnhancer) enhanceCephRBD(csiDeploy *csiv1.CSI) error {
 		},
 	}
 
-	cephInfo := e.getCephInfo(csiDeploy)
+	if csiDeploy.Spec.Version == csiv1.CSIVersionV1p1 {
+		// ceph-csi 3.x gets the monitors by the clusterID in StorageClasses.
+		spec := &csiDeploy.Spec.DriverTemplate.Template.Spec
+		spec.Volumes = hostVolumes()
+		container := &spec.Containers[0]
+		container.Env = fieldEnvs()
+		container.VolumeMounts = hostVolumeMounts()
+		useCephCSIConfig(csiDeploy, "rbd")
+
+		cephConfigs, legacyNames, err := e.getRBDCephConfigs(csiDeploy)
+		if err != nil {
+			return err
+		}
+		if cephConfigs == nil {
+			return nil
+		}
+		csiDeploy.Spec.Secrets, csiDeploy.Spec.StorageClasses, csiDeploy.Spec.ConfigMaps =
+			e.enhanceCephSecretsStorageClassesAndConfigMap(csiDeploy, cephConfigs, legacyNames)
+		return e.enhanceCephEncryption(csiDeploy)
+	}
+
+	cephInfo, err := e.getCephInfo(csiDeploy)
+	if err != nil {
+		return err
+	}
 	if cephInfo != nil {
 		csiDeploy.Spec.Secrets, csiDeploy.Spec.StorageClasses = e.enhanceCephSecretAndStorageClasses(csiDeploy, cephInfo)
 	}
@@ -195,38 +253,64 @@ func (e *cephEnhancer) enhanceCephRBD(csiDeploy *csiv1.CSI) error {
 	return nil
 }
 
+// getRBDCephConfigs returns the Ceph clusters of CephRBD used by ceph-csi 3.x. If neither the Ceph section
+// nor the configs parameter is set, the monitors, adminID and adminKey parameters are used as a single cluster,
+// and the StorageClasses keep the names generated by version 1.0, so that version 1.0 can be upgraded in place.
+func (e *cephEnhancer) getRBDCephConfigs(csiDeploy *csiv1.CSI) ([]cephConfig, bool, error) {
+	if csiDeploy.Spec.Ceph != nil || len(csiDeploy.Spec.Parameters[configsKey]) > 0 {
+		cephConfigs, err := e.getCephConfigs(csiDeploy)
+		return cephConfigs, false, err
+	}
+
+	cephInfo, err := e.getCephInfo(csiDeploy)
+	if err != nil || cephInfo == nil {
+		return nil, false, err
+	}
+	clusterID := cephInfo.ClusterID
+	if len(clusterID) == 0 {
+		clusterID = defaultClusterID
+	}
+	return []cephConfig{{
+		Monitors:  cephInfo.Monitors,
+		AdminID:   cephInfo.AdminID,
+		AdminKey:  cephInfo.AdminKey,
+		UserID:    cephInfo.UserID,
+		UserKey:   cephInfo.UserKey,
+		Pools:     strings.Join(cephInfo.Pools, ","),
+		ClusterID: clusterID,
+	}}, true, nil
+}
+
 // enhanceCephFS enhance a CephFS volume.
 func (e *cephEnhancer) enhanceCephFS(csiDeploy *csiv1.CSI) error {
-	csiVersion, err := getCSIVersion(csiDeploy)
+	csiVersion, err := EnhanceComponents(e.config, csiDeploy, cephFSLivenessProbePorts, cephFSMetricsPorts)
 	if err != nil {
 		return err
 	}
-	enhanceExternalComponents(e.config, csiDeploy, csiVersion)
-	if csiDeploy.Spec.Node.LivenessProbe != nil {
-		csiDeploy.Spec.Node.LivenessProbe.Parameters = map[string]string{
-			types.LivenessProbePortKey: cephFSLivenessProbePorts.Node,
-		}
-	}
-	if csiDeploy.Spec.Controller.LivenessProbe != nil {
-		csiDeploy.Spec.Controller.LivenessProbe.Parameters = map[string]string{
-			types.LivenessProbePortKey: cephFSLivenessProbePorts.Controller,
-		}
-	}
 
 	// Fill DriverTemplate.
 	e.generateCephFSDriverTemplate(csiVersion, csiDeploy)
 
 	if csiDeploy.Spec.Version == csiv1.CSIVersionV0 {
 		// Fill ceph related information.
-		cephInfo := e.getCephInfo(csiDeploy)
+		cephInfo, err := e.getCephInfo(csiDeploy)
+		if err != nil {
+			return err
+		}
 		if cephInfo != nil {
 			csiDeploy.Spec.Secrets, csiDeploy.Spec.StorageClasses = e.enhanceCephSecretAndStorageClasses(csiDeploy, cephInfo)
 		}
 	} else {
-		cephConfigs := e.getCephConfigs(csiDeploy)
+		cephConfigs, err := e.getCephConfigs(csiDeploy)
+		if err != nil {
+			return err
+		}
+		if usesFuseMounter(cephConfigs) {
+			mountFuse(csiDeploy)
+		}
 		if cephConfigs != nil {
 			csiDeploy.Spec.Secrets, csiDeploy.Spec.StorageClasses, csiDeploy.Spec.ConfigMaps =
-				e.enhanceCephSecretsStorageClassesAndConfigMap(csiDeploy, cephConfigs)
+				e.enhanceCephSecretsStorageClassesAndConfigMap(csiDeploy, cephConfigs, false)
 		}
 	}
 
@@ -234,7 +318,7 @@ func (e *cephEnhancer) enhanceCephFS(csiDeploy *csiv1.CSI) error {
 }
 
 func (e *cephEnhancer) generateCephFSDriverTemplate(
-	csiVersion *csiVersion,
+	csiVersion *ComponentImages,
 	csiDeploy *csiv1.CSI) {
 	csiDeploy.Spec.DriverTemplate = &csiv1.CSIDriverTemplate{
 		Template: corev1.PodTemplateSpec{
@@ -258,7 +342,7 @@ func (e *cephEnhancer) generateCephFSDriverTemplate(
 							},
 							AllowPrivilegeEscalation: boolPtr(true),
 						},
-						Image: getImage(e.config.RegistryDomain, csiVersion.Driver),
+						Image: GetImage(e.config.RegistryDomain, csiVersion.Driver),
 						Args: []string{
 							"--nodeid=$(NODE_ID)",
 							"--endpoint=$(CSI_ENDPOINT)",
@@ -278,43 +362,53 @@ func (e *cephEnhancer) generateCephFSDriverTemplate(
 
 	// Mount cache related parameters are only supported in CSI V1.x.
 	if csiDeploy.Spec.Version == csiv1.CSIVersionV1 {
-		spec := &csiDeploy.Spec.DriverTemplate.Template.Spec
-		spec.Volumes = append(spec.Volumes, corev1.Volume{
-			Name: "ceph-csi-config",
-			VolumeSource: corev1.VolumeSource{
-				ConfigMap: &corev1.ConfigMapVolumeSource{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: getConfigMapName(csiDeploy),
-					},
-				},
-			},
-		}, corev1.Volume{
-			Name: "keys-tmp-dir",
-			VolumeSource: corev1.VolumeSource{
-				EmptyDir: &corev1.EmptyDirVolumeSource{
-					Medium: corev1.StorageMediumMemory,
+		useCephCSIConfig(csiDeploy, "cephfs")
+	}
+}
+
+// useCephCSIConfig makes the driver template run ceph-csi 3.x of a type, rbd or cephfs,
+// which reads the Ceph clusters from the generated ConfigMap.
+func useCephCSIConfig(csiDeploy *csiv1.CSI, typ string) {
+	spec := &csiDeploy.Spec.DriverTemplate.Template.Spec
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: "ceph-csi-config",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: getConfigMapName(csiDeploy),
 				},
 			},
-		})
-
-		container := &spec.Containers[0]
-		container.Args = []string{
-			"--nodeid=$(NODE_ID)",
-			"--endpoint=$(CSI_ENDPOINT)",
-			"--v=5",
-			"--drivername=" + csiDeploy.Spec.DriverName,
-			"--type=cephfs",
-		}
-		container.Env = append(container.Env, corev1.EnvVar{
-			Name: "POD_IP",
-			ValueFrom: &corev1.EnvVarSource{
-				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"},
+		},
+	}, corev1.Volume{
+		Name: "keys-tmp-dir",
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{
+				Medium: corev1.StorageMediumMemory,
 			},
-		})
-		container.VolumeMounts = append(container.VolumeMounts,
-			corev1.VolumeMount{Name: "ceph-csi-config", MountPath: "/etc/ceph-csi-config/"},
-			corev1.VolumeMount{Name: "keys-tmp-dir", MountPath: "/tmp/csi/keys"})
-	}
+		},
+	})
+
+	container := &spec.Containers[0]
+	container.Args = []string{
+		"--nodeid=$(NODE_ID)",
+		"--endpoint=$(CSI_ENDPOINT)",
+		"--v=5",
+		"--drivername=" + csiDeploy.Spec.DriverName,
+		"--type=" + typ,
+	}
+	if MetricsEnabled(csiDeploy) {
+		container.Args = append(container.Args,
+			"--enablegrpcmetrics=true", "--metricsport=$("+types.MetricsPortEnv+")")
+	}
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name: "POD_IP",
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"},
+		},
+	})
+	container.VolumeMounts = append(container.VolumeMounts,
+		corev1.VolumeMount{Name: "ceph-csi-config", MountPath: "/etc/ceph-csi-config/"},
+		corev1.VolumeMount{Name: "keys-tmp-dir", MountPath: "/tmp/csi/keys"})
 }
 
 // 1. Generate a Secret to hold ceph secret information
@@ -340,6 +434,26 @@ func (e *cephEnhancer) enhanceCephSecretAndStorageClasses(
 	case csiv1.CSIDriverCephRBD:
 		secret.Data = map[string][]byte{cephInfo.AdminID: adminKey}
 	}
+	secrets := []corev1.Secret{secret}
+
+	// Nodes use the restricted user if it is set, instead of the admin.
+	userID, nodeSecretName := cephInfo.AdminID, secretName
+	if len(cephInfo.UserID) > 0 && len(cephInfo.UserKey) > 0 {
+		userID, nodeSecretName = cephInfo.UserID, secretName+"-node"
+		nodeSecret := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nodeSecretName,
+				Namespace: csiDeploy.Namespace,
+			},
+		}
+		switch csiDeploy.Spec.DriverName {
+		case csiv1.CSIDriverCephFS:
+			nodeSecret.Data = cephFSUserSecretData(csiv1.CSICephUser{UserID: cephInfo.UserID, UserKey: cephInfo.UserKey})
+		case csiv1.CSIDriverCephRBD:
+			nodeSecret.Data = map[string][]byte{cephInfo.UserID: []byte(cephInfo.UserKey)}
+		}
+		secrets = append(secrets, nodeSecret)
+	}
 
 	// Generate storageClasses.
 	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
@@ -355,12 +469,12 @@ func (e *cephEnhancer) enhanceCephSecretAndStorageClasses(
 				"monitors": cephInfo.Monitors,
 				"pool":     pool,
 				"adminid":  cephInfo.AdminID,
-				"userid":   cephInfo.AdminID,
+				"userid":   userID,
 
 				provisionerSecretKey[csiDeploy.Spec.Version].Name:      secretName,
 				provisionerSecretKey[csiDeploy.Spec.Version].Namespace: secret.Namespace,
 
-				nodeSecretKey[csiDeploy.Spec.Version][csiDeploy.Spec.DriverName].Name:      secretName,
+				nodeSecretKey[csiDeploy.Spec.Version][csiDeploy.Spec.DriverName].Name:      nodeSecretName,
 				nodeSecretKey[csiDeploy.Spec.Version][csiDeploy.Spec.DriverName].Namespace: secret.Namespace,
 			},
 		}
@@ -379,17 +493,20 @@ func (e *cephEnhancer) enhanceCephSecretAndStorageClasses(
 		scList[0].Name = csiDeploy.Spec.DriverName
 	}
 
-	return []corev1.Secret{secret}, e.getStorageClassesWithFS(csiDeploy.Spec.DriverName, scList)
+	return secrets, e.getStorageClassesWithFS(csiDeploy, scList)
 }
 
 // 1. Generate a Secret to hold ceph secret information
 // 2. Create a StorageClass for each pool and file system
 // 3. Create a ConfigMap holds ceph clusters' information
+// If legacyNames is true, the StorageClasses are named without the cluster ID as version 1.0 does.
 func (e *cephEnhancer) enhanceCephSecretsStorageClassesAndConfigMap(
 	csiDeploy *csiv1.CSI,
-	cephConfigs []cephConfig) ([]corev1.Secret, []storagev1.StorageClass, []corev1.ConfigMap) {
+	cephConfigs []cephConfig,
+	legacyNames bool) ([]corev1.Secret, []storagev1.StorageClass, []corev1.ConfigMap) {
 	secrets := make([]corev1.Secret, 0)
 	storageClasses := make([]storagev1.StorageClass, 0)
+	var namedStorageClasses []storagev1.StorageClass
 	cephDriverConfigs := make([]cephDriverConfig, 0)
 	for _, conf := range cephConfigs {
 		// Generate secret.
@@ -407,76 +524,54 @@ func (e *cephEnhancer) enhanceCephSecretsStorageClassesAndConfigMap(
 				"adminKey": adminKey,
 				"adminID":  []byte(conf.AdminID),
 			}
-			if conf.UserKey != "" && conf.UserID != "" {
-				secret.Data["userID"] = []byte(conf.UserID)
-				secret.Data["userKey"] = []byte(conf.UserKey)
-			}
 		case csiv1.CSIDriverCephRBD:
-			secret.Data = map[string][]byte{conf.AdminID: adminKey}
+			// ceph-csi 3.x reads the user to provision images from userID and userKey.
+			secret.Data = map[string][]byte{
+				"userID":  []byte(conf.AdminID),
+				"userKey": adminKey,
+			}
 		}
 		secrets = append(secrets, secret)
+		secrets = append(secrets, cephNodeSecrets(csiDeploy, &conf, secretName)...)
 
 		// Generate storageClasses.
-		reclaimPolicy := corev1.PersistentVolumeReclaimDelete
 		pools := strings.Split(conf.Pools, ",")
 		if len(conf.FSName) == 0 {
 			conf.FSName = "cephfs"
 		}
-		for _, pool := range pools {
-			sc := storagev1.StorageClass{
-				AllowVolumeExpansion: boolPtr(true),
-				ObjectMeta: metav1.ObjectMeta{
-					Name: fmt.Sprintf("%s-%s-%s", csiDeploy.Spec.DriverName, conf.ClusterID, pool),
-				},
-				Provisioner:   csiDeploy.Spec.DriverName,
-				ReclaimPolicy: &reclaimPolicy,
-				Parameters: map[string]string{
-					"pool":      pool,
-					"adminid":   conf.AdminID,
-					"userid":    conf.AdminID,
-					"clusterID": conf.ClusterID,
-					"fsName":    conf.FSName,
-
-					controllerPublishSecretKey[csiDeploy.Spec.Version].Name:      secretName,
-					controllerPublishSecretKey[csiDeploy.Spec.Version].Namespace: secret.Namespace,
-
-					controllerExpandSecretKey[csiDeploy.Spec.Version].Name:      secretName,
-					controllerExpandSecretKey[csiDeploy.Spec.Version].Namespace: secret.Namespace,
-
-					provisionerSecretKey[csiDeploy.Spec.Version].Name:      secretName,
-					provisionerSecretKey[csiDeploy.Spec.Version].Namespace: secret.Namespace,
-
-					nodeSecretKey[csiDeploy.Spec.Version][csiDeploy.Spec.DriverName].Name:      secretName,
-					nodeSecretKey[csiDeploy.Spec.Version][csiDeploy.Spec.DriverName].Namespace: secret.Namespace,
-				},
-			}
-
-			if csiDeploy.Spec.DriverName == csiv1.CSIDriverCephRBD {
-				sc.Parameters["imageFormat"] = "2"
-			}
-			if csiDeploy.Spec.DriverName == csiv1.CSIDriverCephFS {
-				sc.Parameters["provisionVolume"] = "true"
+		if csiDeploy.Spec.DriverName == csiv1.CSIDriverCephFS && len(conf.Classes) > 0 {
+			// The StorageClasses are named by the classes, instead of one for each pool.
+			classSCs, groupConfigs := cephFSClassStorageClasses(csiDeploy, conf, secretName)
+			namedStorageClasses = append(namedStorageClasses, classSCs...)
+			cephDriverConfigs = append(cephDriverConfigs, groupConfigs...)
+		} else {
+			for _, pool := range pools {
+				name := fmt.Sprintf("%s-%s-%s", csiDeploy.Spec.DriverName, conf.ClusterID, pool)
+				if legacyNames {
+					name = fmt.Sprintf("%s-%s", csiDeploy.Spec.DriverName, pool)
+				}
+				storageClasses = append(storageClasses,
+					newCephStorageClass(csiDeploy, name, pool, conf.ClusterID, &conf, secretName))
 			}
+		}
 
-			storageClasses = append(storageClasses, sc)
-
-			// convert cephConfig to cephDriverConfig
-			driverConf := cephDriverConfig{
-				ClusterID: conf.ClusterID,
-				Monitors:  strings.Split(conf.Monitors, ","),
-			}
-			if len(conf.SubVolumeGroup) != 0 {
-				driverConf.CephFS = &cephFSDriverConfig{
-					SubVolumeGroup: conf.SubVolumeGroup,
-				}
+		// convert cephConfig to cephDriverConfig
+		driverConf := cephDriverConfig{
+			ClusterID: conf.ClusterID,
+			Monitors:  strings.Split(conf.Monitors, ","),
+		}
+		if len(conf.SubVolumeGroup) != 0 {
+			driverConf.CephFS = &cephFSDriverConfig{
+				SubVolumeGroup: conf.SubVolumeGroup,
 			}
-			cephDriverConfigs = append(cephDriverConfigs, driverConf)
 		}
+		cephDriverConfigs = append(cephDriverConfigs, driverConf)
 	}
 
 	if len(storageClasses) == 1 {
 		storageClasses[0].Name = csiDeploy.Spec.DriverName
 	}
+	storageClasses = append(storageClasses, namedStorageClasses...)
 
 	// Generate configMap.
 	configMapName := getConfigMapName(csiDeploy)
@@ -498,16 +593,70 @@ func (e *cephEnhancer) enhanceCephSecretsStorageClassesAndConfigMap(
 		}
 	}
 
-	return secrets, e.getStorageClassesWithFS(csiDeploy.Spec.DriverName, storageClasses), []corev1.ConfigMap{configMap}
+	return secrets, e.getStorageClassesWithFS(csiDeploy, storageClasses), []corev1.ConfigMap{configMap}
+}
+
+// newCephStorageClass generates a StorageClass of a pool of a Ceph cluster used by ceph-csi 3.x.
+func newCephStorageClass(
+	csiDeploy *csiv1.CSI,
+	name, pool, clusterID string,
+	conf *cephConfig,
+	secretName string) storagev1.StorageClass {
+	nodeSecretName := cephNodeSecretName(conf, pool, secretName)
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	sc := storagev1.StorageClass{
+		AllowVolumeExpansion: boolPtr(true),
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Provisioner:   csiDeploy.Spec.DriverName,
+		ReclaimPolicy: &reclaimPolicy,
+		Parameters: map[string]string{
+			"pool":      pool,
+			"clusterID": clusterID,
+
+			controllerPublishSecretKey[csiDeploy.Spec.Version].Name:      secretName,
+			controllerPublishSecretKey[csiDeploy.Spec.Version].Namespace: csiDeploy.Namespace,
+
+			controllerExpandSecretKey[csiDeploy.Spec.Version].Name:      secretName,
+			controllerExpandSecretKey[csiDeploy.Spec.Version].Namespace: csiDeploy.Namespace,
+
+			provisionerSecretKey[csiDeploy.Spec.Version].Name:      secretName,
+			provisionerSecretKey[csiDeploy.Spec.Version].Namespace: csiDeploy.Namespace,
+
+			nodeSecretKey[csiDeploy.Spec.Version][csiDeploy.Spec.DriverName].Name:      nodeSecretName,
+			nodeSecretKey[csiDeploy.Spec.Version][csiDeploy.Spec.DriverName].Namespace: csiDeploy.Namespace,
+		},
+	}
+
+	switch csiDeploy.Spec.DriverName {
+	case csiv1.CSIDriverCephRBD:
+		sc.Parameters["imageFormat"] = "2"
+		sc.Parameters["imageFeatures"] = "layering"
+	case csiv1.CSIDriverCephFS:
+		sc.Parameters["adminid"] = conf.AdminID
+		sc.Parameters["userid"] = conf.AdminID
+		if user := conf.nodeUser(pool); user != nil {
+			sc.Parameters["userid"] = user.UserID
+		}
+		sc.Parameters["fsName"] = conf.FSName
+		sc.Parameters["provisionVolume"] = "true"
+	}
+	return sc
 }
 
 // Only used for Ceph RBD.
 func (e *cephEnhancer) getStorageClassesWithFS(
-	typ string,
+	csiDeploy *csiv1.CSI,
 	storageClasses []storagev1.StorageClass) []storagev1.StorageClass {
-	if typ != csiv1.CSIDriverCephRBD {
+	if csiDeploy.Spec.DriverName != csiv1.CSIDriverCephRBD {
 		return storageClasses
 	}
+	// ceph-csi 3.x reads the file system type set by the provisioner.
+	fsTypeKey := "fstype"
+	if csiDeploy.Spec.Version == csiv1.CSIVersionV1p1 {
+		fsTypeKey = "csi.storage.k8s.io/fstype"
+	}
 
 	fileSystems := strings.Split(e.config.Filesystems, ",")
 
@@ -516,7 +665,7 @@ func (e *cephEnhancer) getStorageClassesWithFS(
 		for _, fs := range fileSystems {
 			sc := storageClasses[i].DeepCopy()
 			sc.Name += "-" + fs
-			sc.Parameters["fstype"] = fs
+			sc.Parameters[fsTypeKey] = fs
 			result = append(result, *sc)
 		}
 	}
@@ -526,10 +675,14 @@ func (e *cephEnhancer) getStorageClassesWithFS(
 
 // cephInfo is a set of information of Ceph Cluster.
 type cephInfo struct {
-	Monitors string
-	AdminID  string
-	AdminKey string
-	Pools    []string
+	// ClusterID is the fsid of the imported ceph.conf or the clusterID parameter.
+	ClusterID string
+	Monitors  string
+	AdminID   string
+	AdminKey  string
+	UserID    string
+	UserKey   string
+	Pools     []string
 }
 
 // cephConfig is a set of information of CephFS Cluster used by ceph-csi versions 3.2.0 and above.
@@ -543,6 +696,13 @@ type cephConfig struct {
 	SubVolumeGroup string `json:"subvolumeGroup"`
 	UserID         string `json:"userID"`
 	UserKey        string `json:"userKey"`
+	// PoolUsers are the users used by nodes for each pool, instead of userID and userKey.
+	PoolUsers map[string]csiv1.CSICephUser `json:"poolUsers,omitempty"`
+	// CephConfSecret and CephConfConfigMap refer the ceph.conf and keyrings to fill the unset fields.
+	CephConfSecret    string `json:"cephConfSecret,omitempty"`
+	CephConfConfigMap string `json:"cephConfConfigMap,omitempty"`
+	// Classes of CephFS, a StorageClass is generated for each class instead of each pool.
+	Classes []csiv1.CSICephFSClass `json:"classes,omitempty"`
 }
 
 // cephDriverConfig is a set of information of Ceph Cluster stored in ConfigMap and
@@ -559,48 +719,113 @@ type cephFSDriverConfig struct {
 	SubVolumeGroup string `json:"subvolumeGroup"`
 }
 
-// If Ceph related information specified in CSI, use it. Otherwise use configured information.
-func (e *cephEnhancer) getCephConfigs(csiDeploy *csiv1.CSI) []cephConfig {
-	configBody := csiDeploy.Spec.Parameters[configsKey]
-	cephConfigs := make([]cephConfig, 0)
-	err := json.Unmarshal([]byte(configBody), &cephConfigs)
+// getCephConfigs returns the clusters in the Ceph section, or the deprecated configs parameter.
+func (e *cephEnhancer) getCephConfigs(csiDeploy *csiv1.CSI) ([]cephConfig, error) {
+	cephConfigs, err := parseCephConfigs(csiDeploy)
 	if err != nil {
-		klog.Warningf("parse cephFS's config failed: %+v", err)
-		return nil
+		return nil, fmt.Errorf("parse %s failed: %s", configsKey, err.Error())
 	}
 
-	return cephConfigs
+	for i := range cephConfigs {
+		conf := &cephConfigs[i]
+		if len(conf.CephConfSecret) == 0 && len(conf.CephConfConfigMap) == 0 {
+			continue
+		}
+		imported, err := e.importCephConf(csiDeploy.Namespace, conf.CephConfSecret, conf.CephConfConfigMap)
+		if err == nil {
+			err = imported.fill(conf)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("import ceph.conf of cluster %d failed: %s", i, err.Error())
+		}
+	}
+
+	return cephConfigs, nil
 }
 
-// If Ceph related information specified in CSI, use it. Otherwise use configured information.
-func (e *cephEnhancer) getCephInfo(csiDeploy *csiv1.CSI) *cephInfo {
-	monitors := csiDeploy.Spec.Parameters[monitorsKey]
-	if len(monitors) == 0 {
-		monitors = e.config.Monitors
+// parseCephConfigs converts the clusters in the Ceph section to cephConfigs, or parses the deprecated
+// configs parameter if the Ceph section is not set.
+func parseCephConfigs(csiDeploy *csiv1.CSI) ([]cephConfig, error) {
+	if csiDeploy.Spec.Ceph != nil {
+		cephConfigs := make([]cephConfig, 0, len(csiDeploy.Spec.Ceph.Clusters))
+		for _, cluster := range csiDeploy.Spec.Ceph.Clusters {
+			cephConfigs = append(cephConfigs, cephConfig{
+				Monitors:          strings.Join(cluster.Monitors, ","),
+				AdminID:           cluster.AdminID,
+				AdminKey:          cluster.AdminKey,
+				Pools:             strings.Join(cluster.Pools, ","),
+				ClusterID:         cluster.ClusterID,
+				FSName:            cluster.FSName,
+				SubVolumeGroup:    cluster.SubVolumeGroup,
+				UserID:            cluster.UserID,
+				UserKey:           cluster.UserKey,
+				PoolUsers:         cluster.PoolUsers,
+				CephConfSecret:    cluster.CephConfSecret,
+				CephConfConfigMap: cluster.CephConfConfigMap,
+				Classes:           cluster.Classes,
+			})
+		}
+		return cephConfigs, nil
+	}
+
+	configBody := csiDeploy.Spec.Parameters[configsKey]
+	if len(configBody) == 0 {
+		return nil, nil
+	}
+	cephConfigs := make([]cephConfig, 0)
+	if err := json.Unmarshal([]byte(configBody), &cephConfigs); err != nil {
+		return nil, err
 	}
+	return cephConfigs, nil
+}
 
-	adminID := csiDeploy.Spec.Parameters[adminIDKey]
-	if len(adminID) == 0 {
-		adminID = e.config.AdminID
+// If Ceph related information specified in CSI, use it. Otherwise use the imported ceph.conf and
+// keyrings, or configured information.
+func (e *cephEnhancer) getCephInfo(csiDeploy *csiv1.CSI) (*cephInfo, error) {
+	params := csiDeploy.Spec.Parameters
+	conf := cephConfig{
+		ClusterID: params[clusterIDKey],
+		Monitors:  params[monitorsKey],
+		AdminID:   params[adminIDKey],
+		AdminKey:  params[adminKeyringKey],
+		UserID:    params[userIDKey],
+		UserKey:   params[userKeyringKey],
+	}
+	if len(params[cephConfSecretKey]) > 0 || len(params[cephConfConfigMapKey]) > 0 {
+		imported, err := e.importCephConf(csiDeploy.Namespace, params[cephConfSecretKey], params[cephConfConfigMapKey])
+		if err == nil {
+			err = imported.fill(&conf)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("import ceph.conf failed: %s", err.Error())
+		}
 	}
 
-	adminKey := csiDeploy.Spec.Parameters[adminKeyringKey]
-	if len(adminKey) == 0 {
-		adminKey = e.config.AdminKey
+	if len(conf.Monitors) == 0 {
+		conf.Monitors = e.config.Monitors
+	}
+	if len(conf.AdminID) == 0 {
+		conf.AdminID = e.config.AdminID
+	}
+	if len(conf.AdminKey) == 0 {
+		conf.AdminKey = e.config.AdminKey
 	}
 
-	pools := csiDeploy.Spec.Parameters[poolsKey]
+	pools := params[poolsKey]
 
-	if len(monitors) > 0 && len(adminID) > 0 && len(adminKey) > 0 && len(pools) > 0 {
+	if len(conf.Monitors) > 0 && len(conf.AdminID) > 0 && len(conf.AdminKey) > 0 && len(pools) > 0 {
 		return &cephInfo{
-			Monitors: monitors,
-			AdminID:  adminID,
-			AdminKey: adminKey,
-			Pools:    strings.Split(pools, ","),
-		}
+			ClusterID: conf.ClusterID,
+			Monitors:  conf.Monitors,
+			AdminID:   conf.AdminID,
+			AdminKey:  conf.AdminKey,
+			UserID:    conf.UserID,
+			UserKey:   conf.UserKey,
+			Pools:     strings.Split(pools, ","),
+		}, nil
 	}
 
-	return nil
+	return nil, nil
 }
 
 // fieldEnvs returns a set of common ENVs for both CephRBD and CephFS.
@@ -645,3 +870,46 @@ type keySet struct {
 	Name      string
 	Namespace string
 }
+
+// standardSecretKeys are the secret parameters of StorageClass defined by the external CSI components.
+var standardSecretKeys = []keySet{
+	{Name: "csi.storage.k8s.io/provisioner-secret-name", Namespace: "csi.storage.k8s.io/provisioner-secret-namespace"},
+	{Name: "csi.storage.k8s.io/controller-publish-secret-name",
+		Namespace: "csi.storage.k8s.io/controller-publish-secret-namespace"},
+	{Name: "csi.storage.k8s.io/controller-expand-secret-name",
+		Namespace: "csi.storage.k8s.io/controller-expand-secret-namespace"},
+	{Name: "csi.storage.k8s.io/node-stage-secret-name", Namespace: "csi.storage.k8s.io/node-stage-secret-namespace"},
+	{Name: "csi.storage.k8s.io/node-publish-secret-name", Namespace: "csi.storage.k8s.io/node-publish-secret-namespace"},
+}
+
+// secretKeys returns the secret parameters of StorageClass used by a CSI object.
+func secretKeys(csiDeploy *csiv1.CSI) []keySet {
+	version := csiDeploy.Spec.Version
+	nodeKey, exist := nodeSecretKey[version][csiDeploy.Spec.DriverName]
+	if !exist {
+		return standardSecretKeys
+	}
+	return []keySet{
+		provisionerSecretKey[version],
+		controllerPublishSecretKey[version],
+		controllerExpandSecretKey[version],
+		nodeKey,
+	}
+}
+
+// SecretParameterKeys returns the StorageClass parameters by which the secrets of a CSI object
+// are passed to its driver.
+func SecretParameterKeys(csiDeploy *csiv1.CSI) []string {
+	var keys []string
+	for _, set := range secretKeys(csiDeploy) {
+		keys = append(keys, set.Name, set.Namespace)
+	}
+	return keys
+}
+
+// ProvisionerSecretKeys returns the StorageClass parameters by which the provisioner secret of
+// a CSI object is passed to its driver.
+func ProvisionerSecretKeys(csiDeploy *csiv1.CSI) (string, string) {
+	set := secretKeys(csiDeploy)[0]
+	return set.Name, set.Namespace
+}
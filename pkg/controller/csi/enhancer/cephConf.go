@@ -0,0 +1,310 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// Key used to specify the Secret holding ceph.conf and keyrings, in the format of <namespace>/<name>
+	// or <name>. The Secret must be in the namespace of the CSI object.
+	cephConfSecretKey = "cephConfSecret"
+	// Key used to specify the ConfigMap holding ceph.conf and keyrings, in the format of <namespace>/<name>
+	// or <name>. The ConfigMap must be in the namespace of the CSI object.
+	cephConfConfigMapKey = "cephConfConfigMap"
+
+	// cephConfFile is the key of ceph.conf in the Secret or ConfigMap.
+	cephConfFile = "ceph.conf"
+	// keyringSuffix is the suffix of the keys of keyrings in the Secret or ConfigMap.
+	keyringSuffix = ".keyring"
+	// defaultCephAdminID is the admin used if adminID is not set with an imported ceph.conf.
+	defaultCephAdminID = "admin"
+
+	// cephConfReadTimeout is the timeout of reading the Secret or ConfigMap.
+	cephConfReadTimeout = time.Minute
+)
+
+// importedCephConf is a set of information of Ceph Cluster read from ceph.conf and keyrings.
+type importedCephConf struct {
+	FSID     string
+	Monitors []string
+	// Keys of the users, such as admin for client.admin.
+	Keys map[string]string
+}
+
+// fill fills the unset fields of a cephConfig with the imported information.
+func (c *importedCephConf) fill(conf *cephConfig) error {
+	if len(conf.ClusterID) == 0 {
+		conf.ClusterID = c.FSID
+	}
+	if len(conf.Monitors) == 0 {
+		conf.Monitors = strings.Join(c.Monitors, ",")
+	}
+	if len(conf.AdminID) == 0 {
+		conf.AdminID = defaultCephAdminID
+	}
+	if len(conf.AdminKey) == 0 {
+		conf.AdminKey = c.Keys[conf.AdminID]
+		if len(conf.AdminKey) == 0 {
+			return fmt.Errorf("no keyring of client.%s", conf.AdminID)
+		}
+	}
+	if len(conf.UserID) > 0 && len(conf.UserKey) == 0 {
+		conf.UserKey = c.Keys[conf.UserID]
+		if len(conf.UserKey) == 0 {
+			return fmt.Errorf("no keyring of client.%s", conf.UserID)
+		}
+	}
+	return nil
+}
+
+// importCephConf reads ceph.conf and keyrings from the Secret and the ConfigMap referred in the format of
+// <namespace>/<name> or <name> in the namespace of the CSI object. Files in the Secret take precedence.
+func (e *cephEnhancer) importCephConf(namespace, secretRef, configMapRef string) (*importedCephConf, error) {
+	if e.reader == nil {
+		return nil, fmt.Errorf("no reader to get ceph.conf")
+	}
+
+	files := make(map[string]string)
+	if len(configMapRef) > 0 {
+		name, err := objectRefName(namespace, configMapRef)
+		if err != nil {
+			return nil, err
+		}
+		configMap := &corev1.ConfigMap{}
+		key := k8stypes.NamespacedName{Namespace: namespace, Name: name}
+		if err := e.readObject(key, configMap); err != nil {
+			return nil, fmt.Errorf("get ConfigMap %s failed: %s", key.String(), err.Error())
+		}
+		for name, data := range configMap.Data {
+			files[name] = data
+		}
+	}
+	if len(secretRef) > 0 {
+		name, err := objectRefName(namespace, secretRef)
+		if err != nil {
+			return nil, err
+		}
+		secret := &corev1.Secret{}
+		key := k8stypes.NamespacedName{Namespace: namespace, Name: name}
+		if err := e.readObject(key, secret); err != nil {
+			return nil, fmt.Errorf("get Secret %s failed: %s", key.String(), err.Error())
+		}
+		for name, data := range secret.Data {
+			files[name] = string(data)
+		}
+	}
+
+	content, exist := files[cephConfFile]
+	if !exist {
+		return nil, fmt.Errorf("%s not found", cephConfFile)
+	}
+	conf, err := parseCephConf(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s failed: %s", cephConfFile, err.Error())
+	}
+	for name, content := range files {
+		if strings.HasSuffix(name, keyringSuffix) {
+			parseKeyring(content, conf.Keys)
+		}
+	}
+	return conf, nil
+}
+
+// readObject gets an object by the reader with a timeout.
+func (e *cephEnhancer) readObject(key k8stypes.NamespacedName, obj runtime.Object) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cephConfReadTimeout)
+	defer cancel()
+	return e.reader.Get(ctx, key, obj)
+}
+
+// objectRefName returns the name of an object referred in the format of <namespace>/<name> or <name>.
+// The object must be in the namespace of the CSI object, so that the users who can write CSI objects
+// can't read the objects of other namespaces.
+func objectRefName(namespace, ref string) (string, error) {
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) == 1 {
+		return ref, nil
+	}
+	if parts[0] != namespace {
+		return "", fmt.Errorf("%s must be in the namespace %s of the CSI object", ref, namespace)
+	}
+	return parts[1], nil
+}
+
+// cephConfRefs returns the names of the Secrets and the ConfigMaps holding ceph.conf referred by a CSI object.
+func cephConfRefs(csiDeploy *csiv1.CSI) ([]string, []string) {
+	var secrets, configMaps []string
+	add := func(secretRef, configMapRef string) {
+		if name, err := objectRefName(csiDeploy.Namespace, secretRef); err == nil && len(name) > 0 {
+			secrets = append(secrets, name)
+		}
+		if name, err := objectRefName(csiDeploy.Namespace, configMapRef); err == nil && len(name) > 0 {
+			configMaps = append(configMaps, name)
+		}
+	}
+
+	params := csiDeploy.Spec.Parameters
+	add(params[cephConfSecretKey], params[cephConfConfigMapKey])
+	cephConfigs, _ := parseCephConfigs(csiDeploy)
+	for _, conf := range cephConfigs {
+		add(conf.CephConfSecret, conf.CephConfConfigMap)
+	}
+	return secrets, configMaps
+}
+
+// parseCephConf parses the fsid and the monitors of the global section of ceph.conf.
+func parseCephConf(content string) (*importedCephConf, error) {
+	conf := &importedCephConf{Keys: make(map[string]string)}
+	err := parseINI(content, func(section, key, value string) {
+		if section != "global" {
+			return
+		}
+		switch key {
+		case "fsid":
+			conf.FSID = value
+		case "mon_host":
+			conf.Monitors = parseMonHost(value)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(conf.FSID) == 0 {
+		return nil, fmt.Errorf("fsid not found")
+	}
+	if len(conf.Monitors) == 0 {
+		return nil, fmt.Errorf("mon_host not found")
+	}
+	return conf, nil
+}
+
+// parseMonHost parses mon_host, such as "10.0.0.1,10.0.0.2:6789" or
+// "[v2:10.0.0.1:3300,v1:10.0.0.1:6789] [v2:10.0.0.2:3300,v1:10.0.0.2:6789]". The v1 address of each
+// monitor is used if the monitor has one, as it is supported by all kernels.
+func parseMonHost(value string) []string {
+	var (
+		monitors []string
+		group    []string
+		inGroup  bool
+		item     strings.Builder
+	)
+	flush := func() {
+		if addr := strings.TrimSpace(item.String()); len(addr) > 0 {
+			if inGroup {
+				group = append(group, addr)
+			} else {
+				monitors = append(monitors, trimAddrType(addr))
+			}
+		}
+		item.Reset()
+	}
+	for _, c := range value {
+		switch c {
+		case '[':
+			flush()
+			inGroup, group = true, nil
+		case ']':
+			flush()
+			inGroup = false
+			if addr := pickMonAddr(group); len(addr) > 0 {
+				monitors = append(monitors, addr)
+			}
+		case ',', ';', ' ', '\t':
+			flush()
+		default:
+			item.WriteRune(c)
+		}
+	}
+	flush()
+	return monitors
+}
+
+// pickMonAddr picks the v1 address of a monitor from its addresses, or the first one.
+func pickMonAddr(addrs []string) string {
+	for _, addr := range addrs {
+		if strings.HasPrefix(addr, "v1:") {
+			return trimAddrType(addr)
+		}
+	}
+	if len(addrs) > 0 {
+		return trimAddrType(addrs[0])
+	}
+	return ""
+}
+
+// trimAddrType trims the v1: or v2: prefix and the /<nonce> suffix of an address.
+func trimAddrType(addr string) string {
+	if idx := strings.Index(addr, "/"); idx >= 0 {
+		addr = addr[:idx]
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(addr, "v1:"), "v2:")
+}
+
+// parseKeyring parses the keys of the client sections of a keyring, such as key of client.admin.
+func parseKeyring(content string, keys map[string]string) {
+	// Keyrings are always well formatted by ceph, so errors are ignored.
+	_ = parseINI(content, func(section, key, value string) {
+		if key == "key" && strings.HasPrefix(section, "client.") {
+			keys[strings.TrimPrefix(section, "client.")] = value
+		}
+	})
+}
+
+// parseINI parses the ini format used by ceph. Spaces and dashes in keys are normalized to
+// underscores like ceph does, and comments start with # or ;.
+func parseINI(content string, handle func(section, key, value string)) error {
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return fmt.Errorf("invalid section at line %d", lineNum)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid line %d", lineNum)
+		}
+		key := strings.Join(strings.Fields(strings.ToLower(strings.TrimSpace(parts[0]))), "_")
+		key = strings.Replace(key, "-", "_", -1)
+		value := strings.TrimSpace(parts[1])
+		if idx := strings.IndexAny(value, "#;"); idx >= 0 {
+			value = strings.TrimSpace(value[:idx])
+		}
+		handle(section, key, strings.Trim(value, `"`))
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,175 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMonHost(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected []string
+	}{
+		{"", nil},
+		{"10.0.0.1", []string{"10.0.0.1"}},
+		{"10.0.0.1,10.0.0.2:6789; 10.0.0.3", []string{"10.0.0.1", "10.0.0.2:6789", "10.0.0.3"}},
+		{
+			"[v2:10.0.0.1:3300,v1:10.0.0.1:6789] [v2:10.0.0.2:3300/0,v1:10.0.0.2:6789/0]",
+			[]string{"10.0.0.1:6789", "10.0.0.2:6789"},
+		},
+		{"[v2:10.0.0.1:3300]", []string{"10.0.0.1:3300"}},
+		{"v1:10.0.0.1:6789/0,[v2:10.0.0.2:3300,v1:10.0.0.2:6789]", []string{"10.0.0.1:6789", "10.0.0.2:6789"}},
+	}
+
+	for _, tc := range testCases {
+		if monitors := parseMonHost(tc.value); !reflect.DeepEqual(monitors, tc.expected) {
+			t.Errorf("mon_host %q: expected %v, got %v", tc.value, tc.expected, monitors)
+		}
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected map[string]string
+	}{
+		{
+			name:     "empty keyring",
+			expected: map[string]string{},
+		},
+		{
+			name: "keys of clients",
+			content: `
+[client.admin]
+	key = AQAdmin==
+	caps mon = "allow *"
+[client.kubernetes]
+	key = "AQKube==" # the user of nodes
+`,
+			expected: map[string]string{"admin": "AQAdmin==", "kubernetes": "AQKube=="},
+		},
+		{
+			name: "sections of other entities are ignored",
+			content: `
+[mon.]
+	key = AQMon==
+[osd.0]
+	key = AQOsd==
+`,
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keys := make(map[string]string)
+			parseKeyring(tc.content, keys)
+			if !reflect.DeepEqual(keys, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, keys)
+			}
+		})
+	}
+}
+
+func TestParseCephConf(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		expected  *importedCephConf
+		expectErr bool
+	}{
+		{
+			name: "global section",
+			content: `
+; generated by cephadm
+[global]
+	fsid = 3f0e7c1a-1b2c-4d5e-8f90-123456789abc
+	mon host = [v2:10.0.0.1:3300,v1:10.0.0.1:6789]
+[client]
+	mon_host = 10.0.0.9
+`,
+			expected: &importedCephConf{
+				FSID:     "3f0e7c1a-1b2c-4d5e-8f90-123456789abc",
+				Monitors: []string{"10.0.0.1:6789"},
+				Keys:     map[string]string{},
+			},
+		},
+		{
+			name:      "no fsid",
+			content:   "[global]\nmon_host = 10.0.0.1\n",
+			expectErr: true,
+		},
+		{
+			name:      "no mon_host",
+			content:   "[global]\nfsid = abc\n",
+			expectErr: true,
+		},
+		{
+			name:      "invalid section",
+			content:   "[global\nfsid = abc\n",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf, err := parseCephConf(tc.content)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(conf, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, conf)
+			}
+		})
+	}
+}
+
+func TestObjectRefName(t *testing.T) {
+	testCases := []struct {
+		ref       string
+		expected  string
+		expectErr bool
+	}{
+		{ref: "", expected: ""},
+		{ref: "ceph-conf", expected: "ceph-conf"},
+		{ref: "kube-system/ceph-conf", expected: "ceph-conf"},
+		{ref: "default/ceph-conf", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		name, err := objectRefName("kube-system", tc.ref)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("ref %s: expected an error", tc.ref)
+			}
+			continue
+		}
+		if err != nil || name != tc.expected {
+			t.Errorf("ref %s: expected %s, got %s, %v", tc.ref, tc.expected, name, err)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+const (
+	// kmsTypeMetadata stores the passphrase of each volume in the image metadata, encrypted by
+	// the passphrase in a Secret referred by the KMS, so no external service is needed.
+	kmsTypeMetadata = "metadata"
+	// kmsTypeVault stores the passphrases in Hashicorp Vault.
+	kmsTypeVault = "vault"
+	// kmsTypeVaultTokens stores the passphrases in Hashicorp Vault with the tokens of tenants.
+	kmsTypeVaultTokens = "vaulttokens"
+
+	// encryptionPassphraseKey is the key of the passphrase in the Secret of the metadata KMS.
+	encryptionPassphraseKey = "encryptionPassphrase"
+
+	// Options of the vaulttokens KMS naming the token Secret and the ConfigMap of tenants, and their defaults.
+	tenantTokenNameKey      = "tenantTokenName"
+	tenantConfigNameKey     = "tenantConfigName"
+	defaultTenantTokenName  = "ceph-csi-kms-token"
+	defaultTenantConfigName = "ceph-csi-kms-config"
+
+	kmsConfigVolumeName = "ceph-csi-encryption-kms-config"
+	kmsConfigMountPath  = "/etc/ceph-csi-encryption-kms-config/"
+	kmsSecretsMountPath = "/etc/ceph-csi-kms"
+)
+
+var (
+	kmsTypes = []string{kmsTypeMetadata, kmsTypeVault, kmsTypeVaultTokens}
+
+	// kmsMinVersions are the first ceph-csi versions supporting the KMS types added after v3.2.0.
+	kmsMinVersions = map[string]*version.Version{
+		kmsTypeMetadata:    version.MustParseGeneric("v3.3.0"),
+		kmsTypeVaultTokens: version.MustParseGeneric("v3.3.0"),
+	}
+)
+
+// validateCephEncryption checks whether the encryption settings of CephRBD are valid and supported
+// by the driver image.
+func validateCephEncryption(csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	encryption := csiDeploy.Spec.Ceph.Encryption
+	if csiDeploy.Spec.DriverName != csiv1.CSIDriverCephRBD {
+		return append(errs, field.Forbidden(fieldPath, "only supported by CephRBD"))
+	}
+
+	var driverImage string
+	if images, err := getCSIVersion(csiDeploy); err == nil {
+		driverImage = images.Driver
+	}
+	ids := make(map[string]bool, len(encryption.KMS))
+	for i, kms := range encryption.KMS {
+		path := fieldPath.Child("kms").Index(i)
+		if len(kms.ID) == 0 {
+			errs = append(errs, field.Required(path.Child("id"), ""))
+		} else if ids[kms.ID] {
+			errs = append(errs, field.Duplicate(path.Child("id"), kms.ID))
+		}
+		ids[kms.ID] = true
+
+		switch kms.Type {
+		case kmsTypeMetadata:
+			if len(kms.PassphraseSecret) == 0 {
+				errs = append(errs, field.Required(path.Child("passphraseSecret"), ""))
+			}
+		case kmsTypeVault:
+		case kmsTypeVaultTokens:
+			if !kms.TenantTokens {
+				errs = append(errs, field.Invalid(path.Child("tenantTokens"), kms.TenantTokens,
+					"the KMS reads the tokens of tenants in all namespaces, set it to true to allow it"))
+			}
+		default:
+			errs = append(errs, field.NotSupported(path.Child("type"), kms.Type, kmsTypes))
+			continue
+		}
+		// Images whose tags are not versions are not checked.
+		if minVersion := kmsMinVersions[kms.Type]; minVersion != nil {
+			if v := imageVersion(driverImage); v != nil && v.LessThan(minVersion) {
+				errs = append(errs, field.Invalid(path.Child("type"), kms.Type,
+					fmt.Sprintf("needs ceph-csi %s or later, the driver is %s", minVersion, driverImage)))
+			}
+		}
+	}
+
+	return errs
+}
+
+// enhanceCephEncryption generates the KMS ConfigMap and the encrypted StorageClasses of CephRBD,
+// and mounts them into the driver template.
+func (e *cephEnhancer) enhanceCephEncryption(csiDeploy *csiv1.CSI) error {
+	if csiDeploy.Spec.Ceph == nil || csiDeploy.Spec.Ceph.Encryption == nil ||
+		len(csiDeploy.Spec.Ceph.Encryption.KMS) == 0 {
+		return nil
+	}
+	encryption := csiDeploy.Spec.Ceph.Encryption
+
+	kmsConfigs := make(map[string]map[string]string, len(encryption.KMS))
+	for _, kms := range encryption.KMS {
+		kmsConfig := make(map[string]string, len(kms.Options)+3)
+		for k, v := range kms.Options {
+			kmsConfig[k] = v
+		}
+		kmsConfig["encryptionKMSType"] = kms.Type
+		if kms.Type == kmsTypeMetadata {
+			kmsConfig["secretName"] = kms.PassphraseSecret
+			kmsConfig["secretNamespace"] = csiDeploy.Namespace
+		}
+		kmsConfigs[kms.ID] = kmsConfig
+	}
+
+	body, err := json.Marshal(kmsConfigs)
+	if err != nil {
+		return fmt.Errorf("marshal KMS configs failed: %v", err)
+	}
+	csiDeploy.Spec.ConfigMaps = append(csiDeploy.Spec.ConfigMaps, corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getKMSConfigMapName(csiDeploy),
+			Namespace: csiDeploy.Namespace,
+		},
+		Data: map[string]string{"config.json": string(body)},
+	})
+
+	mountKMS(csiDeploy, encryption)
+	csiDeploy.Spec.StorageClasses = append(csiDeploy.Spec.StorageClasses,
+		encryptedStorageClasses(csiDeploy.Spec.StorageClasses, encryption)...)
+	return nil
+}
+
+// mountKMS mounts the KMS ConfigMap and Secrets into the driver template.
+func mountKMS(csiDeploy *csiv1.CSI, encryption *csiv1.CSICephEncryption) {
+	template := csiDeploy.Spec.DriverTemplate
+	spec := &template.Template.Spec
+	container := &spec.Containers[0]
+
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: kmsConfigVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: getKMSConfigMapName(csiDeploy),
+				},
+			},
+		},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      kmsConfigVolumeName,
+		MountPath: kmsConfigMountPath,
+	})
+
+	for _, kms := range encryption.KMS {
+		if len(kms.SecretName) == 0 {
+			continue
+		}
+		name := "kms-" + kms.ID
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: kms.SecretName},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      name,
+			MountPath: kmsSecretsMountPath + "/" + kms.ID,
+			ReadOnly:  true,
+		})
+	}
+
+	template.Rules = append(template.Rules, kmsRules(encryption)...)
+}
+
+// kmsRules returns the rules of the driver to read the Secrets of the metadata KMS, and the token
+// Secrets and ConfigMaps of tenants, which ceph-csi reads from the apiserver. The rules are
+// limited to the names of these objects.
+func kmsRules(encryption *csiv1.CSICephEncryption) []rbacv1.PolicyRule {
+	var secrets, configMaps []string
+	for _, kms := range encryption.KMS {
+		switch kms.Type {
+		case kmsTypeMetadata:
+			secrets = append(secrets, kms.PassphraseSecret)
+		case kmsTypeVaultTokens:
+			secrets = append(secrets, kmsOption(kms, tenantTokenNameKey, defaultTenantTokenName))
+			configMaps = append(configMaps, kmsOption(kms, tenantConfigNameKey, defaultTenantConfigName))
+		}
+	}
+
+	var rules []rbacv1.PolicyRule
+	if len(secrets) > 0 {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			ResourceNames: secrets,
+			Verbs:         []string{"get"},
+		})
+	}
+	if len(configMaps) > 0 {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups:     []string{""},
+			Resources:     []string{"configmaps"},
+			ResourceNames: configMaps,
+			Verbs:         []string{"get"},
+		})
+	}
+	return rules
+}
+
+// kmsOption returns an option of a KMS, or the default value if it is not set.
+func kmsOption(kms csiv1.CSICephKMS, key, defaultValue string) string {
+	if value := kms.Options[key]; len(value) > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// encryptedStorageClasses generates an encrypted variant of each StorageClass for each KMS, named
+// <name>-encrypted if there is only one KMS, or <name>-encrypted-<id> if there are more.
+func encryptedStorageClasses(
+	storageClasses []storagev1.StorageClass,
+	encryption *csiv1.CSICephEncryption) []storagev1.StorageClass {
+	var result []storagev1.StorageClass
+	for i := range storageClasses {
+		for _, kms := range encryption.KMS {
+			sc := storageClasses[i].DeepCopy()
+			sc.Name += "-encrypted"
+			if len(encryption.KMS) > 1 {
+				sc.Name += "-" + kms.ID
+			}
+			sc.Parameters["encrypted"] = "true"
+			sc.Parameters["encryptionKMSID"] = kms.ID
+			result = append(result, *sc)
+		}
+	}
+	return result
+}
+
+// getKMSConfigMapName returns the name of the KMS ConfigMap.
+func getKMSConfigMapName(csiDeploy *csiv1.CSI) string {
+	return strings.ReplaceAll(csiDeploy.Spec.DriverName+"-kms-conf", ".", "-")
+}
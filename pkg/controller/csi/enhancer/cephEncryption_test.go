@@ -0,0 +1,102 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"reflect"
+	"testing"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateCephEncryption(t *testing.T) {
+	metadata := csiv1.CSICephKMS{ID: "local", Type: kmsTypeMetadata, PassphraseSecret: "rbd-kms-local"}
+	vault := csiv1.CSICephKMS{ID: "vault", Type: kmsTypeVault}
+	vaultTokens := csiv1.CSICephKMS{ID: "tenants", Type: kmsTypeVaultTokens, TenantTokens: true}
+
+	testCases := []struct {
+		name           string
+		driverName     string
+		driverVersion  string
+		kms            []csiv1.CSICephKMS
+		expectedFields []string
+	}{
+		{
+			name:       "builtin driver",
+			driverName: csiv1.CSIDriverCephRBD,
+			kms:        []csiv1.CSICephKMS{metadata, vault, vaultTokens},
+		},
+		{
+			name:           "old driver",
+			driverName:     csiv1.CSIDriverCephRBD,
+			driverVersion:  "cephcsi:v3.2.0",
+			kms:            []csiv1.CSICephKMS{metadata, vault, vaultTokens},
+			expectedFields: []string{"encryption.kms[0].type", "encryption.kms[2].type"},
+		},
+		{
+			name:          "driver tag not a version",
+			driverName:    csiv1.CSIDriverCephRBD,
+			driverVersion: "cephcsi:canary",
+			kms:           []csiv1.CSICephKMS{metadata},
+		},
+		{
+			name:       "invalid KMS",
+			driverName: csiv1.CSIDriverCephRBD,
+			kms: []csiv1.CSICephKMS{
+				{Type: kmsTypeVault},
+				{ID: "local", Type: kmsTypeMetadata},
+				{ID: "local", Type: kmsTypeVaultTokens},
+				{ID: "other", Type: "unknown"},
+			},
+			expectedFields: []string{
+				"encryption.kms[0].id",
+				"encryption.kms[1].passphraseSecret",
+				"encryption.kms[2].id",
+				"encryption.kms[2].tenantTokens",
+				"encryption.kms[3].type",
+			},
+		},
+		{
+			name:           "CephFS",
+			driverName:     csiv1.CSIDriverCephFS,
+			kms:            []csiv1.CSICephKMS{vault},
+			expectedFields: []string{"encryption"},
+		},
+	}
+
+	for _, tc := range testCases {
+		csiDeploy := &csiv1.CSI{
+			Spec: csiv1.CSISpec{
+				DriverName:    tc.driverName,
+				Version:       csiv1.CSIVersionV1p1,
+				DriverVersion: tc.driverVersion,
+				Ceph:          &csiv1.CSICephParameters{Encryption: &csiv1.CSICephEncryption{KMS: tc.kms}},
+			},
+		}
+		errs := validateCephEncryption(csiDeploy, field.NewPath("encryption"))
+		var fields []string
+		for _, err := range errs {
+			fields = append(fields, err.Field)
+		}
+		if !reflect.DeepEqual(fields, tc.expectedFields) {
+			t.Errorf("%s: expected errors of %v, got %v", tc.name, tc.expectedFields, errs)
+		}
+	}
+}
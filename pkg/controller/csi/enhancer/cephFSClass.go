@@ -0,0 +1,119 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"fmt"
+	"strings"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+const (
+	cephFSMounterKernel = "kernel"
+	cephFSMounterFuse   = "fuse"
+)
+
+// cephFSClassStorageClasses generates the StorageClasses of the classes of a cluster. ceph-csi only
+// supports one subvolumeGroup for each clusterID, so a cluster config named <clusterID>-<group> is
+// generated for each other subvolumeGroup used by the classes.
+func cephFSClassStorageClasses(
+	csiDeploy *csiv1.CSI,
+	conf cephConfig,
+	secretName string) ([]storagev1.StorageClass, []cephDriverConfig) {
+	var (
+		storageClasses []storagev1.StorageClass
+		driverConfigs  []cephDriverConfig
+	)
+	groups := make(map[string]bool)
+	pools := strings.Split(conf.Pools, ",")
+
+	for _, class := range conf.Classes {
+		pool := class.Pool
+		if len(pool) == 0 {
+			pool = pools[0]
+		}
+		clusterID := conf.ClusterID
+		if len(class.SubVolumeGroup) > 0 && class.SubVolumeGroup != conf.SubVolumeGroup {
+			clusterID = fmt.Sprintf("%s-%s", conf.ClusterID, class.SubVolumeGroup)
+			if !groups[class.SubVolumeGroup] {
+				groups[class.SubVolumeGroup] = true
+				driverConfigs = append(driverConfigs, cephDriverConfig{
+					ClusterID: clusterID,
+					Monitors:  strings.Split(conf.Monitors, ","),
+					CephFS:    &cephFSDriverConfig{SubVolumeGroup: class.SubVolumeGroup},
+				})
+			}
+		}
+
+		sc := newCephStorageClass(csiDeploy, class.Name, pool, clusterID, &conf, secretName)
+		if len(class.FSName) > 0 {
+			sc.Parameters["fsName"] = class.FSName
+		}
+		if len(class.Mounter) > 0 {
+			sc.Parameters["mounter"] = class.Mounter
+		}
+		if len(class.KernelMountOptions) > 0 {
+			sc.Parameters["kernelMountOptions"] = class.KernelMountOptions
+		}
+		if len(class.FuseMountOptions) > 0 {
+			sc.Parameters["fuseMountOptions"] = class.FuseMountOptions
+		}
+		if len(class.VolumeNamePrefix) > 0 {
+			sc.Parameters["volumeNamePrefix"] = class.VolumeNamePrefix
+		}
+		if len(class.MountOptions) > 0 {
+			sc.MountOptions = append([]string{}, class.MountOptions...)
+		}
+		storageClasses = append(storageClasses, sc)
+	}
+
+	return storageClasses, driverConfigs
+}
+
+// usesFuseMounter returns true if any class selects the fuse mounter.
+func usesFuseMounter(cephConfigs []cephConfig) bool {
+	for _, conf := range cephConfigs {
+		for _, class := range conf.Classes {
+			if class.Mounter == cephFSMounterFuse {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mountFuse adds the host mounts needed by ceph-fuse to the driver template. The ceph-fuse processes
+// keep running in the mount namespace of the host, so that mounts survive the restart of the driver.
+func mountFuse(csiDeploy *csiv1.CSI) {
+	spec := &csiDeploy.Spec.DriverTemplate.Template.Spec
+	spec.Volumes = append(spec.Volumes,
+		corev1.Volume{Name: "host-mount", VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{Path: "/run/mount"}}},
+		corev1.Volume{Name: "ceph-log", VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{Path: "/var/log/ceph", Type: hostPathType(corev1.HostPathDirectoryOrCreate)}}},
+	)
+	container := &spec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts,
+		corev1.VolumeMount{Name: "host-mount", MountPath: "/run/mount"},
+		corev1.VolumeMount{Name: "ceph-log", MountPath: "/var/log/ceph"},
+	)
+}
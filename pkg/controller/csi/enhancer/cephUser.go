@@ -0,0 +1,100 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"fmt"
+	"sort"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nodeUser returns the user used by nodes for a pool, nil means the admin is used.
+func (c *cephConfig) nodeUser(pool string) *csiv1.CSICephUser {
+	if user, ok := c.PoolUsers[pool]; ok {
+		return &user
+	}
+	if len(c.UserID) > 0 && len(c.UserKey) > 0 {
+		return &csiv1.CSICephUser{UserID: c.UserID, UserKey: c.UserKey}
+	}
+	return nil
+}
+
+// cephNodeSecretName returns the name of the Secret used by nodes for a pool.
+func cephNodeSecretName(conf *cephConfig, pool, secretName string) string {
+	if _, ok := conf.PoolUsers[pool]; ok {
+		return fmt.Sprintf("%s-%s-node", secretName, pool)
+	}
+	if conf.nodeUser(pool) != nil {
+		return secretName + "-node"
+	}
+	return secretName
+}
+
+// cephNodeSecrets generates the Secrets of the users of a cluster used by nodes, so that nodes
+// never get the key of the admin.
+func cephNodeSecrets(csiDeploy *csiv1.CSI, conf *cephConfig, secretName string) []corev1.Secret {
+	users := make(map[string]csiv1.CSICephUser)
+	if len(conf.UserID) > 0 && len(conf.UserKey) > 0 {
+		users[secretName+"-node"] = csiv1.CSICephUser{UserID: conf.UserID, UserKey: conf.UserKey}
+	}
+	for pool, user := range conf.PoolUsers {
+		users[cephNodeSecretName(conf, pool, secretName)] = user
+	}
+
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	secrets := make([]corev1.Secret, 0, len(names))
+	for _, name := range names {
+		secret := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: csiDeploy.Namespace,
+			},
+		}
+		switch csiDeploy.Spec.DriverName {
+		case csiv1.CSIDriverCephFS:
+			secret.Data = cephFSUserSecretData(users[name])
+		case csiv1.CSIDriverCephRBD:
+			secret.Data = map[string][]byte{
+				"userID":  []byte(users[name].UserID),
+				"userKey": []byte(users[name].UserKey),
+			}
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets
+}
+
+// cephFSUserSecretData returns the data of a node Secret of CephFS. ceph-csi stages provisioned
+// volumes with adminID and adminKey, so they are set to the restricted user too.
+func cephFSUserSecretData(user csiv1.CSICephUser) map[string][]byte {
+	return map[string][]byte{
+		"adminID":  []byte(user.UserID),
+		"adminKey": []byte(user.UserKey),
+		"userID":   []byte(user.UserID),
+		"userKey":  []byte(user.UserKey),
+	}
+}
@@ -26,8 +26,13 @@ import (
 	"tkestack.io/csi-operator/pkg/config"
 
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var (
@@ -38,30 +43,51 @@ var (
 			corev1.ResourceMemory: resource.MustParse("2Gi"),
 		},
 	}
-	cephRBDLivenessProbePorts    = livenessProbePorts{Node: "9809", Controller: "9808"}
-	cephFSLivenessProbePorts     = livenessProbePorts{Node: "9819", Controller: "9818"}
-	tencentCBSLivenessProbePorts = livenessProbePorts{Node: "9829", Controller: "9828"}
+	cephRBDLivenessProbePorts    = LivenessProbePorts{Node: "9809", Controller: "9808"}
+	cephFSLivenessProbePorts     = LivenessProbePorts{Node: "9819", Controller: "9818"}
+	tencentCBSLivenessProbePorts = LivenessProbePorts{Node: "9829", Controller: "9828"}
+	tencentCFSLivenessProbePorts = LivenessProbePorts{Node: "9839", Controller: "9838"}
+	tencentCOSLivenessProbePorts = LivenessProbePorts{Node: "9849"}
+	nfsLivenessProbePorts        = LivenessProbePorts{Node: "9859", Controller: "9858"}
+	lvmLivenessProbePorts        = LivenessProbePorts{Node: "9869", Controller: "9868"}
+	// Each metrics port is followed by the ports of the sidecars, so leave a gap of 10 ports.
+	cephRBDMetricsPorts    = MetricsPorts{Node: 9840, Controller: 9850}
+	cephFSMetricsPorts     = MetricsPorts{Node: 9860, Controller: 9870}
+	tencentCBSMetricsPorts = MetricsPorts{Node: 9880, Controller: 9890}
+	tencentCFSMetricsPorts = MetricsPorts{Node: 9900, Controller: 9910}
+	tencentCOSMetricsPorts = MetricsPorts{Node: 9920}
+	nfsMetricsPorts        = MetricsPorts{Node: 9930, Controller: 9940}
+	lvmMetricsPorts        = MetricsPorts{Node: 9950, Controller: 9960}
 )
 
-// livenessProbePorts is the set of livenessProbe ports of CSI components.
-type livenessProbePorts struct {
+// LivenessProbePorts is the set of livenessProbe ports of CSI components.
+type LivenessProbePorts struct {
 	Node       string
 	Controller string
 }
 
-// csiVersion is the set of versions of all CSI components.
-type csiVersion struct {
-	Provisioner      string
-	Attacher         string
-	Resizer          string
-	Snapshotter      string
-	LivenessProbe    string
-	NodeRegistrar    string
-	ClusterRegistrar string
-	Driver           string
+// MetricsPorts is the set of the first metrics ports of CSI components.
+type MetricsPorts struct {
+	Node       int32
+	Controller int32
 }
 
-var csiVersionMap = map[string]map[csiv1.CSIVersion]*csiVersion{
+// ComponentImages is the set of versions of all CSI components.
+type ComponentImages struct {
+	Provisioner      string `json:"provisioner,omitempty"`
+	Attacher         string `json:"attacher,omitempty"`
+	Resizer          string `json:"resizer,omitempty"`
+	Snapshotter      string `json:"snapshotter,omitempty"`
+	LivenessProbe    string `json:"livenessProbe,omitempty"`
+	NodeRegistrar    string `json:"nodeRegistrar,omitempty"`
+	ClusterRegistrar string `json:"clusterRegistrar,omitempty"`
+	Driver           string `json:"driver,omitempty"`
+	// Launcher is only used by drivers mounting volumes by a separate process, such as COS.
+	Launcher string `json:"launcher,omitempty"`
+}
+
+// csiVersionMap are the versions of the drivers in the tree, used unless overridden by the version catalog.
+var csiVersionMap = map[string]map[csiv1.CSIVersion]*ComponentImages{
 	csiv1.CSIDriverCephRBD: {
 		csiv1.CSIVersionV0: {
 			Provisioner:   "csi-provisioner:v0.4.2",
@@ -78,8 +104,15 @@ var csiVersionMap = map[string]map[csiv1.CSIVersion]*csiVersion{
 			LivenessProbe: "livenessprobe:v1.1.0",
 			NodeRegistrar: "csi-node-driver-registrar:v1.1.0",
 			Driver:        "rbdplugin:v1.0.0",
-			// TODO: Add resizer.
-			// Resizer:          "v0.1.0",
+		},
+		csiv1.CSIVersionV1p1: {
+			Provisioner:   "csi-provisioner:v1.6.0",
+			Attacher:      "csi-attacher:v1.1.0",
+			Snapshotter:   "csi-snapshotter:v1.2.2",
+			LivenessProbe: "livenessprobe:v1.1.0",
+			NodeRegistrar: "csi-node-driver-registrar:v1.1.0",
+			Driver:        "cephcsi:v3.3.1",
+			Resizer:       "csi-resizer:v0.5.0",
 		},
 	},
 	csiv1.CSIDriverCephFS: {
@@ -123,19 +156,71 @@ var csiVersionMap = map[string]map[csiv1.CSIVersion]*csiVersion{
 			Resizer:       "csi-resizer:v0.5.0",
 		},
 	},
+	csiv1.CSIDriverTencentCFS: {
+		csiv1.CSIVersionV1: {
+			Provisioner:   "csi-provisioner:v1.2.0",
+			LivenessProbe: "livenessprobe:v1.1.0",
+			NodeRegistrar: "csi-node-driver-registrar:v1.1.0",
+			Driver:        "csi-tencentcloud-cfs:v1.0.0",
+		},
+	},
+	csiv1.CSIDriverTencentCOS: {
+		csiv1.CSIVersionV1: {
+			LivenessProbe: "livenessprobe:v1.1.0",
+			NodeRegistrar: "csi-node-driver-registrar:v1.1.0",
+			Driver:        "csi-tencentcloud-cos:v1.0.0",
+			Launcher:      "cos-launcher:v1.0.0",
+		},
+	},
+	csiv1.CSIDriverNFS: {
+		csiv1.CSIVersionV1: {
+			Provisioner:   "csi-provisioner:v1.6.0",
+			LivenessProbe: "livenessprobe:v1.1.0",
+			NodeRegistrar: "csi-node-driver-registrar:v1.1.0",
+			Driver:        "nfsplugin:v3.1.0",
+		},
+		// Subdirectories, snapshots and expansion are supported since nfsplugin v4, with the sidecars it
+		// is released with, the snapshotter serving snapshot.storage.k8s.io/v1.
+		csiv1.CSIVersionV1p1: {
+			Provisioner:   "csi-provisioner:v4.0.0",
+			Snapshotter:   "csi-snapshotter:v6.3.3",
+			Resizer:       "csi-resizer:v1.9.3",
+			LivenessProbe: "livenessprobe:v2.12.0",
+			NodeRegistrar: "csi-node-driver-registrar:v2.10.0",
+			Driver:        "nfsplugin:v4.7.0",
+		},
+	},
+	// Storage capacity tracking is supported since csi-provisioner v3.
+	csiv1.CSIDriverLVM: {
+		csiv1.CSIVersionV1p1: {
+			Provisioner:   "csi-provisioner:v3.1.0",
+			Resizer:       "csi-resizer:v1.4.0",
+			LivenessProbe: "livenessprobe:v1.1.0",
+			NodeRegistrar: "csi-node-driver-registrar:v1.1.0",
+			Driver:        "topolvm:0.20.0",
+		},
+	},
 }
 
-// New creates a Enhancer.
-func New(config *config.Config) Enhancer {
-	cephEnhancer := newCephEnhancer(config)
-	tencentCloudEnhancer := newTencentCloudEnhancer(config)
-	return &enhancer{
-		enhancers: map[string]Enhancer{
-			csiv1.CSIDriverCephRBD:    cephEnhancer,
-			csiv1.CSIDriverCephFS:     cephEnhancer,
-			csiv1.CSIDriverTencentCBS: tencentCloudEnhancer,
-		},
+// New creates an Interface of the registered drivers and the drivers described by DriverProfiles.
+// The reader is used to read the DriverProfiles and the objects referred by CSI objects, such as Secrets.
+func New(config *config.Config, reader client.Reader) Interface {
+	enhancers := make(map[string]Enhancer, len(drivers))
+	for name, driver := range drivers {
+		enhancers[name] = driver.NewEnhancer(config, reader)
 	}
+	return &enhancer{config: config, reader: reader, enhancers: enhancers}
+}
+
+// Interface enhances and validates the CSI objects of well known drivers.
+type Interface interface {
+	Enhancer
+	// Validate checks whether a CSI object is supported by a well known driver and its parameters are valid.
+	// fieldPath is the path of the spec.
+	Validate(csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList
+	// CatalogStatus returns the entry of the version catalog used by a CSI object,
+	// or nil if the CSI object is not of a registered driver.
+	CatalogStatus(csiDeploy *csiv1.CSI) *csiv1.CSICatalogStatus
 }
 
 // Enhancer is helper used to enhance a well known CSI type.
@@ -144,23 +229,207 @@ type Enhancer interface {
 	Enhance(csiDeploy *csiv1.CSI) error
 }
 
-// enhancer is the implement of Enhancer.
+// enhancer is the implement of Interface.
 type enhancer struct {
+	config    *config.Config
+	reader    client.Reader
 	enhancers map[string]Enhancer
 }
 
-// Enhance enhances a well known CSI type.
+// Enhance enhances a well known CSI type. DriverProfiles take precedence over the registered drivers.
 func (e *enhancer) Enhance(csiDeploy *csiv1.CSI) error {
+	profile, err := e.getDriverProfile(csiDeploy.Spec.DriverName)
+	if err != nil {
+		return err
+	}
+	if profile != nil {
+		if err := newProfileEnhancer(e.config, profile).Enhance(csiDeploy); err != nil {
+			return fmt.Errorf("enhance by DriverProfile %s failed: %s", profile.Name, err.Error())
+		}
+		return applyStorageClassTemplates(csiDeploy)
+	}
+
 	enhancer, exist := e.enhancers[csiDeploy.Spec.DriverName]
 	if !exist {
 		return fmt.Errorf("unknown storage type: %s", csiDeploy.Spec.DriverName)
 	}
-	return enhancer.Enhance(csiDeploy)
+	if err := enhancer.Enhance(csiDeploy); err != nil {
+		return err
+	}
+	addDriverRules(csiDeploy, getDriver(csiDeploy.Spec.DriverName).Rules)
+	return applyStorageClassTemplates(csiDeploy)
+}
+
+// CatalogStatus returns the entry of the version catalog used by a CSI object of a registered driver.
+// CSI objects described by DriverProfiles use the versions of the DriverProfiles instead.
+func (e *enhancer) CatalogStatus(csiDeploy *csiv1.CSI) *csiv1.CSICatalogStatus {
+	if csiDeploy.Spec.Version == "" {
+		return nil
+	}
+	if profile, err := e.getDriverProfile(csiDeploy.Spec.DriverName); err != nil || profile != nil {
+		return nil
+	}
+	return catalogStatus(csiDeploy)
 }
 
-// getImage generates a complete image address based on the domain name, image
-// name, and tag of the image registry.
-func getImage(domain string, name string) string {
+// Validate checks whether a CSI object is supported by a DriverProfile or a registered driver.
+func (e *enhancer) Validate(csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList {
+	if csiDeploy.Spec.Version != "" {
+		profile, err := e.getDriverProfile(csiDeploy.Spec.DriverName)
+		if err != nil {
+			return field.ErrorList{field.InternalError(fieldPath.Child("driverName"), err)}
+		}
+		if profile != nil {
+			return validateProfileParameters(profile, csiDeploy, fieldPath)
+		}
+	}
+	return ValidateParameters(csiDeploy, fieldPath)
+}
+
+// addDriverRules adds the PolicyRules registered by the driver to the driver template.
+func addDriverRules(csiDeploy *csiv1.CSI, rules []rbacv1.PolicyRule) {
+	if len(rules) == 0 || csiDeploy.Spec.DriverTemplate == nil {
+		return
+	}
+	template := csiDeploy.Spec.DriverTemplate
+	for _, rule := range rules {
+		exist := false
+		for _, existRule := range template.Rules {
+			if equality.Semantic.DeepEqual(rule, existRule) {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			template.Rules = append(template.Rules, rule)
+		}
+	}
+}
+
+// applyStorageClassTemplates merges the StorageClass templates onto the generated StorageClasses.
+func applyStorageClassTemplates(csiDeploy *csiv1.CSI) error {
+	generated := csiDeploy.Spec.StorageClasses
+	var added []storagev1.StorageClass
+
+	for i := range csiDeploy.Spec.StorageClassTemplates {
+		template := &csiDeploy.Spec.StorageClassTemplates[i]
+		found := false
+		for j := range generated {
+			if template.Base != "" && generated[j].Name != template.Base {
+				continue
+			}
+			found = true
+			if template.Name == "" {
+				MergeStorageClassTemplate(&generated[j], template)
+				continue
+			}
+			sc := generated[j].DeepCopy()
+			sc.Name = template.Name
+			MergeStorageClassTemplate(sc, template)
+			added = append(added, *sc)
+		}
+		if !found && template.Base != "" {
+			return fmt.Errorf("base StorageClass %s of template %d is not generated", template.Base, i)
+		}
+	}
+
+	// The StorageClasses may be kept from the last enhancement, replace the ones generated before.
+	for _, sc := range added {
+		replaced := false
+		for j := range generated {
+			if generated[j].Name == sc.Name {
+				generated[j], replaced = sc, true
+				break
+			}
+		}
+		if !replaced {
+			generated = append(generated, sc)
+		}
+	}
+	csiDeploy.Spec.StorageClasses = generated
+	return nil
+}
+
+// MergeStorageClassTemplate merges a template into a StorageClass.
+func MergeStorageClassTemplate(sc *storagev1.StorageClass, template *csiv1.CSIStorageClassTemplate) {
+	if len(template.Labels) > 0 && sc.Labels == nil {
+		sc.Labels = make(map[string]string, len(template.Labels))
+	}
+	for k, v := range template.Labels {
+		sc.Labels[k] = v
+	}
+	if len(template.Annotations) > 0 && sc.Annotations == nil {
+		sc.Annotations = make(map[string]string, len(template.Annotations))
+	}
+	for k, v := range template.Annotations {
+		sc.Annotations[k] = v
+	}
+	if len(template.Parameters) > 0 && sc.Parameters == nil {
+		sc.Parameters = make(map[string]string, len(template.Parameters))
+	}
+	for k, v := range template.Parameters {
+		sc.Parameters[k] = v
+	}
+
+	if template.ReclaimPolicy != nil {
+		policy := *template.ReclaimPolicy
+		sc.ReclaimPolicy = &policy
+	}
+	if template.MountOptions != nil {
+		sc.MountOptions = append([]string{}, template.MountOptions...)
+	}
+	if template.AllowVolumeExpansion != nil {
+		sc.AllowVolumeExpansion = boolPtr(*template.AllowVolumeExpansion)
+	}
+	if template.VolumeBindingMode != nil {
+		mode := *template.VolumeBindingMode
+		sc.VolumeBindingMode = &mode
+	}
+	if template.AllowedTopologies != nil {
+		sc.AllowedTopologies = nil
+		for i := range template.AllowedTopologies {
+			sc.AllowedTopologies = append(sc.AllowedTopologies, *template.AllowedTopologies[i].DeepCopy())
+		}
+	}
+}
+
+// ReferredSecrets returns the names of the Secrets in the namespace of a CSI object,
+// which are read to enhance the CSI object.
+func ReferredSecrets(csiDeploy *csiv1.CSI) []string {
+	if csiDeploy.Spec.Version == "" {
+		return nil
+	}
+	switch csiDeploy.Spec.DriverName {
+	case csiv1.CSIDriverTencentCOS:
+		return cosSecretNames(csiDeploy)
+	case csiv1.CSIDriverCephRBD, csiv1.CSIDriverCephFS:
+		secrets, _ := cephConfRefs(csiDeploy)
+		return secrets
+	}
+	return nil
+}
+
+// ReferredConfigMaps returns the names of the ConfigMaps in the namespace of a CSI object,
+// which are read to enhance the CSI object.
+func ReferredConfigMaps(csiDeploy *csiv1.CSI) []string {
+	if csiDeploy.Spec.Version == "" {
+		return nil
+	}
+	switch csiDeploy.Spec.DriverName {
+	case csiv1.CSIDriverCephRBD, csiv1.CSIDriverCephFS:
+		_, configMaps := cephConfRefs(csiDeploy)
+		return configMaps
+	}
+	return nil
+}
+
+// GetImage generates a complete image address based on the domain name, image
+// name, and tag of the image registry. Images with a registry are returned as is.
+func GetImage(domain string, name string) string {
+	if parts := strings.SplitN(name, "/", 2); len(parts) == 2 &&
+		(strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return name
+	}
 	if strings.HasSuffix(domain, "/") {
 		domain = strings.TrimSuffix(domain, "/")
 	}
@@ -188,7 +457,7 @@ func getConfigMapName(csiDeploy *csiv1.CSI) string {
 }
 
 // enhanceExternalComponents enhances information of each CSI components.
-func enhanceExternalComponents(globalConfig *config.Config, csiDeploy *csiv1.CSI, csiVersion *csiVersion) {
+func enhanceExternalComponents(globalConfig *config.Config, csiDeploy *csiv1.CSI, csiVersion *ComponentImages) {
 	hasController := false
 	typ := reflect.TypeOf(csiVersion).Elem()
 	value := reflect.ValueOf(csiVersion).Elem()
@@ -202,7 +471,7 @@ func enhanceExternalComponents(globalConfig *config.Config, csiDeploy *csiv1.CSI
 		if len(version) > 0 {
 			// Component will copy to node and controller.
 			component := csiv1.CSIComponent{
-				Image: getImage(globalConfig.RegistryDomain, version),
+				Image: GetImage(globalConfig.RegistryDomain, version),
 			}
 			if criticalComponents.Has(field.Name) {
 				component.Resources = controllerResource
@@ -228,13 +497,13 @@ func enhanceExternalComponents(globalConfig *config.Config, csiDeploy *csiv1.CSI
 }
 
 // getCSIVersion returns the component version.
-func getCSIVersion(csiDeploy *csiv1.CSI) (*csiVersion, error) {
-	csiVersionMapForType, exist := csiVersionMap[csiDeploy.Spec.DriverName]
-	if !exist {
+func getCSIVersion(csiDeploy *csiv1.CSI) (*ComponentImages, error) {
+	driver := getDriver(csiDeploy.Spec.DriverName)
+	if driver == nil {
 		return nil, fmt.Errorf("unknown CSI type %s", csiDeploy.Spec.DriverName)
 	}
 
-	csiVersion, exist := csiVersionMapForType[csiDeploy.Spec.Version]
+	csiVersion, _, exist := catalogImages(driver, csiDeploy.Spec.Version)
 	if !exist {
 		return nil, fmt.Errorf("unknown CSI version %s", csiDeploy.Spec.Version)
 	}
@@ -245,3 +514,22 @@ func getCSIVersion(csiDeploy *csiv1.CSI) (*csiVersion, error) {
 
 	return csiVersion, nil
 }
+
+// MetricsEnabled returns true if the metrics endpoints of a CSI are enabled.
+func MetricsEnabled(csiDeploy *csiv1.CSI) bool {
+	return csiDeploy.Spec.Metrics != nil && csiDeploy.Spec.Metrics.Enabled
+}
+
+// fillMetricsPorts fills the metrics ports not set by users, so that drivers
+// running on the host network will not conflict with each other.
+func fillMetricsPorts(csiDeploy *csiv1.CSI, ports MetricsPorts) {
+	if csiDeploy.Spec.Metrics == nil {
+		return
+	}
+	if csiDeploy.Spec.Metrics.NodePort == 0 {
+		csiDeploy.Spec.Metrics.NodePort = ports.Node
+	}
+	if csiDeploy.Spec.Metrics.ControllerPort == 0 {
+		csiDeploy.Spec.Metrics.ControllerPort = ports.Controller
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"reflect"
+	"testing"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeStorageClassTemplate(t *testing.T) {
+	retain := corev1.PersistentVolumeReclaimRetain
+	waitForConsumer := storagev1.VolumeBindingWaitForFirstConsumer
+
+	testCases := []struct {
+		name     string
+		sc       storagev1.StorageClass
+		template csiv1.CSIStorageClassTemplate
+		expected storagev1.StorageClass
+	}{
+		{
+			name: "empty template",
+			sc: storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "sc"},
+				Parameters: map[string]string{"pool": "rbd"},
+			},
+			expected: storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "sc"},
+				Parameters: map[string]string{"pool": "rbd"},
+			},
+		},
+		{
+			name: "maps are merged",
+			sc: storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "sc", Labels: map[string]string{"a": "1"}},
+				Parameters: map[string]string{"pool": "rbd", "fsType": "ext4"},
+			},
+			template: csiv1.CSIStorageClassTemplate{
+				Labels:      map[string]string{"b": "2"},
+				Annotations: map[string]string{"c": "3"},
+				Parameters:  map[string]string{"fsType": "xfs"},
+			},
+			expected: storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "sc",
+					Labels:      map[string]string{"a": "1", "b": "2"},
+					Annotations: map[string]string{"c": "3"},
+				},
+				Parameters: map[string]string{"pool": "rbd", "fsType": "xfs"},
+			},
+		},
+		{
+			name: "fields are replaced",
+			sc: storagev1.StorageClass{
+				ObjectMeta:   metav1.ObjectMeta{Name: "sc"},
+				MountOptions: []string{"discard"},
+			},
+			template: csiv1.CSIStorageClassTemplate{
+				ReclaimPolicy:        &retain,
+				MountOptions:         []string{"noatime"},
+				AllowVolumeExpansion: boolPtr(true),
+				VolumeBindingMode:    &waitForConsumer,
+				AllowedTopologies: []corev1.TopologySelectorTerm{{
+					MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: "zone", Values: []string{"a"}}},
+				}},
+			},
+			expected: storagev1.StorageClass{
+				ObjectMeta:           metav1.ObjectMeta{Name: "sc"},
+				ReclaimPolicy:        &retain,
+				MountOptions:         []string{"noatime"},
+				AllowVolumeExpansion: boolPtr(true),
+				VolumeBindingMode:    &waitForConsumer,
+				AllowedTopologies: []corev1.TopologySelectorTerm{{
+					MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: "zone", Values: []string{"a"}}},
+				}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sc := tc.sc.DeepCopy()
+			MergeStorageClassTemplate(sc, &tc.template)
+			if !reflect.DeepEqual(*sc, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, *sc)
+			}
+		})
+	}
+}
+
+func TestApplyStorageClassTemplates(t *testing.T) {
+	generated := func(names ...string) []storagev1.StorageClass {
+		var storageClasses []storagev1.StorageClass
+		for _, name := range names {
+			storageClasses = append(storageClasses, storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Parameters: map[string]string{"pool": name},
+			})
+		}
+		return storageClasses
+	}
+
+	testCases := []struct {
+		name          string
+		existing      []storagev1.StorageClass
+		templates     []csiv1.CSIStorageClassTemplate
+		expectedNames []string
+		expectedFS    map[string]string
+		expectErr     bool
+	}{
+		{
+			name:          "no templates",
+			existing:      generated("a", "b"),
+			expectedNames: []string{"a", "b"},
+			expectedFS:    map[string]string{},
+		},
+		{
+			name:          "template without base and name applies to all",
+			existing:      generated("a", "b"),
+			templates:     []csiv1.CSIStorageClassTemplate{{Parameters: map[string]string{"fsType": "xfs"}}},
+			expectedNames: []string{"a", "b"},
+			expectedFS:    map[string]string{"a": "xfs", "b": "xfs"},
+		},
+		{
+			name:     "named template copies its base",
+			existing: generated("a", "b"),
+			templates: []csiv1.CSIStorageClassTemplate{
+				{Base: "b", Name: "b-xfs", Parameters: map[string]string{"fsType": "xfs"}},
+			},
+			expectedNames: []string{"a", "b", "b-xfs"},
+			expectedFS:    map[string]string{"b-xfs": "xfs"},
+		},
+		{
+			name: "StorageClass kept from the last enhancement is replaced",
+			existing: append(generated("a"), storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "a-xfs"},
+				Parameters: map[string]string{"pool": "a", "fsType": "ext4"},
+			}),
+			templates: []csiv1.CSIStorageClassTemplate{
+				{Base: "a", Name: "a-xfs", Parameters: map[string]string{"fsType": "xfs"}},
+			},
+			expectedNames: []string{"a", "a-xfs"},
+			expectedFS:    map[string]string{"a-xfs": "xfs"},
+		},
+		{
+			name:      "base not generated",
+			existing:  generated("a"),
+			templates: []csiv1.CSIStorageClassTemplate{{Base: "missing"}},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			csiDeploy := &csiv1.CSI{}
+			csiDeploy.Spec.StorageClasses = tc.existing
+			csiDeploy.Spec.StorageClassTemplates = tc.templates
+			err := applyStorageClassTemplates(csiDeploy)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			names := make([]string, 0, len(csiDeploy.Spec.StorageClasses))
+			fsTypes := make(map[string]string)
+			for _, sc := range csiDeploy.Spec.StorageClasses {
+				names = append(names, sc.Name)
+				if fsType, exist := sc.Parameters["fsType"]; exist {
+					fsTypes[sc.Name] = fsType
+				}
+			}
+			if !reflect.DeepEqual(names, tc.expectedNames) {
+				t.Errorf("expected StorageClasses %v, got %v", tc.expectedNames, names)
+			}
+			if !reflect.DeepEqual(fsTypes, tc.expectedFS) {
+				t.Errorf("expected fsTypes %v, got %v", tc.expectedFS, fsTypes)
+			}
+		})
+	}
+}
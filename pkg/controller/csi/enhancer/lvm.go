@@ -0,0 +1,334 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"fmt"
+	"strings"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/config"
+	"tkestack.io/csi-operator/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Deprecated parameters of a single device class, used if the lvm section is not set.
+	lvmVolumeGroup  = "volumeGroup"
+	lvmNodeSelector = "nodeSelector"
+
+	// lvmDeviceClassName is the name of the device class set by parameters.
+	lvmDeviceClassName = "lvm"
+	// lvmNodeLabel is the label of the storage nodes if the node selector is not set.
+	lvmNodeLabel = "storage.tkestack.io/lvm"
+	// lvmDefaultFSType is the file system of the volumes if not set.
+	lvmDefaultFSType = "xfs"
+	// lvmDeviceClassKey is the StorageClass parameter of the device class of the volumes.
+	lvmDeviceClassKey = "topolvm.io/device-class"
+	// lvmConfigDir is where the lvmd config is mounted, lvmd reads lvmd.yaml in it by default.
+	lvmConfigDir = "/etc/topolvm"
+	// lvmSocket is the CSI socket inside the driver container, the driver does not support CSI_ENDPOINT.
+	lvmSocket = "/csi/csi.sock"
+)
+
+func init() {
+	Register(&Driver{
+		Name:        csiv1.CSIDriverLVM,
+		Versions:    csiVersionMap[csiv1.CSIDriverLVM],
+		NewEnhancer: newLVMEnhancer,
+		Rules:       lvmPolicyRules(),
+		Validate:    validateLVMParameters,
+	})
+}
+
+// lvmPolicyRules returns PolicyRules needed by the node and the controller of the LVM driver.
+func lvmPolicyRules() []rbacv1.PolicyRule {
+	return []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{"topolvm.io"},
+			Resources: []string{"logicalvolumes", "logicalvolumes/status"},
+			Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete"},
+		},
+		// The node driver reports the capacity of the device classes in the node annotations.
+		{
+			APIGroups: []string{""},
+			Resources: []string{"nodes"},
+			Verbs:     []string{"get", "list", "watch", "update", "patch"},
+		},
+		// The controller driver cleans up the volumes of the deleted nodes.
+		{
+			APIGroups: []string{""},
+			Resources: []string{"persistentvolumeclaims", "pods"},
+			Verbs:     []string{"get", "list", "watch", "update", "delete"},
+		},
+		{
+			APIGroups: []string{"storage.k8s.io"},
+			Resources: []string{"storageclasses", "csidrivers"},
+			Verbs:     []string{"get", "list", "watch"},
+		},
+		{
+			APIGroups: []string{"coordination.k8s.io"},
+			Resources: []string{"leases"},
+			Verbs:     []string{"get", "list", "watch", "create", "update", "delete"},
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"events"},
+			Verbs:     []string{"create", "patch"},
+		},
+	}
+}
+
+// lvmdConfig is the config of lvmd embedded in the node driver.
+type lvmdConfig struct {
+	DeviceClasses []lvmdDeviceClass `json:"device-classes"`
+}
+
+// lvmdDeviceClass is a device class in the config of lvmd.
+type lvmdDeviceClass struct {
+	Name        string `json:"name"`
+	VolumeGroup string `json:"volume-group"`
+	Default     bool   `json:"default,omitempty"`
+	SpareGB     *int64 `json:"spare-gb,omitempty"`
+}
+
+// newLVMEnhancer creates a lvmEnhancer.
+func newLVMEnhancer(config *config.Config, _ client.Reader) Enhancer {
+	return &lvmEnhancer{config: config}
+}
+
+// lvmEnhancer is an Enhancer for the LVM driver.
+type lvmEnhancer struct {
+	config *config.Config
+}
+
+// Enhance enhances CSI for LVM storage.
+func (e *lvmEnhancer) Enhance(csiDeploy *csiv1.CSI) error {
+	csiVersion, err := EnhanceComponents(e.config, csiDeploy, lvmLivenessProbePorts, lvmMetricsPorts)
+	if err != nil {
+		return err
+	}
+	// Volumes are local to the nodes, so the scheduler places pods by the capacity of each node.
+	csiDeploy.Spec.Controller.Provisioner.Parameters = map[string]string{
+		types.StorageCapacityKey: "true",
+	}
+
+	deviceClasses := getLVMDeviceClasses(csiDeploy)
+	if len(deviceClasses) == 0 {
+		return fmt.Errorf("no LVM device classes, lvm.deviceClasses or the %s parameter must be set", lvmVolumeGroup)
+	}
+	configMap, err := generateLVMConfigMap(csiDeploy, deviceClasses)
+	if err != nil {
+		return err
+	}
+	csiDeploy.Spec.ConfigMaps = []corev1.ConfigMap{*configMap}
+	csiDeploy.Spec.DriverTemplate = e.generateLVMDriverTemplate(csiDeploy, csiVersion)
+	csiDeploy.Spec.StorageClasses = generateLVMStorageClasses(csiDeploy, deviceClasses)
+
+	return nil
+}
+
+// generateLVMDriverTemplate generates the content of DriverTemplate for LVM.
+// The node driver embeds lvmd to manage the volume groups of the storage nodes.
+func (e *lvmEnhancer) generateLVMDriverTemplate(
+	csiDeploy *csiv1.CSI,
+	csiVersion *ComponentImages) *csiv1.CSIDriverTemplate {
+	image := GetImage(e.config.RegistryDomain, csiVersion.Driver)
+	return &csiv1.CSIDriverTemplate{
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				// lvm commands are run in the host by nsenter.
+				HostPID:      true,
+				NodeSelector: getLVMNodeSelector(csiDeploy),
+				Containers: []corev1.Container{
+					{
+						Name: "topolvm-node",
+						SecurityContext: &corev1.SecurityContext{
+							Privileged: boolPtr(true),
+						},
+						Image:   image,
+						Command: []string{"/topolvm-node"},
+						Args: append([]string{
+							"--csi-socket=" + lvmSocket,
+							"--embed-lvmd",
+						}, lvmMetricsArgs(csiDeploy)...),
+						Env: []corev1.EnvVar{
+							{
+								Name: "NODE_NAME",
+								ValueFrom: &corev1.EnvVarSource{
+									FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+								},
+							},
+						},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "host-dev", MountPath: "/dev"},
+							{Name: "lvmd-config", MountPath: lvmConfigDir, ReadOnly: true},
+						},
+						ImagePullPolicy: corev1.PullIfNotPresent,
+					},
+				},
+				Volumes: []corev1.Volume{
+					{Name: "host-dev", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/dev"}}},
+					{
+						Name: "lvmd-config",
+						VolumeSource: corev1.VolumeSource{
+							ConfigMap: &corev1.ConfigMapVolumeSource{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: getConfigMapName(csiDeploy),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		ControllerTemplate: &corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name:    "topolvm-controller",
+						Image:   image,
+						Command: []string{"/topolvm-controller"},
+						Args: append([]string{
+							"--csi-socket=" + lvmSocket,
+							// The webhooks only serve topolvm-scheduler, which is replaced by storage capacity tracking.
+							"--enable-webhooks=false",
+						}, lvmMetricsArgs(csiDeploy)...),
+						ImagePullPolicy: corev1.PullIfNotPresent,
+					},
+				},
+			},
+		},
+	}
+}
+
+// lvmMetricsArgs returns the args to serve the metrics of the driver on the port set by the operator.
+func lvmMetricsArgs(csiDeploy *csiv1.CSI) []string {
+	if !MetricsEnabled(csiDeploy) {
+		return nil
+	}
+	return []string{"--metrics-bind-address=$(" + types.MetricsAddressEnv + ")"}
+}
+
+// getLVMNodeSelector returns the node selector of the storage nodes.
+func getLVMNodeSelector(csiDeploy *csiv1.CSI) map[string]string {
+	if lvm := csiDeploy.Spec.LVM; lvm != nil && len(lvm.NodeSelector) > 0 {
+		return lvm.NodeSelector
+	}
+	if selector, err := parseLVMNodeSelector(csiDeploy.Spec.Parameters[lvmNodeSelector]); err == nil && len(selector) > 0 {
+		return selector
+	}
+	return map[string]string{lvmNodeLabel: "true"}
+}
+
+// parseLVMNodeSelector parses the node selector parameter in the format of key1=value1,key2=value2.
+func parseLVMNodeSelector(s string) (map[string]string, error) {
+	selector := make(map[string]string)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) == 0 {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			return nil, fmt.Errorf("invalid label %s, should be in the format of key=value", item)
+		}
+		selector[kv[0]] = kv[1]
+	}
+	return selector, nil
+}
+
+// getLVMDeviceClasses returns the device classes of the lvm section, or the one set by the deprecated parameters.
+func getLVMDeviceClasses(csiDeploy *csiv1.CSI) []csiv1.CSILVMDeviceClass {
+	if lvm := csiDeploy.Spec.LVM; lvm != nil {
+		return lvm.DeviceClasses
+	}
+	volumeGroup := csiDeploy.Spec.Parameters[lvmVolumeGroup]
+	if len(volumeGroup) == 0 {
+		return nil
+	}
+	return []csiv1.CSILVMDeviceClass{{Name: lvmDeviceClassName, VolumeGroup: volumeGroup}}
+}
+
+// generateLVMConfigMap generates the ConfigMap holding the lvmd config of the device classes.
+// The first device class is the default one if none is set.
+func generateLVMConfigMap(
+	csiDeploy *csiv1.CSI,
+	deviceClasses []csiv1.CSILVMDeviceClass) (*corev1.ConfigMap, error) {
+	hasDefault := false
+	for _, deviceClass := range deviceClasses {
+		hasDefault = hasDefault || deviceClass.Default
+	}
+
+	conf := lvmdConfig{DeviceClasses: make([]lvmdDeviceClass, 0, len(deviceClasses))}
+	for i, deviceClass := range deviceClasses {
+		conf.DeviceClasses = append(conf.DeviceClasses, lvmdDeviceClass{
+			Name:        deviceClass.Name,
+			VolumeGroup: deviceClass.VolumeGroup,
+			Default:     deviceClass.Default || (!hasDefault && i == 0),
+			SpareGB:     deviceClass.SpareGB,
+		})
+	}
+	body, err := yaml.Marshal(conf)
+	if err != nil {
+		return nil, fmt.Errorf("marshal lvmd config failed: %s", err.Error())
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getConfigMapName(csiDeploy),
+			Namespace: csiDeploy.Namespace,
+		},
+		Data: map[string]string{"lvmd.yaml": string(body)},
+	}, nil
+}
+
+// generateLVMStorageClasses generates a StorageClass for each device class. Volumes are bound
+// after the pods are scheduled, so that they are created on the nodes of the pods.
+func generateLVMStorageClasses(
+	csiDeploy *csiv1.CSI,
+	deviceClasses []csiv1.CSILVMDeviceClass) []storagev1.StorageClass {
+	storageClasses := make([]storagev1.StorageClass, 0, len(deviceClasses))
+	for _, deviceClass := range deviceClasses {
+		reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+		bindingMode := storagev1.VolumeBindingWaitForFirstConsumer
+		fsType := deviceClass.FSType
+		if len(fsType) == 0 {
+			fsType = lvmDefaultFSType
+		}
+		storageClasses = append(storageClasses, storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: deviceClass.Name,
+			},
+			Provisioner:          csiDeploy.Spec.DriverName,
+			ReclaimPolicy:        &reclaimPolicy,
+			VolumeBindingMode:    &bindingMode,
+			AllowVolumeExpansion: boolPtr(true),
+			Parameters: map[string]string{
+				lvmDeviceClassKey:           deviceClass.Name,
+				"csi.storage.k8s.io/fstype": fsType,
+			},
+		})
+	}
+	return storageClasses
+}
@@ -0,0 +1,92 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"reflect"
+	"testing"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGenerateLVMConfigMap(t *testing.T) {
+	csiDeploy := &csiv1.CSI{
+		ObjectMeta: metav1.ObjectMeta{Name: "lvm", Namespace: "kube-system"},
+		Spec:       csiv1.CSISpec{DriverName: csiv1.CSIDriverLVM},
+	}
+	spareGB := int64(10)
+
+	testCases := []struct {
+		name          string
+		deviceClasses []csiv1.CSILVMDeviceClass
+		expected      string
+	}{
+		{
+			name:          "no device class",
+			deviceClasses: nil,
+			expected:      "device-classes: []\n",
+		},
+		{
+			name: "first device class as default",
+			deviceClasses: []csiv1.CSILVMDeviceClass{
+				{Name: "ssd", VolumeGroup: "vg-ssd"},
+				{Name: "hdd", VolumeGroup: "vg-hdd"},
+			},
+			expected: `device-classes:
+- default: true
+  name: ssd
+  volume-group: vg-ssd
+- name: hdd
+  volume-group: vg-hdd
+`,
+		},
+		{
+			name: "explicit default device class",
+			deviceClasses: []csiv1.CSILVMDeviceClass{
+				{Name: "ssd", VolumeGroup: "vg-ssd"},
+				{Name: "hdd", VolumeGroup: "vg-hdd", Default: true, SpareGB: &spareGB},
+			},
+			expected: `device-classes:
+- name: ssd
+  volume-group: vg-ssd
+- default: true
+  name: hdd
+  spare-gb: 10
+  volume-group: vg-hdd
+`,
+		},
+	}
+
+	for i, testCase := range testCases {
+		configMap, err := generateLVMConfigMap(csiDeploy, testCase.deviceClasses)
+		if err != nil {
+			t.Errorf("case %d(%s): generate ConfigMap failed: %v", i, testCase.name, err)
+			continue
+		}
+		expected := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "topolvm-io-conf", Namespace: "kube-system"},
+			Data:       map[string]string{"lvmd.yaml": testCase.expected},
+		}
+		if !reflect.DeepEqual(configMap, expected) {
+			t.Errorf("case %d(%s): expect %+v, got %+v", i, testCase.name, expected, configMap)
+		}
+	}
+}
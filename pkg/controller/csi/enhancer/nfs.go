@@ -0,0 +1,199 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"fmt"
+	"strings"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/config"
+	"tkestack.io/csi-operator/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Deprecated parameters of a single share, used if the nfs section is not set.
+	nfsServer       = "server"
+	nfsShare        = "share"
+	nfsSubDir       = "subDir"
+	nfsMountOptions = "mountOptions"
+
+	// nfsStorageClassName is the name of the StorageClass of the share set by parameters.
+	nfsStorageClassName = "nfs"
+	// nfsDefaultShare is the share used if not set.
+	nfsDefaultShare = "/"
+)
+
+// nfsSubDirTemplates are the templates supported in subDir, which are replaced by the driver.
+var nfsSubDirTemplates = sets.NewString("pvc.metadata.name", "pvc.metadata.namespace", "pv.metadata.name")
+
+func init() {
+	Register(&Driver{
+		Name:        csiv1.CSIDriverNFS,
+		Versions:    csiVersionMap[csiv1.CSIDriverNFS],
+		NewEnhancer: newNFSEnhancer,
+		Validate:    validateNFSParameters,
+	})
+}
+
+// newNFSEnhancer creates a nfsEnhancer.
+func newNFSEnhancer(config *config.Config, _ client.Reader) Enhancer {
+	return &nfsEnhancer{config: config}
+}
+
+// nfsEnhancer is an Enhancer for the NFS driver.
+type nfsEnhancer struct {
+	config *config.Config
+}
+
+// Enhance enhances CSI for NFS storage.
+func (e *nfsEnhancer) Enhance(csiDeploy *csiv1.CSI) error {
+	csiVersion, err := EnhanceComponents(e.config, csiDeploy, nfsLivenessProbePorts, nfsMetricsPorts)
+	if err != nil {
+		return err
+	}
+	if csiDeploy.Spec.Version == csiv1.CSIVersionV1p1 {
+		// Pass the names of the PVC and PV to the driver for the templates of subDir.
+		csiDeploy.Spec.Controller.Provisioner.Parameters = map[string]string{
+			types.ExtraCreateMetadataKey: "true",
+		}
+	}
+
+	csiDeploy.Spec.DriverTemplate = e.generateNFSDriverTemplate(csiVersion)
+
+	shares := getNFSShares(csiDeploy)
+	if len(shares) == 0 {
+		return fmt.Errorf("no NFS shares, nfs.shares or the %s parameter must be set", nfsServer)
+	}
+	csiDeploy.Spec.StorageClasses = generateNFSStorageClasses(csiDeploy, csiVersion, shares)
+
+	return nil
+}
+
+// generateNFSDriverTemplate generates the content of DriverTemplate for NFS.
+func (e *nfsEnhancer) generateNFSDriverTemplate(csiVersion *ComponentImages) *csiv1.CSIDriverTemplate {
+	return &csiv1.CSIDriverTemplate{
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				HostNetwork: true,
+				DNSPolicy:   corev1.DNSClusterFirstWithHostNet,
+				Tolerations: []corev1.Toleration{
+					{
+						Key:    "node-role.kubernetes.io/master",
+						Effect: corev1.TaintEffectNoSchedule,
+					},
+				},
+				Containers: []corev1.Container{
+					{
+						Name: "nfs",
+						SecurityContext: &corev1.SecurityContext{
+							Privileged: boolPtr(true),
+							Capabilities: &corev1.Capabilities{
+								Add: []corev1.Capability{"SYS_ADMIN"},
+							},
+							AllowPrivilegeEscalation: boolPtr(true),
+						},
+						Image: GetImage(e.config.RegistryDomain, csiVersion.Driver),
+						Args: []string{
+							"--v=5",
+							"--nodeid=$(NODE_ID)",
+							"--endpoint=$(CSI_ENDPOINT)",
+						},
+						Env: []corev1.EnvVar{
+							{
+								Name: "NODE_ID",
+								ValueFrom: &corev1.EnvVarSource{
+									FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+								},
+							},
+						},
+						ImagePullPolicy: corev1.PullIfNotPresent,
+					},
+				},
+			},
+		},
+	}
+}
+
+// getNFSShares returns the shares of the nfs section, or the share set by the deprecated parameters.
+func getNFSShares(csiDeploy *csiv1.CSI) []csiv1.CSINFSShare {
+	if nfs := csiDeploy.Spec.NFS; nfs != nil {
+		return nfs.Shares
+	}
+	server := csiDeploy.Spec.Parameters[nfsServer]
+	if len(server) == 0 {
+		return nil
+	}
+	share := csiv1.CSINFSShare{
+		Name:   nfsStorageClassName,
+		Server: server,
+		Share:  csiDeploy.Spec.Parameters[nfsShare],
+		SubDir: csiDeploy.Spec.Parameters[nfsSubDir],
+	}
+	if mountOptions := csiDeploy.Spec.Parameters[nfsMountOptions]; len(mountOptions) > 0 {
+		for _, option := range strings.Split(mountOptions, ",") {
+			if option = strings.TrimSpace(option); len(option) > 0 {
+				share.MountOptions = append(share.MountOptions, option)
+			}
+		}
+	}
+	return []csiv1.CSINFSShare{share}
+}
+
+// generateNFSStorageClasses generates a StorageClass for each share.
+func generateNFSStorageClasses(
+	csiDeploy *csiv1.CSI,
+	csiVersion *ComponentImages,
+	shares []csiv1.CSINFSShare) []storagev1.StorageClass {
+	storageClasses := make([]storagev1.StorageClass, 0, len(shares))
+	for _, share := range shares {
+		reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+		if share.ReclaimPolicy != nil {
+			reclaimPolicy = *share.ReclaimPolicy
+		}
+		sc := storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: share.Name,
+			},
+			Provisioner:   csiDeploy.Spec.DriverName,
+			ReclaimPolicy: &reclaimPolicy,
+			Parameters: map[string]string{
+				"server": share.Server,
+				"share":  share.Share,
+			},
+			MountOptions: append([]string(nil), share.MountOptions...),
+		}
+		if len(share.Share) == 0 {
+			sc.Parameters["share"] = nfsDefaultShare
+		}
+		if len(share.SubDir) > 0 {
+			sc.Parameters["subDir"] = share.SubDir
+		}
+		if len(csiVersion.Resizer) > 0 {
+			sc.AllowVolumeExpansion = boolPtr(true)
+		}
+		storageClasses = append(storageClasses, sc)
+	}
+	return storageClasses
+}
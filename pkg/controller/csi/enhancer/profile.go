@@ -0,0 +1,270 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/config"
+	"tkestack.io/csi-operator/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// profileReadTimeout is the timeout of listing the DriverProfiles.
+	profileReadTimeout = time.Minute
+)
+
+// profileVariable matches the ${name} variables substituted in the DriverProfiles.
+var profileVariable = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// getDriverProfile returns the DriverProfile of a driver, or nil if the driver is not described by a DriverProfile.
+// If several DriverProfiles describe the same driver, the oldest one wins.
+func (e *enhancer) getDriverProfile(driverName string) (*csiv1.DriverProfile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), profileReadTimeout)
+	defer cancel()
+
+	profiles := &csiv1.DriverProfileList{}
+	if err := e.reader.List(ctx, profiles); err != nil {
+		return nil, fmt.Errorf("list DriverProfiles failed: %s", err.Error())
+	}
+
+	var matched []*csiv1.DriverProfile
+	for i := range profiles.Items {
+		if profiles.Items[i].Spec.DriverName == driverName {
+			matched = append(matched, &profiles.Items[i])
+		}
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		ti, tj := matched[i].CreationTimestamp, matched[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return matched[i].Name < matched[j].Name
+	})
+	return matched[0], nil
+}
+
+// profileEnhancer enhances the CSI objects of a driver described by a DriverProfile.
+type profileEnhancer struct {
+	config  *config.Config
+	profile *csiv1.DriverProfile
+}
+
+// newProfileEnhancer creates an Enhancer of a DriverProfile.
+func newProfileEnhancer(config *config.Config, profile *csiv1.DriverProfile) Enhancer {
+	return &profileEnhancer{config: config, profile: profile}
+}
+
+// Enhance fills the components, the driver template and the StorageClasses of a CSI object by the DriverProfile.
+func (e *profileEnhancer) Enhance(csiDeploy *csiv1.CSI) error {
+	spec := &e.profile.Spec
+	images, err := getProfileImages(e.profile, csiDeploy)
+	if err != nil {
+		return err
+	}
+	enhanceExternalComponents(e.config, csiDeploy, images)
+	if ports := spec.LivenessProbePorts; ports != nil {
+		setLivenessProbePort(csiDeploy.Spec.Node.LivenessProbe, ports.Node)
+		setLivenessProbePort(csiDeploy.Spec.Controller.LivenessProbe, ports.Controller)
+	}
+	if ports := spec.MetricsPorts; ports != nil {
+		fillMetricsPorts(csiDeploy, MetricsPorts{Node: ports.Node, Controller: ports.Controller})
+	}
+
+	values := profileValues(e.profile, csiDeploy)
+
+	driverTemplate := spec.DriverTemplate.DeepCopy()
+	driverImage := GetImage(e.config.RegistryDomain, images.Driver)
+	if err := substituteTemplate(&driverTemplate.Template, driverImage, values); err != nil {
+		return err
+	}
+	if driverTemplate.ControllerTemplate != nil {
+		if err := substituteTemplate(driverTemplate.ControllerTemplate, driverImage, values); err != nil {
+			return err
+		}
+	}
+	csiDeploy.Spec.DriverTemplate = driverTemplate
+
+	storageClasses := make([]storagev1.StorageClass, 0, len(spec.StorageClasses))
+	for i := range spec.StorageClasses {
+		sc := spec.StorageClasses[i].DeepCopy()
+		sc.Provisioner = csiDeploy.Spec.DriverName
+		for key, value := range sc.Parameters {
+			if sc.Parameters[key], err = substitute(value, values); err != nil {
+				return err
+			}
+		}
+		for j := range sc.MountOptions {
+			if sc.MountOptions[j], err = substitute(sc.MountOptions[j], values); err != nil {
+				return err
+			}
+		}
+		storageClasses = append(storageClasses, *sc)
+	}
+	csiDeploy.Spec.StorageClasses = storageClasses
+
+	return nil
+}
+
+// getProfileImages returns the images of the version of a CSI object described by a DriverProfile.
+func getProfileImages(profile *csiv1.DriverProfile, csiDeploy *csiv1.CSI) (*ComponentImages, error) {
+	version := getProfileVersion(profile, csiDeploy.Spec.Version)
+	if version == nil {
+		return nil, fmt.Errorf("unknown CSI version %s", csiDeploy.Spec.Version)
+	}
+
+	images := &ComponentImages{
+		Provisioner:      version.Provisioner,
+		Attacher:         version.Attacher,
+		Resizer:          version.Resizer,
+		Snapshotter:      version.Snapshotter,
+		LivenessProbe:    version.LivenessProbe,
+		NodeRegistrar:    version.NodeRegistrar,
+		ClusterRegistrar: version.ClusterRegistrar,
+		Driver:           version.Driver,
+	}
+	if len(csiDeploy.Spec.DriverVersion) > 0 {
+		images.Driver = csiDeploy.Spec.DriverVersion
+	}
+	return images, nil
+}
+
+// getProfileVersion returns the images of a version in a DriverProfile, or nil if the version is not supported.
+func getProfileVersion(profile *csiv1.DriverProfile, version csiv1.CSIVersion) *csiv1.DriverProfileVersion {
+	for i := range profile.Spec.Versions {
+		if profile.Spec.Versions[i].Version == version {
+			return &profile.Spec.Versions[i]
+		}
+	}
+	return nil
+}
+
+// setLivenessProbePort sets the port of a livenessprobe sidecar, if both of them are set.
+func setLivenessProbePort(component *csiv1.CSIComponent, port int32) {
+	if component == nil || port == 0 {
+		return
+	}
+	component.Parameters = map[string]string{
+		types.LivenessProbePortKey: strconv.Itoa(int(port)),
+	}
+}
+
+// profileValues returns the values of the variables of a CSI object described by a DriverProfile.
+func profileValues(profile *csiv1.DriverProfile, csiDeploy *csiv1.CSI) map[string]string {
+	values := make(map[string]string, len(profile.Spec.Parameters)+2)
+	for _, param := range profile.Spec.Parameters {
+		if len(param.Default) > 0 {
+			values[param.Name] = param.Default
+		}
+	}
+	for key, value := range csiDeploy.Spec.Parameters {
+		values[key] = value
+	}
+	values["csi.name"] = csiDeploy.Name
+	values["csi.namespace"] = csiDeploy.Namespace
+	return values
+}
+
+// substitute replaces the ${name} variables of a string by the values.
+func substitute(s string, values map[string]string) (string, error) {
+	var missing []string
+	result := profileVariable.ReplaceAllStringFunc(s, func(variable string) string {
+		name := strings.TrimSpace(profileVariable.FindStringSubmatch(variable)[1])
+		value, exist := values[name]
+		if !exist {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("parameters %s are not set", strings.Join(missing, ", "))
+	}
+	return result, nil
+}
+
+// substituteTemplate sets the default image of the driver container of a template,
+// and substitutes the variables in its args and envs.
+func substituteTemplate(template *corev1.PodTemplateSpec, image string, values map[string]string) error {
+	if len(template.Spec.Containers) != 1 {
+		return fmt.Errorf("driverTemplate must contain one and only one container")
+	}
+	container := &template.Spec.Containers[0]
+	if len(container.Image) == 0 {
+		container.Image = image
+	}
+	var err error
+	for i := range container.Args {
+		if container.Args[i], err = substitute(container.Args[i], values); err != nil {
+			return err
+		}
+	}
+	for i := range container.Env {
+		if container.Env[i].Value, err = substitute(container.Env[i].Value, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateProfileParameters checks whether the version of a CSI object is supported by its DriverProfile,
+// and the required parameters are set.
+func validateProfileParameters(profile *csiv1.DriverProfile, csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if csiDeploy.Spec.Ceph != nil {
+		errs = append(errs, field.Forbidden(fieldPath.Child("ceph"), "only supported by Ceph drivers"))
+	}
+	if csiDeploy.Spec.TencentCloud != nil {
+		errs = append(errs, field.Forbidden(fieldPath.Child("tencentCloud"), "only supported by Tencent Cloud drivers"))
+	}
+	if csiDeploy.Spec.NFS != nil {
+		errs = append(errs, field.Forbidden(fieldPath.Child("nfs"), "only supported by the NFS driver"))
+	}
+	if csiDeploy.Spec.LVM != nil {
+		errs = append(errs, field.Forbidden(fieldPath.Child("lvm"), "only supported by the LVM driver"))
+	}
+
+	if getProfileVersion(profile, csiDeploy.Spec.Version) == nil {
+		versions := make([]string, 0, len(profile.Spec.Versions))
+		for _, version := range profile.Spec.Versions {
+			versions = append(versions, string(version.Version))
+		}
+		errs = append(errs, field.NotSupported(fieldPath.Child("version"), csiDeploy.Spec.Version, versions))
+	}
+
+	for _, param := range profile.Spec.Parameters {
+		if param.Required && len(param.Default) == 0 && len(csiDeploy.Spec.Parameters[param.Name]) == 0 {
+			errs = append(errs, field.Required(fieldPath.Child("parameters").Key(param.Name),
+				fmt.Sprintf("required by DriverProfile %s", profile.Name)))
+		}
+	}
+	return errs
+}
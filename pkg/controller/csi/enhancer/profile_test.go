@@ -0,0 +1,111 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"testing"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSubstitute(t *testing.T) {
+	values := map[string]string{
+		"server":        "10.0.0.1",
+		"csi.namespace": "kube-system",
+		"empty":         "",
+	}
+
+	testCases := []struct {
+		s         string
+		expected  string
+		expectErr bool
+	}{
+		{s: "", expected: ""},
+		{s: "--v=5", expected: "--v=5"},
+		{s: "--server=${server}", expected: "--server=10.0.0.1"},
+		{s: "${ server }:${csi.namespace}", expected: "10.0.0.1:kube-system"},
+		{s: "--opt=${empty}", expected: "--opt="},
+		{s: "$(CSI_ENDPOINT)", expected: "$(CSI_ENDPOINT)"},
+		{s: "${server}/${share}/${path}", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		result, err := substitute(tc.s, values)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("%q: expected an error", tc.s)
+			}
+			continue
+		}
+		if err != nil || result != tc.expected {
+			t.Errorf("%q: expected %q, got %q, %v", tc.s, tc.expected, result, err)
+		}
+	}
+}
+
+func TestProfileValues(t *testing.T) {
+	profile := &csiv1.DriverProfile{}
+	profile.Spec.Parameters = []csiv1.DriverProfileParameter{
+		{Name: "server", Default: "10.0.0.1"},
+		{Name: "share", Default: "/exports"},
+		{Name: "path"},
+	}
+	csiDeploy := &csiv1.CSI{ObjectMeta: metav1.ObjectMeta{Name: "nfs", Namespace: "kube-system"}}
+	csiDeploy.Spec.Parameters = map[string]string{"share": "/data"}
+
+	values := profileValues(profile, csiDeploy)
+	for name, expected := range map[string]string{
+		"server":        "10.0.0.1",
+		"share":         "/data",
+		"csi.name":      "nfs",
+		"csi.namespace": "kube-system",
+	} {
+		if values[name] != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, values[name])
+		}
+	}
+	if _, exist := values["path"]; exist {
+		t.Errorf("path without default should not be set")
+	}
+}
+
+func TestSubstituteTemplate(t *testing.T) {
+	template := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "driver",
+			Args: []string{"--server=${server}"},
+			Env:  []corev1.EnvVar{{Name: "SERVER", Value: "${server}"}},
+		}}},
+	}
+	if err := substituteTemplate(template, "driver:v1", map[string]string{"server": "10.0.0.1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	container := template.Spec.Containers[0]
+	if container.Image != "driver:v1" || container.Args[0] != "--server=10.0.0.1" ||
+		container.Env[0].Value != "10.0.0.1" {
+		t.Errorf("unexpected container %+v", container)
+	}
+
+	template.Spec.Containers = append(template.Spec.Containers, corev1.Container{Name: "sidecar"})
+	if err := substituteTemplate(template, "driver:v1", nil); err == nil {
+		t.Errorf("expected an error for a template with two containers")
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"fmt"
+	"sort"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/config"
+	"tkestack.io/csi-operator/pkg/types"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Driver describes a well known driver, CSI objects of which only need to set the version.
+type Driver struct {
+	// Name of the driver, which is the driverName of the CSI objects.
+	Name string
+	// Versions are the images of the components of each supported version.
+	Versions map[csiv1.CSIVersion]*ComponentImages
+	// NewEnhancer creates the Enhancer generating the driver template, Secrets, StorageClasses and so on.
+	NewEnhancer func(config *config.Config, reader client.Reader) Enhancer
+	// Rules are the PolicyRules needed by the driver besides the ones in the driver template.
+	// +optional
+	Rules []rbacv1.PolicyRule
+	// Validate checks the parameters of the CSI objects of the driver.
+	// +optional
+	Validate func(csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList
+	// TopologyKey is the node label key of the zone reported by a zonal driver.
+	// +optional
+	TopologyKey string
+}
+
+// drivers are the registered well known drivers.
+var drivers = make(map[string]*Driver)
+
+// Register registers a well known driver. Drivers maintained out of the tree register themselves in the
+// init function of their packages, which are imported by the main package. It must be called before New,
+// and panics if the driver is invalid or registered twice.
+func Register(driver *Driver) {
+	if len(driver.Name) == 0 || len(driver.Versions) == 0 || driver.NewEnhancer == nil {
+		panic(fmt.Sprintf("name, versions and enhancer of driver %q must be set", driver.Name))
+	}
+	if _, exist := drivers[driver.Name]; exist {
+		panic(fmt.Sprintf("driver %s is registered twice", driver.Name))
+	}
+	drivers[driver.Name] = driver
+}
+
+// getDriver returns a registered driver, or nil if the driver is not registered.
+func getDriver(name string) *Driver {
+	return drivers[name]
+}
+
+// registeredDrivers returns the sorted names of the registered drivers.
+func registeredDrivers() []string {
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TopologyKey returns the zone topology key of a registered driver, or an empty string if the driver
+// is not zonal.
+func TopologyKey(driverName string) string {
+	if driver := getDriver(driverName); driver != nil {
+		return driver.TopologyKey
+	}
+	return ""
+}
+
+// DriverRules returns the PolicyRules needed by a registered driver besides the ones of its sidecars.
+func DriverRules(driverName string) []rbacv1.PolicyRule {
+	if driver := getDriver(driverName); driver != nil {
+		return driver.Rules
+	}
+	return nil
+}
+
+// EnhanceComponents fills the sidecars of a CSI object with the images of its version and the ports,
+// and returns the images of the version.
+func EnhanceComponents(
+	config *config.Config,
+	csiDeploy *csiv1.CSI,
+	livenessProbePorts LivenessProbePorts,
+	metricsPorts MetricsPorts) (*ComponentImages, error) {
+	images, err := getCSIVersion(csiDeploy)
+	if err != nil {
+		return nil, err
+	}
+	enhanceExternalComponents(config, csiDeploy, images)
+	if csiDeploy.Spec.Node.LivenessProbe != nil {
+		csiDeploy.Spec.Node.LivenessProbe.Parameters = map[string]string{
+			types.LivenessProbePortKey: livenessProbePorts.Node,
+		}
+	}
+	if csiDeploy.Spec.Controller.LivenessProbe != nil {
+		csiDeploy.Spec.Controller.LivenessProbe.Parameters = map[string]string{
+			types.LivenessProbePortKey: livenessProbePorts.Controller,
+		}
+	}
+	fillMetricsPorts(csiDeploy, metricsPorts)
+	return images, nil
+}
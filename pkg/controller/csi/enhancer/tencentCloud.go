@@ -23,13 +23,13 @@ import (
 
 	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
 	"tkestack.io/csi-operator/pkg/config"
-	"tkestack.io/csi-operator/pkg/types"
 
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
@@ -42,49 +42,86 @@ const (
 	// TencentCloudAPISecretKey represents the name of tencent cloud secret key's environment variable,
 	// which used in tencent cloud's csi plugin.
 	TencentCloudAPISecretKey = "TENCENTCLOUD_API_SECRET_KEY"
+	// TencentCloudCFSAPISecretID represents the name of tencent cloud secret id's environment variable,
+	// which used in tencent cloud's cfs csi plugin.
+	TencentCloudCFSAPISecretID = "TENCENTCLOUD_CFS_API_SECRET_ID"
+	// TencentCloudCFSAPISecretKey represents the name of tencent cloud secret key's environment variable,
+	// which used in tencent cloud's cfs csi plugin.
+	TencentCloudCFSAPISecretKey = "TENCENTCLOUD_CFS_API_SECRET_KEY"
+
+	cfsVPCID    = "vpcId"
+	cfsSubnetID = "subnetId"
+	cfsZone     = "zone"
+	cfsPGroupID = "pgroupId"
 )
 
+// cfsStorageClass describes a default StorageClass of CFS.
+type cfsStorageClass struct {
+	Name        string
+	StorageType string
+	Protocol    string
+}
+
+// cfsStorageClasses are the default StorageClasses of CFS, covering the standard (SD) and
+// high performance (HP) storage types with NFS v3 and v4.
+var cfsStorageClasses = []cfsStorageClass{
+	{Name: "cfs-standard", StorageType: "SD", Protocol: "3"},
+	{Name: "cfs-standard-nfsv4", StorageType: "SD", Protocol: "4"},
+	{Name: "cfs-performance", StorageType: "HP", Protocol: "3"},
+	{Name: "cfs-performance-nfsv4", StorageType: "HP", Protocol: "4"},
+}
+
 // tencentCloudInfo if a set of information of TencentCloud secrets.
 type tencentCloudInfo struct {
 	SecretID  string
 	SecretKey string
 }
 
+func init() {
+	for _, name := range []string{csiv1.CSIDriverTencentCBS, csiv1.CSIDriverTencentCFS, csiv1.CSIDriverTencentCOS} {
+		driver := &Driver{
+			Name:        name,
+			Versions:    csiVersionMap[name],
+			NewEnhancer: newTencentCloudEnhancer,
+			Validate:    validateTencentCloudParameters,
+		}
+		if name == csiv1.CSIDriverTencentCBS {
+			driver.TopologyKey = "topology.com.tencent.cloud.csi.cbs/zone"
+		}
+		Register(driver)
+	}
+}
+
 // newTencentCloudEnhancer creates a tencentCloudEnhancer.
-func newTencentCloudEnhancer(config *config.Config) Enhancer {
-	return &tencentCloudEnhancer{config}
+func newTencentCloudEnhancer(config *config.Config, reader client.Reader) Enhancer {
+	return &tencentCloudEnhancer{config: config, reader: reader}
 }
 
 // tencentCloudEnhancer is an Enhancer for TencentCloud storage.
 type tencentCloudEnhancer struct {
 	config *config.Config
+	reader client.Reader
 }
 
 // Enhance enhances a well known CSI type.
 func (e *tencentCloudEnhancer) Enhance(csiDeploy *csiv1.CSI) error {
-	if csiDeploy.Spec.DriverName == csiv1.CSIDriverTencentCBS {
+	switch csiDeploy.Spec.DriverName {
+	case csiv1.CSIDriverTencentCBS:
 		return e.enhanceTencentCBS(csiDeploy)
+	case csiv1.CSIDriverTencentCFS:
+		return e.enhanceTencentCFS(csiDeploy)
+	case csiv1.CSIDriverTencentCOS:
+		return e.enhanceTencentCOS(csiDeploy)
 	}
 	return fmt.Errorf("unknown type: %s", csiDeploy.Spec.DriverName)
 }
 
 // enhanceTencentCBS enhances CSI for TencentCloud CBS storage.
 func (e *tencentCloudEnhancer) enhanceTencentCBS(csiDeploy *csiv1.CSI) error {
-	csiVersion, err := getCSIVersion(csiDeploy)
+	csiVersion, err := EnhanceComponents(e.config, csiDeploy, tencentCBSLivenessProbePorts, tencentCBSMetricsPorts)
 	if err != nil {
 		return err
 	}
-	enhanceExternalComponents(e.config, csiDeploy, csiVersion)
-	if csiDeploy.Spec.Node.LivenessProbe != nil {
-		csiDeploy.Spec.Node.LivenessProbe.Parameters = map[string]string{
-			types.LivenessProbePortKey: tencentCBSLivenessProbePorts.Node,
-		}
-	}
-	if csiDeploy.Spec.Controller.LivenessProbe != nil {
-		csiDeploy.Spec.Controller.LivenessProbe.Parameters = map[string]string{
-			types.LivenessProbePortKey: tencentCBSLivenessProbePorts.Controller,
-		}
-	}
 
 	csiDeploy.Spec.DriverTemplate = e.generateDriverTemplate(csiVersion, csiDeploy)
 
@@ -115,7 +152,7 @@ func (e *tencentCloudEnhancer) enhanceTencentCBS(csiDeploy *csiv1.CSI) error {
 
 // generateDriverTemplate generates the content of DriverTemplate.
 func (e *tencentCloudEnhancer) generateDriverTemplate(
-	csiVersion *csiVersion,
+	csiVersion *ComponentImages,
 	csiDeploy *csiv1.CSI) *csiv1.CSIDriverTemplate {
 	return &csiv1.CSIDriverTemplate{
 		Template: corev1.PodTemplateSpec{
@@ -140,7 +177,7 @@ func (e *tencentCloudEnhancer) generateDriverTemplate(
 							},
 							AllowPrivilegeEscalation: boolPtr(true),
 						},
-						Image: getImage(e.config.RegistryDomain, csiVersion.Driver),
+						Image: GetImage(e.config.RegistryDomain, csiVersion.Driver),
 						Command: []string{
 							"/bin/csi-tencentcloud",
 						},
@@ -200,11 +237,14 @@ func (e *tencentCloudEnhancer) generateDriverTemplate(
 // getTencentInfo generates TencentCloud information from CSI object and global config.
 func (e *tencentCloudEnhancer) getTencentInfo(csiDeploy *csiv1.CSI) (*tencentCloudInfo, error) {
 	secretID := csiDeploy.Spec.Parameters[secretID]
+	secretKey := csiDeploy.Spec.Parameters[secretKey]
+	if tencentCloud := csiDeploy.Spec.TencentCloud; tencentCloud != nil {
+		secretID, secretKey = tencentCloud.SecretID, tencentCloud.SecretKey
+	}
+
 	if len(secretID) == 0 {
 		secretID = e.config.SecretID
 	}
-
-	secretKey := csiDeploy.Spec.Parameters[secretKey]
 	if len(secretKey) == 0 {
 		secretKey = e.config.SecretKey
 	}
@@ -220,26 +260,10 @@ func (e *tencentCloudEnhancer) generateSecretAndSCs(
 	csiDeploy *csiv1.CSI,
 	tencentInfo *tencentCloudInfo) ([]corev1.Secret, []storagev1.StorageClass, error) {
 	// Generate secrets.
-	secretName := getSecretName(csiDeploy)
-	secret := corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      secretName,
-			Namespace: csiDeploy.Namespace,
-		},
-	}
-
-	secretID, err := base64.StdEncoding.DecodeString(tencentInfo.SecretID)
-	if err != nil {
-		return nil, nil, fmt.Errorf("secretID decoding failed: %v", err)
-	}
-	secretKey, err := base64.StdEncoding.DecodeString(tencentInfo.SecretKey)
+	secret, err := generateTencentCloudSecret(csiDeploy, tencentInfo,
+		TencentCloudAPISecretID, TencentCloudAPISecretKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("secretKey decoding failed: %v", err)
-	}
-
-	secret.Data = map[string][]byte{
-		TencentCloudAPISecretID:  secretID,
-		TencentCloudAPISecretKey: secretKey,
+		return nil, nil, err
 	}
 
 	// Generate storageClasses.
@@ -281,5 +305,186 @@ func (e *tencentCloudEnhancer) generateSecretAndSCs(
 		},
 	}
 
-	return []corev1.Secret{secret}, []storagev1.StorageClass{basicSC, premiumSC, ssdSC}, nil
+	return []corev1.Secret{*secret}, []storagev1.StorageClass{basicSC, premiumSC, ssdSC}, nil
+}
+
+// generateTencentCloudSecret generates the secret of the base64 encoded TencentCloud secret id and key,
+// stored with the keys read by the driver.
+func generateTencentCloudSecret(
+	csiDeploy *csiv1.CSI,
+	tencentInfo *tencentCloudInfo,
+	idKey, keyKey string) (*corev1.Secret, error) {
+	secretID, err := base64.StdEncoding.DecodeString(tencentInfo.SecretID)
+	if err != nil {
+		return nil, fmt.Errorf("secretID decoding failed: %v", err)
+	}
+	secretKey, err := base64.StdEncoding.DecodeString(tencentInfo.SecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("secretKey decoding failed: %v", err)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getSecretName(csiDeploy),
+			Namespace: csiDeploy.Namespace,
+		},
+		Data: map[string][]byte{
+			idKey:  secretID,
+			keyKey: secretKey,
+		},
+	}, nil
+}
+
+// enhanceTencentCFS enhances CSI for TencentCloud CFS storage.
+func (e *tencentCloudEnhancer) enhanceTencentCFS(csiDeploy *csiv1.CSI) error {
+	csiVersion, err := EnhanceComponents(e.config, csiDeploy, tencentCFSLivenessProbePorts, tencentCFSMetricsPorts)
+	if err != nil {
+		return err
+	}
+
+	csiDeploy.Spec.DriverTemplate = e.generateCFSDriverTemplate(csiVersion, csiDeploy)
+
+	tencentInfo, err := e.getTencentInfo(csiDeploy)
+	if err != nil {
+		return fmt.Errorf("get tencent info failed: %v", err)
+	}
+
+	csiDeploy.Spec.Secrets, csiDeploy.Spec.StorageClasses, err = e.generateCFSSecretAndSCs(
+		csiDeploy, tencentInfo)
+	if err != nil {
+		return fmt.Errorf("enhance TencentCloud CFS Secret or StorageClasses failed: %v", err)
+	}
+
+	return nil
+}
+
+// generateCFSDriverTemplate generates the content of DriverTemplate for CFS.
+func (e *tencentCloudEnhancer) generateCFSDriverTemplate(
+	csiVersion *ComponentImages,
+	csiDeploy *csiv1.CSI) *csiv1.CSIDriverTemplate {
+	return &csiv1.CSIDriverTemplate{
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				HostNetwork: true,
+				DNSPolicy:   corev1.DNSClusterFirstWithHostNet,
+				Tolerations: []corev1.Toleration{
+					{
+						Key:    "node-role.kubernetes.io/master",
+						Effect: corev1.TaintEffectNoSchedule,
+					},
+				},
+				Containers: []corev1.Container{
+					{
+						Name: "com-tencent-cloud-csi-cfs",
+						SecurityContext: &corev1.SecurityContext{
+							Privileged: boolPtr(true),
+							Capabilities: &corev1.Capabilities{
+								Add: []corev1.Capability{"SYS_ADMIN"},
+							},
+							AllowPrivilegeEscalation: boolPtr(true),
+						},
+						Image: GetImage(e.config.RegistryDomain, csiVersion.Driver),
+						Command: []string{
+							"/csi-tencentcloud-cfs",
+						},
+						Args: []string{
+							"--v=5",
+							"--logtostderr=true",
+							"--nodeID=$(NODE_ID)",
+							"--endpoint=$(CSI_ENDPOINT)",
+						},
+						Env: []corev1.EnvVar{
+							{
+								Name: "NODE_ID",
+								ValueFrom: &corev1.EnvVarSource{
+									FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+								},
+							},
+							{
+								Name: TencentCloudCFSAPISecretID,
+								ValueFrom: &corev1.EnvVarSource{
+									SecretKeyRef: &corev1.SecretKeySelector{
+										LocalObjectReference: corev1.LocalObjectReference{
+											Name: getSecretName(csiDeploy),
+										},
+										Key: TencentCloudCFSAPISecretID,
+									},
+								},
+							},
+							{
+								Name: TencentCloudCFSAPISecretKey,
+								ValueFrom: &corev1.EnvVarSource{
+									SecretKeyRef: &corev1.SecretKeySelector{
+										LocalObjectReference: corev1.LocalObjectReference{
+											Name: getSecretName(csiDeploy),
+										},
+										Key: TencentCloudCFSAPISecretKey,
+									},
+								},
+							},
+						},
+						ImagePullPolicy: corev1.PullAlways,
+					},
+				},
+			},
+		},
+	}
+}
+
+// generateCFSSecretAndSCs generates secrets and StorageClasses needed by TencentCloud CFS storage.
+func (e *tencentCloudEnhancer) generateCFSSecretAndSCs(
+	csiDeploy *csiv1.CSI,
+	tencentInfo *tencentCloudInfo) ([]corev1.Secret, []storagev1.StorageClass, error) {
+	secret, err := generateTencentCloudSecret(csiDeploy, tencentInfo,
+		TencentCloudCFSAPISecretID, TencentCloudCFSAPISecretKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The file systems are created in the VPC and subnet of the nodes.
+	cfs := getCFSParameters(csiDeploy)
+	vpcID, subnetID := cfs.VPCID, cfs.SubnetID
+	if len(vpcID) == 0 || len(subnetID) == 0 {
+		return nil, nil, fmt.Errorf("%s and %s must be set in parameters", cfsVPCID, cfsSubnetID)
+	}
+
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	storageClasses := make([]storagev1.StorageClass, 0, len(cfsStorageClasses))
+	for _, class := range cfsStorageClasses {
+		sc := storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: class.Name,
+			},
+			Provisioner:   csiDeploy.Spec.DriverName,
+			ReclaimPolicy: &reclaimPolicy,
+			Parameters: map[string]string{
+				"vpcid":       vpcID,
+				"subnetid":    subnetID,
+				"storagetype": class.StorageType,
+				"vers":        class.Protocol,
+			},
+		}
+		if len(cfs.Zone) > 0 {
+			sc.Parameters["zone"] = cfs.Zone
+		}
+		if len(cfs.PGroupID) > 0 {
+			sc.Parameters["pgroupid"] = cfs.PGroupID
+		}
+		storageClasses = append(storageClasses, sc)
+	}
+
+	return []corev1.Secret{*secret}, storageClasses, nil
+}
+
+// getCFSParameters returns the CFS section, or the one read from the deprecated parameters.
+func getCFSParameters(csiDeploy *csiv1.CSI) *csiv1.CSITencentCloudCFS {
+	if tencentCloud := csiDeploy.Spec.TencentCloud; tencentCloud != nil && tencentCloud.CFS != nil {
+		return tencentCloud.CFS
+	}
+	return &csiv1.CSITencentCloudCFS{
+		VPCID:    csiDeploy.Spec.Parameters[cfsVPCID],
+		SubnetID: csiDeploy.Spec.Parameters[cfsSubnetID],
+		Zone:     csiDeploy.Spec.Parameters[cfsZone],
+		PGroupID: csiDeploy.Spec.Parameters[cfsPGroupID],
+	}
 }
@@ -0,0 +1,309 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// cosBuckets is the parameter listing the buckets to mount and the Secrets holding
+	// their credentials, in the format of "<bucket>=<namespace>/<secret>,...". The Secrets
+	// must be in the namespace of the CSI object.
+	cosBuckets = "buckets"
+
+	// COSSecretID is the key of the secret id in the credential Secrets of COS buckets.
+	COSSecretID = "SecretId"
+	// COSSecretKey is the key of the secret key in the credential Secrets of COS buckets.
+	COSSecretKey = "SecretKey"
+
+	// cosLauncherDir is the host directory shared by the driver and the launcher.
+	cosLauncherDir = "/etc/csi-cos"
+
+	cosReadTimeout = time.Minute
+)
+
+// cosBucket is a COS bucket and the Secret holding its credential.
+type cosBucket struct {
+	Name            string
+	SecretNamespace string
+	SecretName      string
+}
+
+// enhanceTencentCOS enhances CSI for TencentCloud COS storage. COS buckets are mounted
+// by cosfs, so there is no dynamic provisioning and no controller driver.
+func (e *tencentCloudEnhancer) enhanceTencentCOS(csiDeploy *csiv1.CSI) error {
+	csiVersion, err := EnhanceComponents(e.config, csiDeploy, tencentCOSLivenessProbePorts, tencentCOSMetricsPorts)
+	if err != nil {
+		return err
+	}
+	csiDeploy.Spec.Controller = csiv1.CSIController{}
+
+	csiDeploy.Spec.DriverTemplate = e.generateCOSDriverTemplate(csiVersion)
+
+	buckets, err := getCOSBuckets(csiDeploy)
+	if err != nil {
+		return err
+	}
+	csiDeploy.Spec.Secrets, err = e.generateCOSSecrets(csiDeploy, buckets)
+	if err != nil {
+		return fmt.Errorf("enhance TencentCloud COS Secrets failed: %v", err)
+	}
+	csiDeploy.Spec.StorageClasses = nil
+
+	return nil
+}
+
+// generateCOSDriverTemplate generates the content of DriverTemplate for COS. The launcher sidecar runs
+// cosfs for the driver, so that the mounts survive the restart of the driver container.
+func (e *tencentCloudEnhancer) generateCOSDriverTemplate(csiVersion *ComponentImages) *csiv1.CSIDriverTemplate {
+	bidirectional := corev1.MountPropagationBidirectional
+	return &csiv1.CSIDriverTemplate{
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				HostNetwork: true,
+				DNSPolicy:   corev1.DNSClusterFirstWithHostNet,
+				Containers: []corev1.Container{
+					{
+						Name: "com-tencent-cloud-csi-cos",
+						SecurityContext: &corev1.SecurityContext{
+							Privileged: boolPtr(true),
+							Capabilities: &corev1.Capabilities{
+								Add: []corev1.Capability{"SYS_ADMIN"},
+							},
+							AllowPrivilegeEscalation: boolPtr(true),
+						},
+						Image: GetImage(e.config.RegistryDomain, csiVersion.Driver),
+						Command: []string{
+							"/csi-tencentcloud-cos",
+						},
+						Args: []string{
+							"--v=5",
+							"--logtostderr=true",
+							"--nodeID=$(NODE_ID)",
+							"--endpoint=$(CSI_ENDPOINT)",
+						},
+						Env: []corev1.EnvVar{
+							{
+								Name: "NODE_ID",
+								ValueFrom: &corev1.EnvVarSource{
+									FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+								},
+							},
+						},
+						ImagePullPolicy: corev1.PullAlways,
+						VolumeMounts: []corev1.VolumeMount{
+							{
+								Name:      "launcher-dir",
+								MountPath: cosLauncherDir,
+							},
+						},
+					},
+				},
+				Volumes: []corev1.Volume{
+					{
+						Name: "launcher-dir",
+						VolumeSource: corev1.VolumeSource{
+							HostPath: &corev1.HostPathVolumeSource{
+								Path: cosLauncherDir,
+								Type: hostPathType(corev1.HostPathDirectoryOrCreate),
+							},
+						},
+					},
+					{
+						Name: "launcher-pods-dir",
+						VolumeSource: corev1.VolumeSource{
+							HostPath: &corev1.HostPathVolumeSource{
+								Path: filepath.Join(e.config.KubeletRootDir, "pods"),
+								Type: hostPathType(corev1.HostPathDirectoryOrCreate),
+							},
+						},
+					},
+					{
+						Name: "device-dir",
+						VolumeSource: corev1.VolumeSource{
+							HostPath: &corev1.HostPathVolumeSource{
+								Path: "/dev",
+							},
+						},
+					},
+				},
+			},
+		},
+		NodeSidecars: []corev1.Container{
+			{
+				Name: "cos-launcher",
+				SecurityContext: &corev1.SecurityContext{
+					Privileged: boolPtr(true),
+				},
+				Image:           GetImage(e.config.RegistryDomain, csiVersion.Launcher),
+				ImagePullPolicy: corev1.PullAlways,
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						Name:      "launcher-dir",
+						MountPath: cosLauncherDir,
+					},
+					{
+						Name:             "launcher-pods-dir",
+						MountPath:        filepath.Join(e.config.KubeletRootDir, "pods"),
+						MountPropagation: &bidirectional,
+					},
+					{
+						Name:      "device-dir",
+						MountPath: "/dev",
+					},
+				},
+			},
+		},
+	}
+}
+
+// generateCOSSecrets generates a credential Secret in the namespace of the CSI object for each bucket,
+// from the Secret referred by the bucket. PersistentVolumes of the bucket use it as nodePublishSecretRef.
+func (e *tencentCloudEnhancer) generateCOSSecrets(
+	csiDeploy *csiv1.CSI,
+	buckets []cosBucket) ([]corev1.Secret, error) {
+	if e.reader == nil {
+		return nil, fmt.Errorf("no reader to get the Secrets of buckets")
+	}
+
+	secrets := make([]corev1.Secret, 0, len(buckets))
+	for _, bucket := range buckets {
+		source := &corev1.Secret{}
+		key := k8stypes.NamespacedName{Namespace: bucket.SecretNamespace, Name: bucket.SecretName}
+		ctx, cancel := context.WithTimeout(context.Background(), cosReadTimeout)
+		err := e.reader.Get(ctx, key, source)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("get Secret %s of bucket %s failed: %v", key.String(), bucket.Name, err)
+		}
+		secretID, secretKey := source.Data[COSSecretID], source.Data[COSSecretKey]
+		if len(secretID) == 0 || len(secretKey) == 0 {
+			return nil, fmt.Errorf("%s and %s must be set in Secret %s of bucket %s",
+				COSSecretID, COSSecretKey, key.String(), bucket.Name)
+		}
+
+		secrets = append(secrets, corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      getCOSSecretName(bucket.Name),
+				Namespace: csiDeploy.Namespace,
+			},
+			Data: map[string][]byte{
+				COSSecretID:  secretID,
+				COSSecretKey: secretKey,
+			},
+		})
+	}
+
+	return secrets, nil
+}
+
+// getCOSBuckets returns the buckets in the COS section, or the ones in the deprecated buckets parameter.
+func getCOSBuckets(csiDeploy *csiv1.CSI) ([]cosBucket, error) {
+	tencentCloud := csiDeploy.Spec.TencentCloud
+	if tencentCloud == nil || tencentCloud.COS == nil {
+		buckets, err := parseCOSBuckets(csiDeploy.Spec.Parameters[cosBuckets])
+		if err != nil {
+			return nil, err
+		}
+		return buckets, checkCOSSecretNamespaces(csiDeploy, buckets)
+	}
+
+	buckets := make([]cosBucket, 0, len(tencentCloud.COS.Buckets))
+	for _, bucket := range tencentCloud.COS.Buckets {
+		namespace := bucket.SecretRef.Namespace
+		if len(namespace) == 0 {
+			namespace = csiDeploy.Namespace
+		}
+		buckets = append(buckets, cosBucket{
+			Name:            bucket.Name,
+			SecretNamespace: namespace,
+			SecretName:      bucket.SecretRef.Name,
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+	return buckets, checkCOSSecretNamespaces(csiDeploy, buckets)
+}
+
+// checkCOSSecretNamespaces checks whether the Secrets of buckets are in the namespace of the CSI object,
+// so that the credentials of other namespaces can't be copied by the users who can write CSI objects.
+func checkCOSSecretNamespaces(csiDeploy *csiv1.CSI, buckets []cosBucket) error {
+	for _, bucket := range buckets {
+		if bucket.SecretNamespace != csiDeploy.Namespace {
+			return fmt.Errorf("the Secret of bucket %s must be in the namespace %s of the CSI object",
+				bucket.Name, csiDeploy.Namespace)
+		}
+	}
+	return nil
+}
+
+// cosSecretNames returns the names of the Secrets referred by the buckets of a CSI object.
+func cosSecretNames(csiDeploy *csiv1.CSI) []string {
+	buckets, err := getCOSBuckets(csiDeploy)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(buckets))
+	for _, bucket := range buckets {
+		names = append(names, bucket.SecretName)
+	}
+	return names
+}
+
+// parseCOSBuckets parses the buckets parameter of COS.
+func parseCOSBuckets(value string) ([]cosBucket, error) {
+	var buckets []cosBucket
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid bucket %s, the format should be <bucket>=<namespace>/<secret>", item)
+		}
+		ref := strings.SplitN(parts[1], "/", 2)
+		if len(parts[0]) == 0 || len(ref) != 2 || len(ref[0]) == 0 || len(ref[1]) == 0 {
+			return nil, fmt.Errorf("invalid bucket %s, the format should be <bucket>=<namespace>/<secret>", item)
+		}
+		buckets = append(buckets, cosBucket{Name: parts[0], SecretNamespace: ref[0], SecretName: ref[1]})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+	return buckets, nil
+}
+
+// getCOSSecretName returns the name of the credential Secret generated for a COS bucket.
+func getCOSSecretName(bucket string) string {
+	return "cos-" + strings.ReplaceAll(strings.ToLower(bucket), ".", "-")
+}
+
+// hostPathType returns a point of HostPathType.
+func hostPathType(typ corev1.HostPathType) *corev1.HostPathType {
+	return &typ
+}
@@ -0,0 +1,82 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCOSBuckets(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  []cosBucket
+		expectErr bool
+	}{
+		{value: "", expected: nil},
+		{
+			value:    "bucket-1250000000=kube-system/cred",
+			expected: []cosBucket{{Name: "bucket-1250000000", SecretNamespace: "kube-system", SecretName: "cred"}},
+		},
+		{
+			value: " b=ns/cred-b , a=ns/cred-a,",
+			expected: []cosBucket{
+				{Name: "a", SecretNamespace: "ns", SecretName: "cred-a"},
+				{Name: "b", SecretNamespace: "ns", SecretName: "cred-b"},
+			},
+		},
+		{value: "bucket", expectErr: true},
+		{value: "bucket=cred", expectErr: true},
+		{value: "=ns/cred", expectErr: true},
+		{value: "bucket=ns/", expectErr: true},
+		{value: "bucket=/cred", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		buckets, err := parseCOSBuckets(tc.value)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("buckets %q: expected an error", tc.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("buckets %q: unexpected error: %v", tc.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(buckets, tc.expected) {
+			t.Errorf("buckets %q: expected %+v, got %+v", tc.value, tc.expected, buckets)
+		}
+	}
+}
+
+func TestGetCOSSecretName(t *testing.T) {
+	testCases := []struct {
+		bucket   string
+		expected string
+	}{
+		{"examplebucket-1250000000", "cos-examplebucket-1250000000"},
+		{"Example.Bucket", "cos-example-bucket"},
+	}
+
+	for _, tc := range testCases {
+		if name := getCOSSecretName(tc.bucket); name != tc.expected {
+			t.Errorf("bucket %s: expected %s, got %s", tc.bucket, tc.expected, name)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package enhancer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// redactedValue replaces the values holding keys in validation errors.
+const redactedValue = "<contents redacted>"
+
+// ValidateParameters checks whether a CSI object of a well known driver is supported by the registered
+// drivers, and whether its typed sections and deprecated parameters are valid. fieldPath is the path of the spec.
+func ValidateParameters(csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	isCeph := csiDeploy.Spec.DriverName == csiv1.CSIDriverCephRBD || csiDeploy.Spec.DriverName == csiv1.CSIDriverCephFS
+	isTencentCloud := csiDeploy.Spec.DriverName == csiv1.CSIDriverTencentCBS ||
+		csiDeploy.Spec.DriverName == csiv1.CSIDriverTencentCFS ||
+		csiDeploy.Spec.DriverName == csiv1.CSIDriverTencentCOS
+	if csiDeploy.Spec.Ceph != nil && !isCeph {
+		errs = append(errs, field.Forbidden(fieldPath.Child("ceph"), "only supported by Ceph drivers"))
+	}
+	if csiDeploy.Spec.TencentCloud != nil && !isTencentCloud {
+		errs = append(errs, field.Forbidden(fieldPath.Child("tencentCloud"), "only supported by Tencent Cloud drivers"))
+	}
+	if csiDeploy.Spec.NFS != nil && csiDeploy.Spec.DriverName != csiv1.CSIDriverNFS {
+		errs = append(errs, field.Forbidden(fieldPath.Child("nfs"), "only supported by the NFS driver"))
+	}
+	if csiDeploy.Spec.LVM != nil && csiDeploy.Spec.DriverName != csiv1.CSIDriverLVM {
+		errs = append(errs, field.Forbidden(fieldPath.Child("lvm"), "only supported by the LVM driver"))
+	}
+	if csiDeploy.Spec.Version == "" || len(errs) > 0 {
+		return errs
+	}
+
+	driver := getDriver(csiDeploy.Spec.DriverName)
+	if driver == nil {
+		return append(errs, field.NotSupported(fieldPath.Child("driverName"),
+			csiDeploy.Spec.DriverName, registeredDrivers()))
+	}
+	if _, exist := driver.Versions[csiDeploy.Spec.Version]; !exist {
+		versions := make([]string, 0, len(driver.Versions))
+		for version := range driver.Versions {
+			versions = append(versions, string(version))
+		}
+		sort.Strings(versions)
+		return append(errs, field.NotSupported(fieldPath.Child("version"), csiDeploy.Spec.Version, versions))
+	}
+	if driver.Validate != nil {
+		errs = append(errs, driver.Validate(csiDeploy, fieldPath)...)
+	}
+
+	return errs
+}
+
+// validateCephParameters checks whether the parameters of a Ceph driver are valid.
+func validateCephParameters(csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList {
+	// The clusters are used by CephFS of version v1.0 and above, and CephRBD of version v1.1.
+	usesClusters := (csiDeploy.Spec.DriverName == csiv1.CSIDriverCephFS && csiDeploy.Spec.Version != csiv1.CSIVersionV0) ||
+		(csiDeploy.Spec.DriverName == csiv1.CSIDriverCephRBD && csiDeploy.Spec.Version == csiv1.CSIVersionV1p1)
+	paramsPath := fieldPath.Child("parameters")
+	errs := validateCephConfRefs(csiDeploy.Namespace, csiDeploy.Spec.Parameters[cephConfSecretKey],
+		csiDeploy.Spec.Parameters[cephConfConfigMapKey],
+		paramsPath.Key(cephConfSecretKey), paramsPath.Key(cephConfConfigMapKey))
+	if !usesClusters {
+		if csiDeploy.Spec.Ceph != nil {
+			errs = append(errs, field.Forbidden(fieldPath.Child("ceph"),
+				fmt.Sprintf("not supported by version %s", csiDeploy.Spec.Version)))
+		}
+		return errs
+	}
+	errs = append(errs, validateCephConfigs(csiDeploy, fieldPath)...)
+	if csiDeploy.Spec.Ceph != nil && csiDeploy.Spec.Ceph.Encryption != nil {
+		errs = append(errs, validateCephEncryption(csiDeploy, fieldPath.Child("ceph", "encryption"))...)
+	}
+	return errs
+}
+
+// validateCephConfRefs checks whether the Secret and the ConfigMap holding ceph.conf are in the namespace
+// of the CSI object.
+func validateCephConfRefs(
+	namespace, secretRef, configMapRef string,
+	secretPath, configMapPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if _, err := objectRefName(namespace, secretRef); err != nil {
+		errs = append(errs, field.Invalid(secretPath, secretRef, err.Error()))
+	}
+	if _, err := objectRefName(namespace, configMapRef); err != nil {
+		errs = append(errs, field.Invalid(configMapPath, configMapRef, err.Error()))
+	}
+
+	return errs
+}
+
+// validateTencentCloudParameters checks whether the parameters of a Tencent Cloud driver are valid.
+func validateTencentCloudParameters(csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if csiDeploy.Spec.TencentCloud != nil {
+		errs = append(errs, validateTencentCloud(csiDeploy.Spec.TencentCloud, csiDeploy.Namespace,
+			fieldPath.Child("tencentCloud"))...)
+	} else if csiDeploy.Spec.DriverName == csiv1.CSIDriverTencentCOS {
+		if _, err := getCOSBuckets(csiDeploy); err != nil {
+			errs = append(errs, field.Invalid(fieldPath.Child("parameters").Key(cosBuckets),
+				csiDeploy.Spec.Parameters[cosBuckets], err.Error()))
+		}
+	}
+
+	return errs
+}
+
+// validateNFSParameters checks whether the shares in the nfs section or the parameters are valid.
+func validateNFSParameters(csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	shares := getNFSShares(csiDeploy)
+	if len(shares) == 0 {
+		if csiDeploy.Spec.NFS != nil {
+			return append(errs, field.Required(fieldPath.Child("nfs", "shares"), ""))
+		}
+		return append(errs, field.Required(fieldPath.Child("parameters").Key(nfsServer), "required unless nfs is set"))
+	}
+
+	names := make(map[string]bool, len(shares))
+	for i, share := range shares {
+		path := fieldPath.Child("nfs", "shares").Index(i)
+		serverPath, sharePath, subDirPath := path.Child("server"), path.Child("share"), path.Child("subDir")
+		if csiDeploy.Spec.NFS == nil {
+			parametersPath := fieldPath.Child("parameters")
+			serverPath, sharePath, subDirPath =
+				parametersPath.Key(nfsServer), parametersPath.Key(nfsShare), parametersPath.Key(nfsSubDir)
+		}
+
+		if len(share.Name) == 0 {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		} else if names[share.Name] {
+			errs = append(errs, field.Duplicate(path.Child("name"), share.Name))
+		}
+		names[share.Name] = true
+		if len(share.Server) == 0 {
+			errs = append(errs, field.Required(serverPath, ""))
+		}
+		if len(share.Share) > 0 && !strings.HasPrefix(share.Share, "/") {
+			errs = append(errs, field.Invalid(sharePath, share.Share, "must be an absolute path"))
+		}
+		if policy := share.ReclaimPolicy; policy != nil &&
+			*policy != corev1.PersistentVolumeReclaimDelete && *policy != corev1.PersistentVolumeReclaimRetain {
+			errs = append(errs, field.NotSupported(path.Child("reclaimPolicy"), *policy, []string{
+				string(corev1.PersistentVolumeReclaimDelete), string(corev1.PersistentVolumeReclaimRetain)}))
+		}
+		if len(share.SubDir) > 0 {
+			errs = append(errs, validateNFSSubDir(share.SubDir, csiDeploy.Spec.Version, subDirPath)...)
+		}
+	}
+
+	return errs
+}
+
+// validateNFSSubDir checks whether the subDir of a share is a relative path with the supported templates.
+func validateNFSSubDir(subDir string, version csiv1.CSIVersion, fieldPath *field.Path) field.ErrorList {
+	if version != csiv1.CSIVersionV1p1 {
+		return field.ErrorList{field.Forbidden(fieldPath, "only supported by version "+csiv1.CSIVersionV1p1)}
+	}
+	if strings.HasPrefix(subDir, "/") {
+		return field.ErrorList{field.Invalid(fieldPath, subDir, "must be a relative path")}
+	}
+	for _, elem := range strings.Split(subDir, "/") {
+		if elem == ".." {
+			return field.ErrorList{field.Invalid(fieldPath, subDir, "must not contain '..'")}
+		}
+	}
+	for _, match := range profileVariable.FindAllStringSubmatch(subDir, -1) {
+		if !nfsSubDirTemplates.Has(match[1]) {
+			return field.ErrorList{field.Invalid(fieldPath, subDir,
+				fmt.Sprintf("unknown template %s, supported templates are %s", match[0],
+					strings.Join(nfsSubDirTemplates.List(), ", ")))}
+		}
+	}
+	return nil
+}
+
+// validateCephConfigs checks whether the clusters in the Ceph section or the configs parameter are valid.
+func validateCephConfigs(csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	clustersPath := fieldPath.Child("ceph", "clusters")
+	if csiDeploy.Spec.Ceph == nil {
+		clustersPath = fieldPath.Child("parameters").Key(configsKey)
+	}
+	cephConfigs, err := parseCephConfigs(csiDeploy)
+	if err != nil {
+		return append(errs, field.Invalid(clustersPath, redactedValue, err.Error()))
+	}
+
+	clusterIDs := make(map[string]bool, len(cephConfigs))
+	classNames := make(map[string]bool)
+	for i := range cephConfigs {
+		conf := &cephConfigs[i]
+		path := clustersPath.Index(i)
+		imported := len(conf.CephConfSecret) > 0 || len(conf.CephConfConfigMap) > 0
+		errs = append(errs, validateCephConfRefs(csiDeploy.Namespace, conf.CephConfSecret, conf.CephConfConfigMap,
+			path.Child("cephConfSecret"), path.Child("cephConfConfigMap"))...)
+
+		// ceph.conf and keyrings fill the cluster ID, the monitors and the keys.
+		if !imported {
+			for _, required := range []struct {
+				value string
+				name  string
+			}{
+				{conf.ClusterID, "clusterID"},
+				{conf.Monitors, "monitors"},
+				{conf.AdminID, "adminID"},
+				{conf.AdminKey, "adminKey"},
+			} {
+				if len(required.value) == 0 {
+					errs = append(errs, field.Required(path.Child(required.name), ""))
+				}
+			}
+		}
+		if len(conf.Pools) == 0 {
+			errs = append(errs, field.Required(path.Child("pools"), ""))
+		}
+		if len(conf.ClusterID) > 0 {
+			if clusterIDs[conf.ClusterID] {
+				errs = append(errs, field.Duplicate(path.Child("clusterID"), conf.ClusterID))
+			}
+			clusterIDs[conf.ClusterID] = true
+		}
+
+		if len(conf.UserID) > 0 && len(conf.UserKey) == 0 && !imported {
+			errs = append(errs, field.Required(path.Child("userKey"), "userKey must be set if userID is set"))
+		}
+		if len(conf.UserKey) > 0 && len(conf.UserID) == 0 {
+			errs = append(errs, field.Required(path.Child("userID"), "userID must be set if userKey is set"))
+		}
+		for pool, user := range conf.PoolUsers {
+			if len(user.UserID) == 0 || len(user.UserKey) == 0 {
+				errs = append(errs, field.Required(path.Child("poolUsers").Key(pool), "userID and userKey must be set"))
+			}
+		}
+
+		if len(conf.Classes) > 0 && csiDeploy.Spec.DriverName != csiv1.CSIDriverCephFS {
+			errs = append(errs, field.Forbidden(path.Child("classes"), "only supported by CephFS"))
+			continue
+		}
+		for j, class := range conf.Classes {
+			classPath := path.Child("classes").Index(j)
+			if len(class.Name) == 0 {
+				errs = append(errs, field.Required(classPath.Child("name"), ""))
+			} else if classNames[class.Name] {
+				errs = append(errs, field.Duplicate(classPath.Child("name"), class.Name))
+			}
+			classNames[class.Name] = true
+			switch class.Mounter {
+			case "", cephFSMounterKernel, cephFSMounterFuse:
+			default:
+				errs = append(errs, field.NotSupported(classPath.Child("mounter"), class.Mounter,
+					[]string{cephFSMounterKernel, cephFSMounterFuse}))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateTencentCloud checks whether the Tencent Cloud section is valid.
+func validateTencentCloud(
+	tencentCloud *csiv1.CSITencentCloudParameters,
+	namespace string,
+	fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if (len(tencentCloud.SecretID) == 0) != (len(tencentCloud.SecretKey) == 0) {
+		errs = append(errs, field.Required(fieldPath, "secretID and secretKey must be set together"))
+	}
+	if cfs := tencentCloud.CFS; cfs != nil {
+		if len(cfs.VPCID) == 0 {
+			errs = append(errs, field.Required(fieldPath.Child("cfs", "vpcID"), ""))
+		}
+		if len(cfs.SubnetID) == 0 {
+			errs = append(errs, field.Required(fieldPath.Child("cfs", "subnetID"), ""))
+		}
+	}
+	if cos := tencentCloud.COS; cos != nil {
+		names := make(map[string]bool, len(cos.Buckets))
+		for i, bucket := range cos.Buckets {
+			path := fieldPath.Child("cos", "buckets").Index(i)
+			if len(bucket.Name) == 0 {
+				errs = append(errs, field.Required(path.Child("name"), ""))
+			} else if names[bucket.Name] {
+				errs = append(errs, field.Duplicate(path.Child("name"), bucket.Name))
+			}
+			names[bucket.Name] = true
+			if len(bucket.SecretRef.Name) == 0 {
+				errs = append(errs, field.Required(path.Child("secretRef", "name"), ""))
+			}
+			if ns := bucket.SecretRef.Namespace; len(ns) != 0 && ns != namespace {
+				errs = append(errs, field.Invalid(path.Child("secretRef", "namespace"), ns,
+					"must be the namespace of the CSI object"))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateLVMParameters checks whether the device classes in the lvm section or the parameters are valid.
+func validateLVMParameters(csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if csiDeploy.Spec.LVM == nil {
+		parametersPath := fieldPath.Child("parameters")
+		if _, err := parseLVMNodeSelector(csiDeploy.Spec.Parameters[lvmNodeSelector]); err != nil {
+			errs = append(errs, field.Invalid(parametersPath.Key(lvmNodeSelector),
+				csiDeploy.Spec.Parameters[lvmNodeSelector], err.Error()))
+		}
+		if len(csiDeploy.Spec.Parameters[lvmVolumeGroup]) == 0 {
+			errs = append(errs, field.Required(parametersPath.Key(lvmVolumeGroup), "required unless lvm is set"))
+		}
+		return errs
+	}
+
+	deviceClassesPath := fieldPath.Child("lvm", "deviceClasses")
+	if len(csiDeploy.Spec.LVM.DeviceClasses) == 0 {
+		return append(errs, field.Required(deviceClassesPath, ""))
+	}
+	names := make(map[string]bool, len(csiDeploy.Spec.LVM.DeviceClasses))
+	hasDefault := false
+	for i, deviceClass := range csiDeploy.Spec.LVM.DeviceClasses {
+		path := deviceClassesPath.Index(i)
+		if len(deviceClass.Name) == 0 {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		} else if names[deviceClass.Name] {
+			errs = append(errs, field.Duplicate(path.Child("name"), deviceClass.Name))
+		}
+		names[deviceClass.Name] = true
+		if len(deviceClass.VolumeGroup) == 0 {
+			errs = append(errs, field.Required(path.Child("volumeGroup"), ""))
+		}
+		if deviceClass.Default {
+			if hasDefault {
+				errs = append(errs, field.Invalid(path.Child("default"), deviceClass.Default,
+					"only one device class can be the default"))
+			}
+			hasDefault = true
+		}
+		if deviceClass.SpareGB != nil && *deviceClass.SpareGB < 0 {
+			errs = append(errs, field.Invalid(path.Child("spareGB"), *deviceClass.SpareGB, "must not be negative"))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,198 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"sync"
+	"time"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsNamespace = "csi_operator"
+
+// Stages of a reconciliation, used as the value of the stage label.
+const (
+	stageAdoption       = "adoption"
+	stageRBAC           = "rbac"
+	stageSecrets        = "secrets"
+	stageStorageClasses = "storage_classes"
+	stageConfigMaps     = "config_maps"
+	stageNode           = "node"
+	stageController     = "controller"
+	stageMetrics        = "metrics"
+)
+
+var (
+	csiPhases          = []csiv1.CSIPhase{csiv1.CSIPending, csiv1.CSIRunning, csiv1.CSIFailed}
+	conditionStatuses  = []corev1.ConditionStatus{corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionUnknown}
+	csiLabels          = []string{"namespace", "name"}
+	workloadPodsLabels = []string{"namespace", "name", "state"}
+
+	csiPhaseGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "csi_phase",
+		Help:      "Phase of CSI objects, 1 for the current phase and 0 for others.",
+	}, append(csiLabels, "phase"))
+	csiConditionGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "csi_condition",
+		Help:      "Conditions of CSI objects, 1 for the current status and 0 for others.",
+	}, append(csiLabels, "type", "status"))
+	nodePodsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "node_driver_pods",
+		Help:      "Number of desired and available node driver pods of CSI objects.",
+	}, workloadPodsLabels)
+	controllerPodsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "controller_driver_pods",
+		Help:      "Number of desired and available controller driver pods of CSI objects.",
+	}, workloadPodsLabels)
+	storageClassesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "managed_storage_classes",
+		Help:      "Number of StorageClasses managed for CSI objects.",
+	}, csiLabels)
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_stage_duration_seconds",
+		Help:      "Duration of each stage of CSI reconciliations.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"stage"})
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_stage_errors_total",
+		Help:      "Number of failed stages of CSI reconciliations.",
+	}, []string{"stage"})
+	enhanceFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "enhance_failures_total",
+		Help:      "Number of failures to enhance well known CSI objects.",
+	}, []string{"driver"})
+
+	// reportedConditions keeps the condition types reported for each CSI object,
+	// so that their series can be removed once the object is deleted.
+	reportedConditions     = make(map[k8stypes.NamespacedName]sets.String)
+	reportedConditionsLock sync.Mutex
+)
+
+// init func.
+func init() {
+	metrics.Registry.MustRegister(
+		csiPhaseGauge,
+		csiConditionGauge,
+		nodePodsGauge,
+		controllerPodsGauge,
+		storageClassesGauge,
+		reconcileDuration,
+		reconcileErrors,
+		enhanceFailures,
+	)
+}
+
+// observeStage records the duration and the result of a reconciliation stage started at start.
+func observeStage(stage string, start time.Time, err error) {
+	reconcileDuration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+	if err != nil {
+		reconcileErrors.WithLabelValues(stage).Inc()
+	}
+}
+
+// recordStatusMetrics records the phase and conditions of a CSI object.
+func recordStatusMetrics(csiDeploy *csiv1.CSI) {
+	for _, phase := range csiPhases {
+		value := 0.0
+		if csiDeploy.Status.Phase == phase {
+			value = 1
+		}
+		csiPhaseGauge.WithLabelValues(csiDeploy.Namespace, csiDeploy.Name, string(phase)).Set(value)
+	}
+
+	key := k8stypes.NamespacedName{Namespace: csiDeploy.Namespace, Name: csiDeploy.Name}
+	reportedConditionsLock.Lock()
+	defer reportedConditionsLock.Unlock()
+	if reportedConditions[key] == nil {
+		reportedConditions[key] = sets.NewString()
+	}
+	for _, condition := range csiDeploy.Status.Conditions {
+		reportedConditions[key].Insert(condition.Type)
+		for _, status := range conditionStatuses {
+			value := 0.0
+			if condition.Status == status {
+				value = 1
+			}
+			csiConditionGauge.WithLabelValues(csiDeploy.Namespace, csiDeploy.Name,
+				condition.Type, string(status)).Set(value)
+		}
+	}
+}
+
+// recordWorkloadMetrics records the desired and available pods of the node driver and controller driver.
+func recordWorkloadMetrics(csiDeploy *csiv1.CSI, nodeDriver *appsv1.DaemonSet, controller *appsv1.Deployment) {
+	if nodeDriver != nil {
+		nodePodsGauge.WithLabelValues(csiDeploy.Namespace, csiDeploy.Name, "desired").
+			Set(float64(nodeDriver.Status.DesiredNumberScheduled))
+		nodePodsGauge.WithLabelValues(csiDeploy.Namespace, csiDeploy.Name, "available").
+			Set(float64(nodeDriver.Status.NumberAvailable))
+	}
+	if controller != nil {
+		desired := int32(0)
+		if controller.Spec.Replicas != nil {
+			desired = *controller.Spec.Replicas
+		}
+		controllerPodsGauge.WithLabelValues(csiDeploy.Namespace, csiDeploy.Name, "desired").
+			Set(float64(desired))
+		controllerPodsGauge.WithLabelValues(csiDeploy.Namespace, csiDeploy.Name, "available").
+			Set(float64(controller.Status.AvailableReplicas))
+	}
+}
+
+// recordStorageClassesMetrics records the number of StorageClasses managed for a CSI object.
+func recordStorageClassesMetrics(csiDeploy *csiv1.CSI, count int) {
+	storageClassesGauge.WithLabelValues(csiDeploy.Namespace, csiDeploy.Name).Set(float64(count))
+}
+
+// forgetCSIMetrics removes all series of a deleted CSI object.
+func forgetCSIMetrics(key k8stypes.NamespacedName) {
+	for _, phase := range csiPhases {
+		csiPhaseGauge.DeleteLabelValues(key.Namespace, key.Name, string(phase))
+	}
+
+	reportedConditionsLock.Lock()
+	for _, typ := range reportedConditions[key].List() {
+		for _, status := range conditionStatuses {
+			csiConditionGauge.DeleteLabelValues(key.Namespace, key.Name, typ, string(status))
+		}
+	}
+	delete(reportedConditions, key)
+	reportedConditionsLock.Unlock()
+
+	for _, state := range []string{"desired", "available"} {
+		nodePodsGauge.DeleteLabelValues(key.Namespace, key.Name, state)
+		controllerPodsGauge.DeleteLabelValues(key.Namespace, key.Name, state)
+	}
+	storageClassesGauge.DeleteLabelValues(key.Namespace, key.Name)
+}
@@ -0,0 +1,208 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	nodeComponent       = "node"
+	controllerComponent = "controller"
+
+	// maxMessageLength limits the length of termination messages recorded in status.
+	maxMessageLength = 256
+)
+
+// benignWaitingReasons are reasons of waiting containers which are not considered failures.
+var benignWaitingReasons = sets.NewString("ContainerCreating", "PodInitializing")
+
+// newDriverPodHandler enqueues Requests for the CSI object owning a node driver or controller driver pod.
+// Pods are owned by DaemonSets or ReplicaSets, so the owner is determined by the selector labels.
+func newDriverPodHandler() handler.EventHandler {
+	mapper := func(object handler.MapObject) []reconcile.Request {
+		labelSet := object.Meta.GetLabels()
+		name := ""
+		if value, found := labelSet[nodeDriverLabel]; found {
+			name = strings.TrimSuffix(value, "-"+nodeComponent)
+		} else if value, found := labelSet[controllerDriverLabel]; found {
+			name = strings.TrimSuffix(value, "-"+controllerComponent)
+		}
+		if name == "" {
+			return nil
+		}
+		return []reconcile.Request{{
+			NamespacedName: k8stypes.NamespacedName{
+				Namespace: object.Meta.GetNamespace(),
+				Name:      name,
+			}}}
+	}
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(mapper),
+	}
+}
+
+// syncContainerFailures aggregates the failing containers of the node driver and controller driver
+// pods into the status of CSI, and records an event for each newly failing container.
+func (r *ReconcileCSI) syncContainerFailures(csiDeploy *csiv1.CSI) error {
+	var failures []csiv1.CSIContainerFailure
+
+	workloads := []struct {
+		component string
+		label     string
+	}{
+		{nodeComponent, nodeDriverLabel},
+		{controllerComponent, controllerDriverLabel},
+	}
+	for _, workload := range workloads {
+		pods := &corev1.PodList{}
+		err := r.listObjects(pods, &client.ListOptions{
+			Namespace: csiDeploy.Namespace,
+			LabelSelector: labels.SelectorFromSet(labels.Set{
+				workload.label: csiDeploy.Name + "-" + workload.component,
+			}),
+		})
+		if err != nil {
+			return fmt.Errorf("list %s driver pods failed: %s", workload.component, err.Error())
+		}
+		failures = append(failures, aggregateContainerFailures(workload.component, pods.Items)...)
+	}
+
+	existed := sets.NewString()
+	for _, failure := range csiDeploy.Status.ContainerFailures {
+		existed.Insert(containerFailureKey(&failure))
+	}
+	for i := range failures {
+		failure := &failures[i]
+		if existed.Has(containerFailureKey(failure)) {
+			continue
+		}
+		message := fmt.Sprintf("Container %s of %s driver is in %s on %d pod(s), restarts: %d, node: %s",
+			failure.Container, failure.Component, failure.Reason, failure.Pods, failure.RestartCount, failure.Node)
+		if len(failure.Message) > 0 {
+			message += ": " + failure.Message
+		}
+		r.recorder.Event(csiDeploy, corev1.EventTypeWarning, types.ContainerFailed, message)
+	}
+
+	csiDeploy.Status.ContainerFailures = failures
+	return nil
+}
+
+// aggregateContainerFailures groups the failing containers of pods by container name and reason.
+func aggregateContainerFailures(component string, pods []corev1.Pod) []csiv1.CSIContainerFailure {
+	failureMap := make(map[string]*csiv1.CSIContainerFailure)
+	for i := range pods {
+		pod := &pods[i]
+		statuses := append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+		statuses = append(statuses, pod.Status.ContainerStatuses...)
+		for j := range statuses {
+			status := &statuses[j]
+			reason := containerFailureReason(status)
+			if reason == "" {
+				continue
+			}
+
+			failure := &csiv1.CSIContainerFailure{
+				Component: component,
+				Container: status.Name,
+				Reason:    reason,
+			}
+			key := containerFailureKey(failure)
+			if exist, found := failureMap[key]; found {
+				failure = exist
+			} else {
+				failureMap[key] = failure
+			}
+
+			failure.Pods++
+			if failure.Node == "" || status.RestartCount > failure.RestartCount {
+				failure.RestartCount = status.RestartCount
+				failure.Node = pod.Spec.NodeName
+				failure.Message = terminationMessage(status)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(failureMap))
+	for key := range failureMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	failures := make([]csiv1.CSIContainerFailure, 0, len(keys))
+	for _, key := range keys {
+		failures = append(failures, *failureMap[key])
+	}
+	return failures
+}
+
+// containerFailureReason returns the reason why a container is failing, or an empty
+// string if the container is healthy or still starting.
+func containerFailureReason(status *corev1.ContainerStatus) string {
+	if waiting := status.State.Waiting; waiting != nil {
+		if waiting.Reason != "" && !benignWaitingReasons.Has(waiting.Reason) {
+			return waiting.Reason
+		}
+		return ""
+	}
+	if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+		if terminated.Reason != "" {
+			return terminated.Reason
+		}
+		return "Error"
+	}
+	return ""
+}
+
+// terminationMessage returns the message of the last termination of a container.
+func terminationMessage(status *corev1.ContainerStatus) string {
+	terminated := status.LastTerminationState.Terminated
+	if terminated == nil {
+		terminated = status.State.Terminated
+	}
+	if terminated == nil {
+		return ""
+	}
+	message := strings.TrimSpace(terminated.Message)
+	if message == "" {
+		message = fmt.Sprintf("exit code %d", terminated.ExitCode)
+	}
+	if len(message) > maxMessageLength {
+		message = message[:maxMessageLength] + "..."
+	}
+	return message
+}
+
+// containerFailureKey returns the key used to aggregate container failures.
+func containerFailureKey(failure *csiv1.CSIContainerFailure) string {
+	return failure.Component + "/" + failure.Container + "/" + failure.Reason
+}
@@ -0,0 +1,121 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"reflect"
+	"testing"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func waitingStatus(name, reason string, restarts int32) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:         name,
+		RestartCount: restarts,
+		State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}},
+	}
+}
+
+func podWithStatuses(node string, statuses ...corev1.ContainerStatus) corev1.Pod {
+	return corev1.Pod{
+		Spec:   corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{ContainerStatuses: statuses},
+	}
+}
+
+func TestAggregateContainerFailures(t *testing.T) {
+	crashed := waitingStatus("driver", "CrashLoopBackOff", 3)
+	crashed.LastTerminationState.Terminated = &corev1.ContainerStateTerminated{ExitCode: 1, Message: " no monitors \n"}
+
+	testCases := []struct {
+		name     string
+		pods     []corev1.Pod
+		expected []csiv1.CSIContainerFailure
+	}{
+		{
+			name:     "no pods",
+			expected: []csiv1.CSIContainerFailure{},
+		},
+		{
+			name: "healthy and starting containers",
+			pods: []corev1.Pod{
+				podWithStatuses("node1", corev1.ContainerStatus{Name: "driver"},
+					waitingStatus("registrar", "ContainerCreating", 0)),
+			},
+			expected: []csiv1.CSIContainerFailure{},
+		},
+		{
+			name: "same failure on several pods",
+			pods: []corev1.Pod{
+				podWithStatuses("node1", waitingStatus("driver", "CrashLoopBackOff", 1)),
+				podWithStatuses("node2", crashed),
+			},
+			expected: []csiv1.CSIContainerFailure{{
+				Component:    "node",
+				Container:    "driver",
+				Reason:       "CrashLoopBackOff",
+				Pods:         2,
+				RestartCount: 3,
+				Node:         "node2",
+				Message:      "no monitors",
+			}},
+		},
+		{
+			name: "failures of init containers and terminated containers",
+			pods: []corev1.Pod{{
+				Spec: corev1.PodSpec{NodeName: "node1"},
+				Status: corev1.PodStatus{
+					InitContainerStatuses: []corev1.ContainerStatus{waitingStatus("init", "ImagePullBackOff", 0)},
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name:  "driver",
+						State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 2}},
+					}},
+				},
+			}},
+			expected: []csiv1.CSIContainerFailure{
+				{
+					Component: "node",
+					Container: "driver",
+					Reason:    "Error",
+					Pods:      1,
+					Node:      "node1",
+					Message:   "exit code 2",
+				},
+				{
+					Component: "node",
+					Container: "init",
+					Reason:    "ImagePullBackOff",
+					Pods:      1,
+					Node:      "node1",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			failures := aggregateContainerFailures("node", tc.pods)
+			if !reflect.DeepEqual(failures, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, failures)
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/util"
 	"tkestack.io/csi-operator/pkg/types"
 
 	corev1 "k8s.io/api/core/v1"
@@ -187,6 +188,11 @@ func generateControllerRole(csiDeploy *csiv1.CSI) *rbacv1.ClusterRole {
 		rules = append(rules, provisionerPolicyRules()...)
 	}
 
+	// Add PolicyRules needed by Provisioner to publish the storage capacity.
+	if storageCapacityEnabled(csiDeploy) {
+		rules = append(rules, storageCapacityPolicyRules()...)
+	}
+
 	// Add PolicyRules needed by Attacher.
 	if csiDeploy.Spec.Controller.Attacher != nil {
 		rules = append(rules, attacherPolicyRules()...)
@@ -215,6 +221,11 @@ func generateControllerRole(csiDeploy *csiv1.CSI) *rbacv1.ClusterRole {
 			})
 	}
 
+	// The controller driver running another command needs the rules of the driver too.
+	if csiDeploy.Spec.DriverTemplate.ControllerTemplate != nil {
+		rules = append(rules, csiDeploy.Spec.DriverTemplate.Rules...)
+	}
+
 	return &rbacv1.ClusterRole{
 		ObjectMeta: metav1.ObjectMeta{Name: clusterRoleName(csiDeploy, true)},
 		Rules:      rules,
@@ -247,6 +258,28 @@ func provisionerPolicyRules() []rbacv1.PolicyRule {
 	}
 }
 
+// storageCapacityPolicyRules returns PolicyRules needed by provisioner to publish the storage capacity.
+func storageCapacityPolicyRules() []rbacv1.PolicyRule {
+	return []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{"storage.k8s.io"},
+			Resources: []string{"csistoragecapacities"},
+			Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete"},
+		},
+		// Used to find the owner of the CSIStorageCapacity objects.
+		{
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+			Verbs:     []string{"get"},
+		},
+		{
+			APIGroups: []string{"apps"},
+			Resources: []string{"replicasets", "deployments"},
+			Verbs:     []string{"get"},
+		},
+	}
+}
+
 // attacherPolicyRules returns PolicyRules needed by attacher.
 func attacherPolicyRules() []rbacv1.PolicyRule {
 	return []rbacv1.PolicyRule{
@@ -278,7 +311,13 @@ func snapshotterPolicyRules() []rbacv1.PolicyRule {
 		{
 			APIGroups: []string{"snapshot.storage.k8s.io"},
 			Resources: []string{"volumesnapshotcontents"},
-			Verbs:     []string{"create", "get", "list", "watch", "update", "delete"},
+			Verbs:     []string{"create", "get", "list", "watch", "update", "delete", "patch"},
+		},
+		{
+			// Updated by the snapshotter since v2.0, which leaves the snapshots to the snapshot-controller.
+			APIGroups: []string{"snapshot.storage.k8s.io"},
+			Resources: []string{"volumesnapshotcontents/status"},
+			Verbs:     []string{"update", "patch"},
 		},
 		{
 			APIGroups: []string{"snapshot.storage.k8s.io"},
@@ -335,7 +374,7 @@ func (r *ReconcileCSI) syncServiceAccounts(csiDeploy *csiv1.CSI) (bool, error) {
 
 		// ServiceAccount already exists, update it if has wrong OwnerReferences.
 		updatedObj := exist.DeepCopy()
-		if mergeObjectMeta(&sc.ObjectMeta, &updatedObj.ObjectMeta) {
+		if util.MergeObjectMeta(&sc.ObjectMeta, &updatedObj.ObjectMeta) {
 			return true, r.updateObject(updatedObj)
 		}
 
@@ -393,7 +432,7 @@ func (r *ReconcileCSI) syncClusterRoleBinding(csiDeploy *csiv1.CSI) (bool, error
 
 		// ServiceAccount already exists, update it if has wrong Subjects or RoleRef.
 		updateObj := exist.DeepCopy()
-		updated := mergeObjectMeta(&crb.ObjectMeta, &updateObj.ObjectMeta)
+		updated := util.MergeObjectMeta(&crb.ObjectMeta, &updateObj.ObjectMeta)
 		if !equality.Semantic.DeepEqual(updateObj.Subjects, crb.Subjects) {
 			updated = true
 			updateObj.Subjects = crb.Subjects
@@ -0,0 +1,103 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/csi/enhancer"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// operatorRoleFile is the manifest holding the ClusterRole of the operator.
+const operatorRoleFile = "../../../deploy/kubernetes/rbac.yaml"
+
+// operatorRole reads the ClusterRole of the operator from its manifest.
+func operatorRole(t *testing.T) *rbacv1.ClusterRole {
+	data, err := ioutil.ReadFile(operatorRoleFile)
+	if err != nil {
+		t.Fatalf("read %s failed: %v", operatorRoleFile, err)
+	}
+	for _, doc := range strings.Split(string(data), "\n---") {
+		role := &rbacv1.ClusterRole{}
+		if err := yaml.Unmarshal([]byte(doc), role); err != nil {
+			t.Fatalf("parse %s failed: %v", operatorRoleFile, err)
+		}
+		if role.Kind == "ClusterRole" && role.Name == "csi-operator-role" {
+			return role
+		}
+	}
+	t.Fatalf("csi-operator-role not found in %s", operatorRoleFile)
+	return nil
+}
+
+// ruleAllows returns true if one of the rules allows the verb on the resource of the API group.
+func ruleAllows(rules []rbacv1.PolicyRule, apiGroup, resource, verb string) bool {
+	contains := func(values []string, value string) bool {
+		for _, v := range values {
+			if v == value || v == rbacv1.ResourceAll {
+				return true
+			}
+		}
+		return false
+	}
+	for _, rule := range rules {
+		if contains(rule.APIGroups, apiGroup) && contains(rule.Resources, resource) && contains(rule.Verbs, verb) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestOperatorRoleCoversRules checks whether the operator holds the permissions it grants to the drivers,
+// as the apiserver rejects ClusterRoles escalating the permissions of their creator.
+func TestOperatorRoleCoversRules(t *testing.T) {
+	role := operatorRole(t)
+
+	testCases := []struct {
+		name  string
+		rules []rbacv1.PolicyRule
+	}{
+		{name: "LVM driver", rules: enhancer.DriverRules(csiv1.CSIDriverLVM)},
+		{name: "storage capacity", rules: storageCapacityPolicyRules()},
+		{name: "snapshotter", rules: snapshotterPolicyRules()},
+	}
+
+	for _, tc := range testCases {
+		if len(tc.rules) == 0 {
+			t.Errorf("%s: expected rules", tc.name)
+		}
+		for _, rule := range tc.rules {
+			for _, apiGroup := range rule.APIGroups {
+				for _, resource := range rule.Resources {
+					for _, verb := range rule.Verbs {
+						if !ruleAllows(role.Rules, apiGroup, resource, verb) {
+							t.Errorf("%s: csi-operator-role does not allow %s on %s of group %q",
+								tc.name, verb, resource, apiGroup)
+						}
+					}
+				}
+			}
+		}
+	}
+}
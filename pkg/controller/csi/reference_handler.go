@@ -0,0 +1,65 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/util"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// referredNamesFunc returns the names of the objects of a kind a CSI object refers to.
+type referredNamesFunc func(csiDeploy *csiv1.CSI) []string
+
+// newReferenceHandler enqueues Requests for the CSI objects in the namespace of the object which refer to it.
+// It is used for the objects copied into the CSI object by the enhancer, such as the credentials of buckets.
+func newReferenceHandler(c client.Client, referredNames referredNamesFunc) handler.EventHandler {
+	mapper := func(object handler.MapObject) []reconcile.Request {
+		ctx, cancel := util.GetContext()
+		defer cancel()
+		csiList := &csiv1.CSIList{}
+		if err := c.List(ctx, csiList, client.InNamespace(object.Meta.GetNamespace())); err != nil {
+			klog.Errorf("List CSI objects failed: %v", err)
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for i := range csiList.Items {
+			csiDeploy := &csiList.Items[i]
+			for _, name := range referredNames(csiDeploy) {
+				if name == object.Meta.GetName() {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{
+							Namespace: csiDeploy.Namespace,
+							Name:      csiDeploy.Name,
+						}})
+					break
+				}
+			}
+		}
+		return requests
+	}
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(mapper),
+	}
+}
@@ -27,6 +27,7 @@ import (
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"tkestack.io/csi-operator/pkg/controller/util"
 	"tkestack.io/csi-operator/pkg/types"
 )
 
@@ -48,6 +49,25 @@ func (r *ReconcileCSI) syncSecrets(csiDeploy *csiv1.CSI) (bool, error) {
 		errs    types.ErrorList
 	)
 
+	// Adopt the Secrets retained by a deleted CSI with the same namespace and name.
+	retained := &corev1.SecretList{}
+	err = r.listObjects(retained, &client.ListOptions{LabelSelector: retainedLabelSelector(csiDeploy)})
+	if err != nil {
+		return false, fmt.Errorf("list retained Secrets failed: %s", err.Error())
+	}
+	for i := range retained.Items {
+		secret := &retained.Items[i]
+		key := k8stypes.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}.String()
+		if _, exist := secretSets[key]; exist || !hasSecret(csiDeploy, key) {
+			continue
+		}
+		if err := r.adoptObject(csiDeploy, secret, true); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		secretSets[key] = secret
+	}
+
 	for _, secret := range csiDeploy.Spec.Secrets {
 		key := k8stypes.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}.String()
 		exist := secretSets[key]
@@ -59,9 +79,9 @@ func (r *ReconcileCSI) syncSecrets(csiDeploy *csiv1.CSI) (bool, error) {
 		}
 	}
 
-	// Remaining secrets are no longer needed by this CSI, delete them.
+	// Remaining secrets are no longer needed by this CSI, delete or release them.
 	for _, secret := range secretSets {
-		if err := r.deleteObject(secret); err != nil {
+		if err := r.releaseOrDelete(csiDeploy, secret); err != nil {
 			errs = append(errs, err)
 		} else {
 			updated = true
@@ -94,7 +114,7 @@ func (r *ReconcileCSI) syncSecret(
 	updateObj.ObjectMeta = exist.ObjectMeta
 	filterSecretDefaultFields(updateObj, exist)
 	updated := !equality.Semantic.DeepEqual(updateObj, exist)
-	if mergeObjectMeta(&secret.ObjectMeta, &updateObj.ObjectMeta) {
+	if util.MergeObjectMeta(&secret.ObjectMeta, &updateObj.ObjectMeta) {
 		updated = true
 	}
 	if updated {
@@ -106,6 +126,44 @@ func (r *ReconcileCSI) syncSecret(
 	return false, nil
 }
 
+// clearSecrets releases the Secrets of a deleting CSI if their deletion policy is Orphan or Retain,
+// others will be deleted by the garbage collector.
+func (r *ReconcileCSI) clearSecrets(csiDeploy *csiv1.CSI) error {
+	list := &corev1.SecretList{}
+	err := r.listObjects(list, &client.ListOptions{LabelSelector: ownerLabelSelector(csiDeploy)})
+	if err != nil {
+		return fmt.Errorf("list Secrets for %s/%s failed: %s",
+			csiDeploy.Namespace, csiDeploy.Name, err.Error())
+	}
+
+	var errs types.ErrorList
+	for i := range list.Items {
+		secret := &list.Items[i]
+		if getDeletionPolicy(csiDeploy, secret) == csiv1.CSIDeletionDelete {
+			continue
+		}
+		if err := r.releaseOrDelete(csiDeploy, secret); err != nil {
+			errs = append(errs, fmt.Errorf("clear Secret %s/%s failed: %s",
+				secret.Namespace, secret.Name, err.Error()))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// hasSecret returns true if a Secret is required by the CSI.
+func hasSecret(csiDeploy *csiv1.CSI, key string) bool {
+	for _, secret := range csiDeploy.Spec.Secrets {
+		if (k8stypes.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}).String() == key {
+			return true
+		}
+	}
+	return false
+}
+
 // filterSecretDefaultFields filters fields filled by k8s.
 func filterSecretDefaultFields(secret, ref *corev1.Secret) {
 	if secret.Type == "" && ref.Type == corev1.SecretTypeOpaque {
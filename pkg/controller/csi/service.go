@@ -0,0 +1,500 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"fmt"
+	"strings"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/csi/enhancer"
+	"tkestack.io/csi-operator/pkg/controller/util"
+	"tkestack.io/csi-operator/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/klog"
+)
+
+const (
+	// metricsServiceLabel is the label used by ServiceMonitors to select the metrics Services of a CSI.
+	metricsServiceLabel = "storage.tkestack.io/metrics"
+
+	driverMetricsPortName = "metrics"
+
+	// Use different ports for node driver and controller driver as some CSI driver
+	// will set hostNetwork to true. They are below the ports of the well known drivers.
+	nodeMetricsPort       = 9740
+	controllerMetricsPort = 9750
+	// maxMetricsPorts is the number of ports reserved for the metrics endpoints of a pod.
+	maxMetricsPorts = 5
+
+	monitoringGroup   = "monitoring.coreos.com"
+	monitoringVersion = "v1"
+)
+
+// sidecarMetrics describes the metrics endpoint of a sidecar.
+type sidecarMetrics struct {
+	// PortName is the name of the container port.
+	PortName string
+	// Offset of the port from the first metrics port of the pod.
+	Offset int32
+	// MetricsAddressSince is the first version supporting the --metrics-address flag.
+	MetricsAddressSince *version.Version
+	// HTTPEndpointSince is the first version supporting the --http-endpoint flag.
+	HTTPEndpointSince *version.Version
+}
+
+// sidecarMetricsMap is the set of controller sidecars exposing metrics, keyed by container name.
+var sidecarMetricsMap = map[string]sidecarMetrics{
+	"csi-provisioner": {
+		PortName:            "prov-metrics",
+		Offset:              1,
+		MetricsAddressSince: version.MustParseGeneric("v1.3.0"),
+		HTTPEndpointSince:   version.MustParseGeneric("v2.1.0"),
+	},
+	"csi-attacher": {
+		PortName:            "attach-metrics",
+		Offset:              2,
+		MetricsAddressSince: version.MustParseGeneric("v2.0.0"),
+		HTTPEndpointSince:   version.MustParseGeneric("v3.1.0"),
+	},
+	"csi-resizer": {
+		PortName:            "resize-metrics",
+		Offset:              3,
+		MetricsAddressSince: version.MustParseGeneric("v0.3.0"),
+		HTTPEndpointSince:   version.MustParseGeneric("v1.1.0"),
+	},
+	"csi-snapshotter": {
+		PortName:            "snap-metrics",
+		Offset:              4,
+		MetricsAddressSince: version.MustParseGeneric("v2.0.0"),
+		HTTPEndpointSince:   version.MustParseGeneric("v3.0.0"),
+	},
+}
+
+// getMetricsPort returns the first metrics port of the node driver or controller driver pods.
+func getMetricsPort(csiDeploy *csiv1.CSI, controller bool) int32 {
+	if controller {
+		if csiDeploy.Spec.Metrics.ControllerPort > 0 {
+			return csiDeploy.Spec.Metrics.ControllerPort
+		}
+		return controllerMetricsPort
+	}
+	if csiDeploy.Spec.Metrics.NodePort > 0 {
+		return csiDeploy.Spec.Metrics.NodePort
+	}
+	return nodeMetricsPort
+}
+
+// knownMetricsPorts returns the first metrics ports of the node driver and controller driver pods of a CSI.
+// The unset ports of well known drivers are skipped, as they are filled by the enhancer.
+func knownMetricsPorts(csiDeploy *csiv1.CSI) []int32 {
+	if !enhancer.MetricsEnabled(csiDeploy) || isTerminating(csiDeploy) {
+		return nil
+	}
+	var ports []int32
+	for _, controller := range []bool{false, true} {
+		set := csiDeploy.Spec.Metrics.NodePort
+		if controller {
+			if !hasController(csiDeploy) {
+				continue
+			}
+			set = csiDeploy.Spec.Metrics.ControllerPort
+		}
+		if set == 0 && csiDeploy.Spec.Version != "" {
+			continue
+		}
+		ports = append(ports, getMetricsPort(csiDeploy, controller))
+	}
+	return ports
+}
+
+// injectMetrics enables the metrics endpoints of the driver and sidecars of a pod.
+func injectMetrics(csiDeploy *csiv1.CSI, spec *corev1.PodSpec, controller bool) {
+	port := getMetricsPort(csiDeploy, controller)
+
+	// The driver container gets the address by the METRICS_ADDRESS or METRICS_PORT env, as the flag
+	// is driver specific. Its port is only exposed if the driver is started with the flag.
+	driverContainer := &spec.Containers[0]
+	driverContainer.Env = append(driverContainer.Env,
+		corev1.EnvVar{Name: types.MetricsAddressEnv, Value: fmt.Sprintf(":%d", port)},
+		corev1.EnvVar{Name: types.MetricsPortEnv, Value: fmt.Sprintf("%d", port)})
+	if servesMetrics(driverContainer) {
+		driverContainer.Ports = append(driverContainer.Ports, corev1.ContainerPort{
+			Name:          driverMetricsPortName,
+			ContainerPort: port,
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+
+	for i := 1; i < len(spec.Containers); i++ {
+		container := &spec.Containers[i]
+		sidecar, exist := sidecarMetricsMap[container.Name]
+		if !exist {
+			continue
+		}
+		flag := sidecarMetricsFlag(container.Image, &sidecar)
+		if flag == "" {
+			klog.V(3).Infof("%s of %s/%s does not support metrics", container.Image,
+				csiDeploy.Namespace, csiDeploy.Name)
+			continue
+		}
+		container.Args = append(container.Args, fmt.Sprintf("%s=:%d", flag, port+sidecar.Offset))
+		container.Ports = append(container.Ports, corev1.ContainerPort{
+			Name:          sidecar.PortName,
+			ContainerPort: port + sidecar.Offset,
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+}
+
+// servesMetrics returns true if the command or args of the driver container refer the metrics envs.
+func servesMetrics(container *corev1.Container) bool {
+	for _, arg := range append(append([]string(nil), container.Command...), container.Args...) {
+		if strings.Contains(arg, "$("+types.MetricsAddressEnv+")") || strings.Contains(arg, "$("+types.MetricsPortEnv+")") {
+			return true
+		}
+	}
+	return false
+}
+
+// sidecarMetricsFlag returns the flag used to enable the metrics endpoint of a sidecar,
+// or an empty string if the sidecar does not support metrics.
+func sidecarMetricsFlag(image string, sidecar *sidecarMetrics) string {
+	v, err := version.ParseGeneric(image[strings.LastIndex(image, ":")+1:])
+	if err != nil {
+		// Assume tags not following semver, such as canary, are recent versions.
+		return "--http-endpoint"
+	}
+	if v.AtLeast(sidecar.HTTPEndpointSince) {
+		return "--http-endpoint"
+	}
+	if v.AtLeast(sidecar.MetricsAddressSince) {
+		return "--metrics-address"
+	}
+	return ""
+}
+
+// metricsServicePorts returns the ports of the metrics endpoints of a pod template.
+func metricsServicePorts(spec *corev1.PodSpec) []corev1.ServicePort {
+	names := map[string]bool{driverMetricsPortName: true}
+	for _, sidecar := range sidecarMetricsMap {
+		names[sidecar.PortName] = true
+	}
+
+	var ports []corev1.ServicePort
+	for _, container := range spec.Containers {
+		for _, port := range container.Ports {
+			if !names[port.Name] {
+				continue
+			}
+			ports = append(ports, corev1.ServicePort{
+				Name:       port.Name,
+				Port:       port.ContainerPort,
+				TargetPort: intstr.FromString(port.Name),
+				Protocol:   corev1.ProtocolTCP,
+			})
+		}
+	}
+	return ports
+}
+
+// syncMetricsServices creates or updates the metrics Services of the node driver and controller driver,
+// and the prometheus-operator monitors if requested.
+func (r *ReconcileCSI) syncMetricsServices(csiDeploy *csiv1.CSI) (bool, error) {
+	var (
+		updated bool
+		errs    types.ErrorList
+	)
+
+	desired := map[bool]bool{false: enhancer.MetricsEnabled(csiDeploy), true: enhancer.MetricsEnabled(csiDeploy) && hasController(csiDeploy)}
+	for _, controller := range []bool{false, true} {
+		name := metricsServiceName(csiDeploy, controller)
+		var (
+			svcUpdated bool
+			err        error
+		)
+		if desired[controller] {
+			svcUpdated, err = r.syncMetricsService(csiDeploy, controller)
+		} else {
+			svcUpdated, err = r.deleteIfExist(k8stypes.NamespacedName{Namespace: csiDeploy.Namespace, Name: name},
+				&corev1.Service{})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sync metrics Service %s failed: %v", name, err))
+		} else if svcUpdated {
+			updated = true
+		}
+	}
+
+	if monitorUpdated, err := r.syncMonitors(csiDeploy); err != nil {
+		errs = append(errs, err)
+	} else if monitorUpdated {
+		updated = true
+	}
+
+	if len(errs) > 0 {
+		return updated, errs
+	}
+	return updated, nil
+}
+
+// syncMetricsService creates or updates the metrics Service of the node driver or controller driver.
+func (r *ReconcileCSI) syncMetricsService(csiDeploy *csiv1.CSI, controller bool) (bool, error) {
+	var (
+		selector map[string]string
+		ports    []corev1.ServicePort
+	)
+	if controller {
+		deploy := r.generateControllerDriver(csiDeploy)
+		selector, ports = deploy.Spec.Selector.MatchLabels, metricsServicePorts(&deploy.Spec.Template.Spec)
+	} else {
+		ds := r.generateNodeDriver(csiDeploy)
+		selector, ports = ds.Spec.Selector.MatchLabels, metricsServicePorts(&ds.Spec.Template.Spec)
+	}
+
+	desired := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       csiDeploy.Namespace,
+			Name:            metricsServiceName(csiDeploy, controller),
+			Labels:          map[string]string{metricsServiceLabel: csiDeploy.Name},
+			OwnerReferences: ownerReference(csiDeploy),
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  selector,
+			Ports:     ports,
+		},
+	}
+
+	exist := &corev1.Service{}
+	err := r.getObject(k8stypes.NamespacedName{Namespace: desired.Namespace, Name: desired.Name}, exist)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			klog.Infof("Create metrics Service %s for %s/%s", desired.Name, csiDeploy.Namespace, csiDeploy.Name)
+			return true, r.createObject(desired)
+		}
+		return false, err
+	}
+
+	updateObj := exist.DeepCopy()
+	updated := util.MergeObjectMeta(&desired.ObjectMeta, &updateObj.ObjectMeta)
+	if !equality.Semantic.DeepEqual(updateObj.Spec.Selector, desired.Spec.Selector) {
+		updated = true
+		updateObj.Spec.Selector = desired.Spec.Selector
+	}
+	if !equality.Semantic.DeepEqual(updateObj.Spec.Ports, desired.Spec.Ports) {
+		updated = true
+		updateObj.Spec.Ports = desired.Spec.Ports
+	}
+	if !updated {
+		return false, nil
+	}
+	klog.Infof("Update metrics Service %s for %s/%s", desired.Name, csiDeploy.Namespace, csiDeploy.Name)
+	return true, r.updateObject(updateObj)
+}
+
+// syncMonitors creates, updates or deletes the prometheus-operator monitors of a CSI.
+func (r *ReconcileCSI) syncMonitors(csiDeploy *csiv1.CSI) (bool, error) {
+	monitorType := csiv1.CSIMonitorType("")
+	if enhancer.MetricsEnabled(csiDeploy) {
+		monitorType = csiDeploy.Spec.Metrics.Monitor
+	}
+
+	var (
+		updated bool
+		errs    types.ErrorList
+	)
+	for _, monitor := range r.generateMonitors(csiDeploy) {
+		if !r.hasKind(monitor.GroupVersionKind()) {
+			if monitorType == csiv1.CSIMonitorType(monitor.GetKind()) {
+				klog.V(4).Infof("%s is not supported by the cluster, skip it for %s/%s",
+					monitor.GetKind(), csiDeploy.Namespace, csiDeploy.Name)
+			}
+			continue
+		}
+
+		key := k8stypes.NamespacedName{Namespace: monitor.GetNamespace(), Name: monitor.GetName()}
+		exist := &unstructured.Unstructured{}
+		exist.SetGroupVersionKind(monitor.GroupVersionKind())
+		var (
+			monitorUpdated bool
+			err            error
+		)
+		if monitorType == csiv1.CSIMonitorType(monitor.GetKind()) {
+			monitorUpdated, err = r.syncMonitor(exist, monitor)
+		} else {
+			monitorUpdated, err = r.deleteIfExist(key, exist)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sync %s %s failed: %v", monitor.GetKind(), key.String(), err))
+		} else if monitorUpdated {
+			updated = true
+		}
+	}
+
+	if len(errs) > 0 {
+		return updated, errs
+	}
+	return updated, nil
+}
+
+// syncMonitor creates or updates a prometheus-operator monitor.
+func (r *ReconcileCSI) syncMonitor(exist, monitor *unstructured.Unstructured) (bool, error) {
+	key := k8stypes.NamespacedName{Namespace: monitor.GetNamespace(), Name: monitor.GetName()}
+	err := r.getObject(key, exist)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			klog.Infof("Create %s %s", monitor.GetKind(), key.String())
+			return true, r.createObject(monitor)
+		}
+		return false, err
+	}
+	if equality.Semantic.DeepEqual(exist.Object["spec"], monitor.Object["spec"]) {
+		return false, nil
+	}
+	exist.Object["spec"] = monitor.Object["spec"]
+	klog.Infof("Update %s %s", monitor.GetKind(), key.String())
+	return true, r.updateObject(exist)
+}
+
+// generateMonitors generates all kinds of prometheus-operator monitors of a CSI.
+func (r *ReconcileCSI) generateMonitors(csiDeploy *csiv1.CSI) []*unstructured.Unstructured {
+	var monitors []*unstructured.Unstructured
+
+	// A ServiceMonitor selects both node and controller metrics Services.
+	var endpoints []interface{}
+	portNames := map[string]bool{}
+	for _, controller := range []bool{false, true} {
+		if controller && !hasController(csiDeploy) {
+			continue
+		}
+		for _, port := range r.metricsPodPorts(csiDeploy, controller) {
+			if !portNames[port.Name] {
+				portNames[port.Name] = true
+				endpoints = append(endpoints, map[string]interface{}{"port": port.Name})
+			}
+		}
+	}
+	monitors = append(monitors, newMonitor(csiv1.CSIServiceMonitor, csiDeploy, csiDeploy.Name, map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{metricsServiceLabel: csiDeploy.Name},
+		},
+		"namespaceSelector": map[string]interface{}{
+			"matchNames": []interface{}{csiDeploy.Namespace},
+		},
+		"endpoints": endpoints,
+	}))
+
+	// PodMonitors select pods by the selector labels of the node driver and controller driver.
+	for _, controller := range []bool{false, true} {
+		if controller && !hasController(csiDeploy) {
+			continue
+		}
+		label, name := nodeDriverLabel, csiDeploy.Name+"-node"
+		if controller {
+			label, name = controllerDriverLabel, csiDeploy.Name+"-controller"
+		}
+		var podEndpoints []interface{}
+		for _, port := range r.metricsPodPorts(csiDeploy, controller) {
+			podEndpoints = append(podEndpoints, map[string]interface{}{"port": port.Name})
+		}
+		monitors = append(monitors, newMonitor(csiv1.CSIPodMonitor, csiDeploy, name, map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{label: name},
+			},
+			"namespaceSelector": map[string]interface{}{
+				"matchNames": []interface{}{csiDeploy.Namespace},
+			},
+			"podMetricsEndpoints": podEndpoints,
+		}))
+	}
+
+	return monitors
+}
+
+// metricsPodPorts returns the metrics ports of the node driver or controller driver pods.
+func (r *ReconcileCSI) metricsPodPorts(csiDeploy *csiv1.CSI, controller bool) []corev1.ServicePort {
+	if !enhancer.MetricsEnabled(csiDeploy) {
+		return nil
+	}
+	if controller {
+		return metricsServicePorts(&r.generateControllerDriver(csiDeploy).Spec.Template.Spec)
+	}
+	return metricsServicePorts(&r.generateNodeDriver(csiDeploy).Spec.Template.Spec)
+}
+
+// newMonitor creates a prometheus-operator monitor object.
+func newMonitor(kind string, csiDeploy *csiv1.CSI, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	monitor := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	monitor.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   monitoringGroup,
+		Version: monitoringVersion,
+		Kind:    kind,
+	})
+	monitor.SetNamespace(csiDeploy.Namespace)
+	monitor.SetName(name)
+	monitor.SetLabels(map[string]string{metricsServiceLabel: csiDeploy.Name})
+	monitor.SetOwnerReferences(ownerReference(csiDeploy))
+	return monitor
+}
+
+// hasKind returns true if the kind is served by the cluster.
+func (r *ReconcileCSI) hasKind(gvk schema.GroupVersionKind) bool {
+	if r.mapper == nil {
+		return false
+	}
+	_, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil && !meta.IsNoMatchError(err) {
+		klog.Warningf("Get REST mapping of %s failed: %v", gvk.String(), err)
+	}
+	return err == nil
+}
+
+// deleteIfExist deletes an object if it exists, and returns true if it is deleted.
+func (r *ReconcileCSI) deleteIfExist(key k8stypes.NamespacedName, obj runtime.Object) (bool, error) {
+	if err := r.getObject(key, obj); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := r.deleteObject(obj); err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	klog.Infof("Delete %s", key.String())
+	return true, nil
+}
+
+// metricsServiceName returns the name of the metrics Service of the node driver or controller driver.
+func metricsServiceName(csiDeploy *csiv1.CSI, controller bool) string {
+	if controller {
+		return csiDeploy.Name + "-controller-metrics"
+	}
+	return csiDeploy.Name + "-node-metrics"
+}
@@ -21,11 +21,11 @@ import (
 	"fmt"
 
 	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/util"
 	"tkestack.io/csi-operator/pkg/types"
 
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
-	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -33,16 +33,16 @@ import (
 
 // syncStorageClasses creates or updates all StorageClasses needed by CephRBD and CephFS.
 func (r *ReconcileCSI) syncStorageClasses(csiDeploy *csiv1.CSI) (bool, error) {
-	storageClasses := &storagev1.StorageClassList{}
-	err := r.listObjects(storageClasses, &client.ListOptions{
+	existSCs := &storagev1.StorageClassList{}
+	err := r.listObjects(existSCs, &client.ListOptions{
 		LabelSelector: ownerLabelSelector(csiDeploy),
 	})
 	if err != nil {
 		return false, fmt.Errorf("list StorageClasses failed: %s", err.Error())
 	}
-	existSCSet := make(map[string]*storagev1.StorageClass, len(storageClasses.Items))
-	for i := range storageClasses.Items {
-		sc := &storageClasses.Items[i]
+	existSCSet := make(map[string]*storagev1.StorageClass, len(existSCs.Items))
+	for i := range existSCs.Items {
+		sc := &existSCs.Items[i]
 		existSCSet[sc.Name] = sc
 	}
 
@@ -55,8 +55,51 @@ func (r *ReconcileCSI) syncStorageClasses(csiDeploy *csiv1.CSI) (bool, error) {
 		csiDeploy.Spec.StorageClasses = []storagev1.StorageClass{}
 	}
 
-	for _, sc := range csiDeploy.Spec.StorageClasses {
+	retained := &storagev1.StorageClassList{}
+	if err := r.listObjects(retained, &client.ListOptions{
+		LabelSelector: retainedLabelSelector(csiDeploy),
+	}); err != nil {
+		return false, fmt.Errorf("list retained StorageClasses failed: %s", err.Error())
+	}
+
+	known := make([]storagev1.StorageClass, 0, len(existSCs.Items)+len(retained.Items))
+	known = append(append(known, existSCs.Items...), retained.Items...)
+	storageClasses, err := r.topologyStorageClasses(csiDeploy, known)
+	if err != nil {
+		// Don't touch the existing StorageClasses if the zones are unknown.
+		errs = append(errs, err)
+		return false, errs
+	}
+	desired := make(map[string]bool, len(storageClasses))
+	for i := range storageClasses {
+		desired[storageClasses[i].Name] = true
+	}
+
+	// Adopt the StorageClasses retained by a deleted CSI with the same namespace and name.
+	for i := range retained.Items {
+		sc := &retained.Items[i]
+		if _, exist := existSCSet[sc.Name]; exist || !desired[sc.Name] {
+			continue
+		}
+		if err := r.adoptObject(csiDeploy, sc, false); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		existSCSet[sc.Name] = sc
+	}
+
+	defaultName, defaultManaged, defaultErr := r.resolveDefaultStorageClass(csiDeploy)
+	if defaultErr != nil {
+		errs = append(errs, defaultErr)
+	}
+
+	for _, sc := range storageClasses {
 		exist := existSCSet[sc.Name]
+		// The default annotations are only set if some CSI names a default StorageClass, or reset if
+		// the operator set them before, so that the ones set by administrators are kept.
+		if defaultErr == nil && (defaultManaged || defaultClassManaged(exist)) {
+			setDefaultClassAnnotations(&sc, sc.Name == defaultName)
+		}
 		delete(existSCSet, sc.Name)
 		if scUpdated, err := r.syncStorageClass(exist, sc, csiDeploy); err != nil {
 			errs = append(errs, err)
@@ -65,14 +108,15 @@ func (r *ReconcileCSI) syncStorageClasses(csiDeploy *csiv1.CSI) (bool, error) {
 		}
 	}
 
-	// Remaining StorageClasses are no longer needed by this CSI, delete them.
+	// Remaining StorageClasses are no longer needed by this CSI, delete or release them.
 	for _, sc := range existSCSet {
-		if err := r.deleteObject(sc); err != nil {
+		if err := r.releaseOrDelete(csiDeploy, sc); err != nil {
 			errs = append(errs, err)
 		} else {
 			updated = true
 		}
 	}
+	recordStorageClassesMetrics(csiDeploy, len(storageClasses))
 
 	if len(errs) != 0 {
 		return updated, errs
@@ -92,20 +136,28 @@ func (r *ReconcileCSI) syncStorageClass(
 
 	if exist != nil {
 		// StorageClass already exists, update it if necessary.
-		updateObj := sc.DeepCopy()
-		updateObj.TypeMeta = exist.TypeMeta
-		updateObj.ObjectMeta = exist.ObjectMeta
-		filterSCDefaultFields(updateObj, exist)
-		updated := !equality.Semantic.DeepEqual(updateObj, exist)
-		if mergeObjectMeta(&sc.ObjectMeta, &updateObj.ObjectMeta) {
-			updated = true
+		updateObj, recreate := util.DiffStorageClass(exist, &sc)
+		if !recreate {
+			if updateObj == nil {
+				return false, nil
+			}
+			klog.Infof("Update StorageClass %s for %s/%s", sc.Name, csiDeploy.Namespace, csiDeploy.Name)
+			return true, r.updateObject(updateObj)
 		}
-		if !updated {
+
+		// Immutable fields changed, the StorageClass can only be recreated, which makes
+		// PVCs creation fail until it is created again, so it must be opted in.
+		if sc.Annotations[types.RecreateStorageClassKey] != "true" &&
+			csiDeploy.Annotations[types.RecreateStorageClassKey] != "true" {
+			r.recorder.Eventf(csiDeploy, corev1.EventTypeWarning, types.StorageClassChangeBlocked,
+				"Immutable fields of StorageClass %s are changed, set annotation %s to true to recreate it",
+				sc.Name, types.RecreateStorageClassKey)
 			return false, nil
 		}
-		// Only update the SotrageClass object if needed.
-		klog.Infof("StorageClass %s of %s/%s is changed, delete it first",
+		klog.Infof("Immutable fields of StorageClass %s of %s/%s are changed, delete it first",
 			sc.Name, csiDeploy.Namespace, csiDeploy.Name)
+		r.recorder.Eventf(csiDeploy, corev1.EventTypeNormal, types.StorageClassRecreated,
+			"Immutable fields of StorageClass %s are changed, recreate it", sc.Name)
 		if err := r.deleteObject(exist); err != nil {
 			return false, fmt.Errorf("delete old StorageClass %s of %s/%s failed: %v",
 				sc.Name, csiDeploy.Namespace, csiDeploy.Name, err)
@@ -117,7 +169,7 @@ func (r *ReconcileCSI) syncStorageClass(
 	return true, r.createObject(&sc)
 }
 
-// clearStorageClasses deletes all StorageClasses owned by a specified CSI.
+// clearStorageClasses deletes or releases all StorageClasses owned by a specified CSI.
 func (r *ReconcileCSI) clearStorageClasses(csiDeploy *csiv1.CSI) error {
 	// List the StorageClasses by owner label.
 	list := &storagev1.StorageClassList{}
@@ -133,11 +185,10 @@ func (r *ReconcileCSI) clearStorageClasses(csiDeploy *csiv1.CSI) error {
 	}
 
 	var errs types.ErrorList
-	for _, sc := range list.Items {
-		if err := r.deleteObject(&sc); err != nil && !errors.IsNotFound(err) {
-			errs = append(errs, fmt.Errorf("delete StorageClass %s failed: %s", sc.Name, err.Error()))
+	for i := range list.Items {
+		if err := r.releaseOrDelete(csiDeploy, &list.Items[i]); err != nil {
+			errs = append(errs, fmt.Errorf("clear StorageClass failed: %s", err.Error()))
 		}
-		klog.V(4).Infof("StorageClass %s deleted", sc.Name)
 	}
 
 	if len(errs) > 0 {
@@ -146,16 +197,12 @@ func (r *ReconcileCSI) clearStorageClasses(csiDeploy *csiv1.CSI) error {
 	return nil
 }
 
-// Clear unconcerned fields when comparing two StorageClass object.
-func filterSCDefaultFields(sc, ref *storagev1.StorageClass) {
-	if sc.ReclaimPolicy == nil &&
-		ref.ReclaimPolicy != nil &&
-		*ref.ReclaimPolicy == corev1.PersistentVolumeReclaimDelete {
-		sc.ReclaimPolicy = ref.ReclaimPolicy
-	}
-	if sc.VolumeBindingMode == nil &&
-		ref.VolumeBindingMode != nil &&
-		*ref.VolumeBindingMode == storagev1.VolumeBindingImmediate {
-		sc.VolumeBindingMode = ref.VolumeBindingMode
+// hasStorageClass returns true if a StorageClass is required by the CSI.
+func hasStorageClass(csiDeploy *csiv1.CSI, name string) bool {
+	for _, sc := range csiDeploy.Spec.StorageClasses {
+		if sc.Name == name {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,176 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/csi/enhancer"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// topologyKey returns the zone topology key of a CSI, or an empty string if the driver is not zonal.
+func topologyKey(csiDeploy *csiv1.CSI) string {
+	if topology := csiDeploy.Spec.Topology; topology != nil && topology.Key != "" {
+		return topology.Key
+	}
+	return enhancer.TopologyKey(csiDeploy.Spec.DriverName)
+}
+
+// topologyEnabled returns true if the Topology feature of the provisioner should be turned on.
+func topologyEnabled(csiDeploy *csiv1.CSI) bool {
+	// Keep the feature on for CSI objects created before the topology settings.
+	return csiDeploy.Spec.Topology == nil || csiDeploy.Spec.Topology.Enabled
+}
+
+// topologyStorageClasses returns the StorageClasses of a CSI with the topology settings applied.
+// The known StorageClasses are the ones already created for the CSI, used to keep their zones.
+func (r *ReconcileCSI) topologyStorageClasses(
+	csiDeploy *csiv1.CSI,
+	known []storagev1.StorageClass) ([]storagev1.StorageClass, error) {
+	topology := csiDeploy.Spec.Topology
+	if topology == nil || !topology.Enabled {
+		return csiDeploy.Spec.StorageClasses, nil
+	}
+
+	key := topologyKey(csiDeploy)
+	bindingMode := topology.VolumeBindingMode
+	if bindingMode == nil && key != "" {
+		mode := storagev1.VolumeBindingWaitForFirstConsumer
+		bindingMode = &mode
+	}
+
+	storageClasses := make([]storagev1.StorageClass, 0, len(csiDeploy.Spec.StorageClasses))
+	for i := range csiDeploy.Spec.StorageClasses {
+		sc := csiDeploy.Spec.StorageClasses[i].DeepCopy()
+		if sc.VolumeBindingMode == nil && bindingMode != nil {
+			mode := *bindingMode
+			sc.VolumeBindingMode = &mode
+		}
+		storageClasses = append(storageClasses, *sc)
+	}
+	if !topology.PerZoneStorageClasses || key == "" {
+		return storageClasses, nil
+	}
+
+	zones, err := r.discoverZones(csiDeploy, key, knownZones(csiDeploy, key, known))
+	if err != nil {
+		return nil, err
+	}
+	perZone := make([]storagev1.StorageClass, 0, len(storageClasses)*len(zones))
+	for i := range storageClasses {
+		// StorageClasses restricted by the user are not split.
+		if len(storageClasses[i].AllowedTopologies) > 0 {
+			continue
+		}
+		for _, zone := range zones {
+			sc := storageClasses[i].DeepCopy()
+			sc.Name = zoneStorageClassName(sc.Name, zone)
+			sc.AllowedTopologies = []corev1.TopologySelectorTerm{{
+				MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{
+					Key:    key,
+					Values: []string{zone},
+				}},
+			}}
+			perZone = append(perZone, *sc)
+		}
+	}
+	return append(storageClasses, perZone...), nil
+}
+
+// discoverZones returns the zones of the nodes the driver is registered on. The known zones
+// are kept while they still have nodes, so that a zone whose drivers are restarting or
+// being upgraded doesn't lose its StorageClasses. They are dropped once their nodes are gone.
+func (r *ReconcileCSI) discoverZones(
+	csiDeploy *csiv1.CSI,
+	key string,
+	known map[string]bool) ([]string, error) {
+	csiNodes := &storagev1.CSINodeList{}
+	if err := r.listObjects(csiNodes, &client.ListOptions{}); err != nil {
+		return nil, fmt.Errorf("list CSINodes failed: %s", err.Error())
+	}
+	registered := make(map[string]bool, len(csiNodes.Items))
+	for i := range csiNodes.Items {
+		for _, driver := range csiNodes.Items[i].Spec.Drivers {
+			if driver.Name == csiDeploy.Spec.DriverName {
+				registered[csiNodes.Items[i].Name] = true
+				break
+			}
+		}
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := r.listObjects(nodes, &client.ListOptions{}); err != nil {
+		return nil, fmt.Errorf("list Nodes failed: %s", err.Error())
+	}
+	zoneSet := make(map[string]bool)
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		zone := node.Labels[key]
+		if zone != "" && (registered[node.Name] || known[zone]) {
+			zoneSet[zone] = true
+		}
+	}
+
+	zones := make([]string, 0, len(zoneSet))
+	for zone := range zoneSet {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	return zones, nil
+}
+
+// knownZones returns the zones of the per-zone StorageClasses among the known ones.
+func knownZones(csiDeploy *csiv1.CSI, key string, known []storagev1.StorageClass) map[string]bool {
+	zones := make(map[string]bool)
+	for i := range known {
+		terms := known[i].AllowedTopologies
+		if len(terms) != 1 || len(terms[0].MatchLabelExpressions) != 1 {
+			continue
+		}
+		requirement := terms[0].MatchLabelExpressions[0]
+		if requirement.Key != key || len(requirement.Values) != 1 {
+			continue
+		}
+		zone := requirement.Values[0]
+		for j := range csiDeploy.Spec.StorageClasses {
+			if known[i].Name == zoneStorageClassName(csiDeploy.Spec.StorageClasses[j].Name, zone) {
+				zones[zone] = true
+				break
+			}
+		}
+	}
+	return zones
+}
+
+// zoneStorageClassName returns the name of the StorageClass restricted to a zone.
+func zoneStorageClassName(name, zone string) string {
+	zone = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(zone))
+	return name + "-" + strings.Trim(zone, "-.")
+}
@@ -0,0 +1,155 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"reflect"
+	"testing"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestZoneStorageClassName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		zone     string
+		expected string
+	}{
+		{"sc", "ap-guangzhou-3", "sc-ap-guangzhou-3"},
+		{"sc", "Zone_A", "sc-zone-a"},
+		{"sc", "us-east-1a", "sc-us-east-1a"},
+		{"sc", "_zone.", "sc-zone"},
+	}
+
+	for _, tc := range testCases {
+		if name := zoneStorageClassName(tc.name, tc.zone); name != tc.expected {
+			t.Errorf("zone %s: expected %s, got %s", tc.zone, tc.expected, name)
+		}
+	}
+}
+
+func zoneNode(name, zone string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"zone": zone}}}
+}
+
+func registeredCSINode(name, driver string) *storagev1.CSINode {
+	return &storagev1.CSINode{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       storagev1.CSINodeSpec{Drivers: []storagev1.CSINodeDriver{{Name: driver, NodeID: name}}},
+	}
+}
+
+func zoneStorageClass(name, zone string) storagev1.StorageClass {
+	return storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		AllowedTopologies: []corev1.TopologySelectorTerm{{
+			MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: "zone", Values: []string{zone}}},
+		}},
+	}
+}
+
+func TestTopologyStorageClasses(t *testing.T) {
+	immediate := storagev1.VolumeBindingImmediate
+
+	testCases := []struct {
+		name          string
+		topology      *csiv1.CSITopology
+		objects       []runtime.Object
+		known         []storagev1.StorageClass
+		expectedNames []string
+		expectedMode  *storagev1.VolumeBindingMode
+	}{
+		{
+			name:          "topology disabled",
+			topology:      &csiv1.CSITopology{Key: "zone"},
+			expectedNames: []string{"sc", "restricted"},
+		},
+		{
+			name:          "binding mode defaults to WaitForFirstConsumer for zonal drivers",
+			topology:      &csiv1.CSITopology{Enabled: true, Key: "zone"},
+			expectedNames: []string{"sc", "restricted"},
+			expectedMode:  bindingModePtr(storagev1.VolumeBindingWaitForFirstConsumer),
+		},
+		{
+			name:          "binding mode set explicitly",
+			topology:      &csiv1.CSITopology{Enabled: true, Key: "zone", VolumeBindingMode: &immediate},
+			expectedNames: []string{"sc", "restricted"},
+			expectedMode:  &immediate,
+		},
+		{
+			name:     "per-zone StorageClasses of registered nodes",
+			topology: &csiv1.CSITopology{Enabled: true, Key: "zone", PerZoneStorageClasses: true},
+			objects: []runtime.Object{
+				zoneNode("node1", "b"), zoneNode("node2", "a"), zoneNode("node3", "c"),
+				registeredCSINode("node1", "driver"), registeredCSINode("node2", "driver"),
+				registeredCSINode("node3", "other"),
+			},
+			expectedNames: []string{"sc", "restricted", "sc-a", "sc-b"},
+			expectedMode:  bindingModePtr(storagev1.VolumeBindingWaitForFirstConsumer),
+		},
+		{
+			name:     "known zone is kept while it has nodes",
+			topology: &csiv1.CSITopology{Enabled: true, Key: "zone", PerZoneStorageClasses: true},
+			objects: []runtime.Object{
+				zoneNode("node1", "a"), zoneNode("node2", "b"),
+			},
+			known:         []storagev1.StorageClass{zoneStorageClass("sc-a", "a"), zoneStorageClass("sc-c", "c")},
+			expectedNames: []string{"sc", "restricted", "sc-a"},
+			expectedMode:  bindingModePtr(storagev1.VolumeBindingWaitForFirstConsumer),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			csiDeploy := &csiv1.CSI{}
+			csiDeploy.Spec.DriverName = "driver"
+			csiDeploy.Spec.Topology = tc.topology
+			csiDeploy.Spec.StorageClasses = []storagev1.StorageClass{
+				{ObjectMeta: metav1.ObjectMeta{Name: "sc"}},
+				zoneStorageClass("restricted", "a"),
+			}
+			r := &ReconcileCSI{client: fake.NewFakeClientWithScheme(scheme.Scheme, tc.objects...)}
+
+			storageClasses, err := r.topologyStorageClasses(csiDeploy, tc.known)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			names := make([]string, 0, len(storageClasses))
+			for _, sc := range storageClasses {
+				names = append(names, sc.Name)
+				if !reflect.DeepEqual(sc.VolumeBindingMode, tc.expectedMode) {
+					t.Errorf("expected binding mode %v of %s, got %v", tc.expectedMode, sc.Name, sc.VolumeBindingMode)
+				}
+			}
+			if !reflect.DeepEqual(names, tc.expectedNames) {
+				t.Errorf("expected StorageClasses %v, got %v", tc.expectedNames, names)
+			}
+		})
+	}
+}
+
+func bindingModePtr(mode storagev1.VolumeBindingMode) *storagev1.VolumeBindingMode {
+	return &mode
+}
@@ -18,10 +18,8 @@
 package csi
 
 import (
-	"context"
-	"time"
-
 	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/util"
 
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -32,44 +30,29 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-const (
-	// TODO: Make this configurable.
-	apiTimeout = time.Minute
-)
-
 // getObject returns a specific object from k8s.
 func (r *ReconcileCSI) getObject(key k8stypes.NamespacedName, object runtime.Object) error {
-	ctx, cancel := getContext()
-	defer cancel()
-	return r.client.Get(ctx, key, object)
+	return util.GetObject(r.client, key, object)
 }
 
 // createObject creates an object to k8s.
 func (r *ReconcileCSI) createObject(object runtime.Object) error {
-	ctx, cancel := getContext()
-	defer cancel()
-	return r.client.Create(ctx, object)
+	return util.CreateObject(r.client, object)
 }
 
 // updateObject updates an object to k8s.
 func (r *ReconcileCSI) updateObject(object runtime.Object) error {
-	ctx, cancel := getContext()
-	defer cancel()
-	return r.client.Update(ctx, object)
+	return util.UpdateObject(r.client, object)
 }
 
 // listObjects list objects from k8s.
 func (r *ReconcileCSI) listObjects(list runtime.Object, opts *client.ListOptions) error {
-	ctx, cancel := getContext()
-	defer cancel()
-	return r.client.List(ctx, list, opts)
+	return util.ListObjects(r.client, list, opts)
 }
 
 // deleteObject deletes an object in k8s.
 func (r *ReconcileCSI) deleteObject(obj runtime.Object) error {
-	ctx, cancel := getContext()
-	defer cancel()
-	return r.client.Delete(ctx, obj)
+	return util.DeleteObject(r.client, obj)
 }
 
 // updateCSIStatus updates CSI's status.
@@ -77,14 +60,7 @@ func (r *ReconcileCSI) updateCSIStatus(oldDeploy, newDeploy *csiv1.CSI) error {
 	if equality.Semantic.DeepEqual(oldDeploy.Status, newDeploy.Status) {
 		return nil
 	}
-	ctx, cancel := getContext()
-	defer cancel()
-	return r.client.Status().Update(ctx, newDeploy)
-}
-
-// getContext creates a Context with a specified timeout.
-func getContext() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), apiTimeout)
+	return util.UpdateStatus(r.client, newDeploy)
 }
 
 // isTerminating returns true if the CSI object is deleted by user.
@@ -107,48 +83,6 @@ func hasSameGeneration(obj runtime.Object, gvk schema.GroupVersionKind, csiDeplo
 	return false
 }
 
-// mergeObjectMeta merges src into dst and returns true if dst is changed.
-func mergeObjectMeta(src, dst *metav1.ObjectMeta) bool {
-	changed := false
-
-	if !equality.Semantic.DeepEqual(src.OwnerReferences, dst.OwnerReferences) {
-		changed = true
-		dst.OwnerReferences = src.OwnerReferences
-	}
-
-	// We can't just copy labels as it maybe remove system added labels.
-	if dst.Labels == nil {
-		if src.Labels != nil {
-			changed = true
-			dst.Labels = src.Labels
-		}
-	} else {
-		for k, v := range src.Labels {
-			if dst.Labels[k] != v {
-				changed = true
-				dst.Labels[k] = v
-			}
-		}
-	}
-
-	// We can't just copy annotations as it maybe remove system added annotations.
-	if dst.Annotations == nil {
-		if src.Annotations != nil {
-			changed = true
-			dst.Annotations = src.Annotations
-		}
-	} else {
-		for k, v := range src.Annotations {
-			if dst.Annotations[k] != v {
-				changed = true
-				dst.Annotations[k] = v
-			}
-		}
-	}
-
-	return changed
-}
-
 // mergeLabels merges labels into meta.
 func mergeLabels(meta *metav1.ObjectMeta, labels map[string]string) {
 	if meta.Labels == nil {
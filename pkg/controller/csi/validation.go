@@ -18,12 +18,16 @@
 package csi
 
 import (
+	"fmt"
 	"regexp"
 
 	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
 
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
@@ -42,6 +46,15 @@ func (r *ReconcileCSI) validateCSIObject(csiDeploy *csiv1.CSI) field.ErrorList {
 		fieldPath.Child("driverName"))...)
 	errs = append(errs, r.validateDriverTemplate(csiDeploy.Spec.DriverTemplate,
 		fieldPath.Child("nodeDriverTemplate"))...)
+	errs = append(errs, r.validateMetrics(csiDeploy.Spec.Metrics,
+		fieldPath.Child("metrics"))...)
+	errs = append(errs, r.validateMetricsConflicts(csiDeploy, fieldPath.Child("metrics"))...)
+	errs = append(errs, r.validateDeletionPolicy(csiDeploy.Spec.DeletionPolicy,
+		fieldPath.Child("deletionPolicy"))...)
+	errs = append(errs, r.validateStorageClassTemplates(csiDeploy.Spec.StorageClassTemplates,
+		fieldPath.Child("storageClassTemplates"))...)
+	errs = append(errs, r.validateTopology(csiDeploy, fieldPath.Child("topology"))...)
+	errs = append(errs, r.enhancer.Validate(csiDeploy, fieldPath)...)
 
 	return errs
 }
@@ -78,13 +91,219 @@ func (r *ReconcileCSI) validateDriverTemplate(
 	}
 
 	// CSIDriverTemplate should contains one and only one container.
-	switch len(template.Template.Spec.Containers) {
+	errs = append(errs, validateDriverContainers(template.Template.Spec.Containers, fieldPath)...)
+	if template.ControllerTemplate != nil {
+		errs = append(errs, validateDriverContainers(template.ControllerTemplate.Spec.Containers,
+			fieldPath.Child("controllerTemplate"))...)
+	}
+	errs = append(errs, validateNodeSidecars(template, fieldPath.Child("nodeSidecars"))...)
+
+	return errs
+}
+
+// validateNodeSidecars checks whether the sidecars of the node driver are valid.
+func validateNodeSidecars(template *csiv1.CSIDriverTemplate, fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	names := map[string]bool{"node-driver-registrar": true, "liveness-probe": true}
+	for _, container := range template.Template.Spec.Containers {
+		names[container.Name] = true
+	}
+	for i, sidecar := range template.NodeSidecars {
+		idxPath := fieldPath.Index(i)
+		if len(sidecar.Name) == 0 {
+			errs = append(errs, field.Required(idxPath.Child("name"), ""))
+		} else if names[sidecar.Name] {
+			errs = append(errs, field.Duplicate(idxPath.Child("name"), sidecar.Name))
+		}
+		if len(sidecar.Image) == 0 {
+			errs = append(errs, field.Required(idxPath.Child("image"), ""))
+		}
+		names[sidecar.Name] = true
+	}
+
+	return errs
+}
+
+// validateDriverContainers checks whether a template of the driver contains one and only one container.
+func validateDriverContainers(containers []corev1.Container, fieldPath *field.Path) field.ErrorList {
+	switch len(containers) {
 	case 0:
-		errs = append(errs, field.Invalid(fieldPath, template.Template.Spec.Containers, validation.EmptyError()))
+		return field.ErrorList{field.Invalid(fieldPath, containers, validation.EmptyError())}
 	case 1:
+		return nil
+	default:
+		return field.ErrorList{field.Invalid(fieldPath, containers, "must have one and only one container")}
+	}
+}
+
+// validateMetrics checks whether the metrics configuration is valid.
+func (r *ReconcileCSI) validateMetrics(metrics *csiv1.CSIMetrics, fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if metrics == nil {
+		return errs
+	}
+
+	// Each component reserves maxMetricsPorts ports for the driver and its sidecars.
+	for _, port := range []struct {
+		value int32
+		path  *field.Path
+	}{
+		{metrics.NodePort, fieldPath.Child("nodePort")},
+		{metrics.ControllerPort, fieldPath.Child("controllerPort")},
+	} {
+		if port.value == 0 {
+			continue
+		}
+		for _, msg := range validation.IsValidPortNum(int(port.value + maxMetricsPorts - 1)) {
+			errs = append(errs, field.Invalid(port.path, port.value, msg))
+		}
+		if port.value < 1 {
+			errs = append(errs, field.Invalid(port.path, port.value, "must be greater than 0"))
+		}
+	}
+
+	nodePort, controllerPort := metrics.NodePort, metrics.ControllerPort
+	if nodePort == 0 {
+		nodePort = nodeMetricsPort
+	}
+	if controllerPort == 0 {
+		controllerPort = controllerMetricsPort
+	}
+	if nodePort < controllerPort+maxMetricsPorts && controllerPort < nodePort+maxMetricsPorts {
+		errs = append(errs, field.Invalid(fieldPath.Child("controllerPort"), metrics.ControllerPort,
+			fmt.Sprintf("must be at least %d ports away from the node port", maxMetricsPorts)))
+	}
+
+	switch metrics.Monitor {
+	case "", csiv1.CSIServiceMonitor, csiv1.CSIPodMonitor:
+	default:
+		errs = append(errs, field.NotSupported(fieldPath.Child("monitor"), metrics.Monitor,
+			[]string{string(csiv1.CSIServiceMonitor), string(csiv1.CSIPodMonitor)}))
+	}
+
+	return errs
+}
+
+// validateMetricsConflicts checks whether the metrics ports of a CSI overlap the ones of the CSI objects
+// claiming them earlier, which conflict on the nodes running both drivers on the host network.
+// Only the newer claim is invalid, so that the CSI objects already running are not broken by it.
+func (r *ReconcileCSI) validateMetricsConflicts(csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList {
+	ports := knownMetricsPorts(csiDeploy)
+	if len(ports) == 0 {
+		return nil
+	}
+
+	csiList := &csiv1.CSIList{}
+	if err := r.listObjects(csiList, &client.ListOptions{}); err != nil {
+		return field.ErrorList{field.InternalError(fieldPath, fmt.Errorf("list CSI objects failed: %s", err.Error()))}
+	}
+	var errs field.ErrorList
+	for i := range csiList.Items {
+		other := &csiList.Items[i]
+		if !claimedBefore(other, csiDeploy) {
+			continue
+		}
+		for _, port := range ports {
+			for _, otherPort := range knownMetricsPorts(other) {
+				if port < otherPort+maxMetricsPorts && otherPort < port+maxMetricsPorts {
+					errs = append(errs, field.Invalid(fieldPath, port, fmt.Sprintf(
+						"metrics ports %d-%d overlap the ports %d-%d of %s/%s", port, port+maxMetricsPorts-1,
+						otherPort, otherPort+maxMetricsPorts-1, other.Namespace, other.Name)))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// claimedBefore returns true if CSI a claims its metrics ports before b. A CSI synced with its current
+// spec holds its ports against the ones not synced yet, such as the created or changed ones, otherwise
+// the earlier created one holds them.
+func claimedBefore(a, b *csiv1.CSI) bool {
+	if synced(a) != synced(b) {
+		return synced(a)
+	}
+	return createdBefore(a, b)
+}
+
+// synced returns true if a CSI is synced with its current spec.
+func synced(csiDeploy *csiv1.CSI) bool {
+	return csiDeploy.Status.ObservedGeneration == csiDeploy.Generation
+}
+
+// createdBefore returns true if CSI a is created before b, CSI objects created at the same time
+// are ordered by their namespaces and names.
+func createdBefore(a, b *csiv1.CSI) bool {
+	if a.Namespace == b.Namespace && a.Name == b.Name {
+		return false
+	}
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+}
+
+// validateDeletionPolicy checks whether the deletion policy is supported.
+func (r *ReconcileCSI) validateDeletionPolicy(policy csiv1.CSIDeletionPolicy, fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	switch policy {
+	case "", csiv1.CSIDeletionDelete, csiv1.CSIDeletionOrphan, csiv1.CSIDeletionRetain:
 	default:
-		errs = append(errs, field.Invalid(fieldPath,
-			template.Template.Spec.Containers, "must have one and only one container"))
+		errs = append(errs, field.NotSupported(fieldPath, policy,
+			[]string{csiv1.CSIDeletionDelete, csiv1.CSIDeletionOrphan, csiv1.CSIDeletionRetain}))
+	}
+
+	return errs
+}
+
+// validateStorageClassTemplates checks whether the StorageClass templates are valid.
+func (r *ReconcileCSI) validateStorageClassTemplates(
+	templates []csiv1.CSIStorageClassTemplate,
+	fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	names := make(map[string]bool, len(templates))
+	for i := range templates {
+		template := &templates[i]
+		if template.Name == "" {
+			continue
+		}
+		if template.Base == "" {
+			errs = append(errs, field.Required(fieldPath.Index(i).Child("base"), "base must be set if name is set"))
+		}
+		if names[template.Name] {
+			errs = append(errs, field.Duplicate(fieldPath.Index(i).Child("name"), template.Name))
+		}
+		names[template.Name] = true
+	}
+
+	return errs
+}
+
+// validateTopology checks whether the topology settings are valid.
+func (r *ReconcileCSI) validateTopology(csiDeploy *csiv1.CSI, fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	topology := csiDeploy.Spec.Topology
+	if topology == nil {
+		return errs
+	}
+	if topology.Key != "" {
+		for _, msg := range validation.IsQualifiedName(topology.Key) {
+			errs = append(errs, field.Invalid(fieldPath.Child("key"), topology.Key, msg))
+		}
+	}
+	if mode := topology.VolumeBindingMode; mode != nil &&
+		*mode != storagev1.VolumeBindingImmediate && *mode != storagev1.VolumeBindingWaitForFirstConsumer {
+		errs = append(errs, field.NotSupported(fieldPath.Child("volumeBindingMode"), *mode,
+			[]string{string(storagev1.VolumeBindingImmediate), string(storagev1.VolumeBindingWaitForFirstConsumer)}))
+	}
+	if topology.PerZoneStorageClasses && topologyKey(csiDeploy) == "" {
+		errs = append(errs, field.Required(fieldPath.Child("key"),
+			"key must be set to generate StorageClasses per zone"))
 	}
 
 	return errs
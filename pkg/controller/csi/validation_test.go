@@ -0,0 +1,112 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"testing"
+	"time"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestScheme returns a scheme of the k8s objects and the objects of the operator.
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatalf("add k8s objects to scheme failed: %v", err)
+	}
+	if err := csiv1.AddToScheme(s); err != nil {
+		t.Fatalf("add CSI objects to scheme failed: %v", err)
+	}
+	return s
+}
+
+func metricsCSI(name string, port int32, created time.Time, generation, observed int64) *csiv1.CSI {
+	return &csiv1.CSI{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "kube-system",
+			CreationTimestamp: metav1.NewTime(created),
+			Generation:        generation,
+		},
+		Spec: csiv1.CSISpec{
+			DriverName: name,
+			Metrics:    &csiv1.CSIMetrics{Enabled: true, NodePort: port},
+		},
+		Status: csiv1.CSIStatus{ObservedGeneration: observed},
+	}
+}
+
+func TestValidateMetricsConflicts(t *testing.T) {
+	earlier := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	testCases := []struct {
+		name      string
+		csiDeploy *csiv1.CSI
+		other     *csiv1.CSI
+		expectErr bool
+	}{
+		{
+			name:      "no overlap",
+			csiDeploy: metricsCSI("b", 9100, later, 1, 0),
+			other:     metricsCSI("a", 9105, earlier, 1, 1),
+		},
+		{
+			name:      "created later",
+			csiDeploy: metricsCSI("b", 9102, later, 1, 0),
+			other:     metricsCSI("a", 9100, earlier, 1, 1),
+			expectErr: true,
+		},
+		{
+			name:      "created earlier",
+			csiDeploy: metricsCSI("a", 9100, earlier, 1, 1),
+			other:     metricsCSI("b", 9102, later, 1, 0),
+		},
+		{
+			name:      "running one changed by the earlier created one",
+			csiDeploy: metricsCSI("b", 9102, later, 1, 1),
+			other:     metricsCSI("a", 9100, earlier, 2, 1),
+		},
+		{
+			name:      "earlier created one changed to the ports of a running one",
+			csiDeploy: metricsCSI("a", 9100, earlier, 2, 1),
+			other:     metricsCSI("b", 9102, later, 1, 1),
+			expectErr: true,
+		},
+		{
+			name:      "created at the same time",
+			csiDeploy: metricsCSI("a", 9100, later, 1, 0),
+			other:     metricsCSI("b", 9100, later, 1, 0),
+		},
+	}
+
+	for i, testCase := range testCases {
+		r := &ReconcileCSI{client: fake.NewFakeClientWithScheme(newTestScheme(t), testCase.csiDeploy, testCase.other)}
+		errs := r.validateMetricsConflicts(testCase.csiDeploy, field.NewPath("spec", "metrics"))
+		if (len(errs) > 0) != testCase.expectErr {
+			t.Errorf("case %d(%s): expect error %t, got %v", i, testCase.name, testCase.expectErr, errs)
+		}
+	}
+}
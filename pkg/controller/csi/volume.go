@@ -0,0 +1,125 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/util"
+	"tkestack.io/csi-operator/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+// maxReportedVolumes limits the number of volumes listed in the condition of a CSI object.
+const maxReportedVolumes = 20
+
+// checkVolumesReleased returns false if PersistentVolumes or live VolumeAttachments of the driver
+// still exist, as removing the driver would make them unmountable and undeletable.
+// The check can be skipped by the force-delete annotation.
+func (r *ReconcileCSI) checkVolumesReleased(csiDeploy *csiv1.CSI) (bool, error) {
+	if csiDeploy.Annotations[types.ForceDeleteKey] == "true" {
+		klog.Warningf("Force delete %s/%s without checking volumes", csiDeploy.Namespace, csiDeploy.Name)
+		updateCondition(csiDeploy, types.VolumesReleased, "Skipped by the force-delete annotation", corev1.ConditionTrue)
+		return true, nil
+	}
+
+	pvList := &corev1.PersistentVolumeList{}
+	if err := r.listObjects(pvList, &client.ListOptions{}); err != nil {
+		return false, fmt.Errorf("list PersistentVolumes failed: %s", err.Error())
+	}
+	var pvs []string
+	for _, pv := range pvList.Items {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csiDeploy.Spec.DriverName && volumeNeedsDriver(&pv) {
+			pvs = append(pvs, pv.Name)
+		}
+	}
+
+	vaList := &storagev1.VolumeAttachmentList{}
+	if err := r.listObjects(vaList, &client.ListOptions{}); err != nil {
+		return false, fmt.Errorf("list VolumeAttachments failed: %s", err.Error())
+	}
+	var vas []string
+	for _, va := range vaList.Items {
+		// A deleting VolumeAttachment still needs the driver to detach the volume.
+		if va.Spec.Attacher == csiDeploy.Spec.DriverName && (va.DeletionTimestamp == nil || va.Status.Attached) {
+			vas = append(vas, va.Name)
+		}
+	}
+
+	if len(pvs) == 0 && len(vas) == 0 {
+		updateCondition(csiDeploy, types.VolumesReleased, "", corev1.ConditionTrue)
+		return true, nil
+	}
+
+	message := fmt.Sprintf("Deletion is blocked by %d PersistentVolume(s) [%s] and %d VolumeAttachment(s) [%s] "+
+		"of driver %s, set annotation %s to true to force it",
+		len(pvs), truncateNames(pvs), len(vas), truncateNames(vas), csiDeploy.Spec.DriverName, types.ForceDeleteKey)
+	// Only record the event when the blocking volumes change, as it is checked on every reconcile.
+	if exist := util.FindCondition(csiDeploy.Status.Conditions, types.VolumesReleased); exist == nil ||
+		exist.Status != corev1.ConditionFalse || exist.Message != message {
+		r.recorder.Event(csiDeploy, corev1.EventTypeWarning, types.DeletionBlocked, message)
+	}
+	updateCondition(csiDeploy, types.VolumesReleased, message, corev1.ConditionFalse)
+	return false, nil
+}
+
+// volumeNeedsDriver returns true if a PersistentVolume still needs the driver. Released or Failed
+// volumes with the Retain reclaim policy are left to the administrators, others are still mounted,
+// provisioned, or deleted with their backing volumes by the driver.
+func volumeNeedsDriver(pv *corev1.PersistentVolume) bool {
+	switch pv.Status.Phase {
+	case corev1.VolumeReleased, corev1.VolumeFailed:
+		return pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain
+	}
+	return true
+}
+
+// truncateNames sorts names and joins at most maxReportedVolumes of them.
+func truncateNames(names []string) string {
+	sort.Strings(names)
+	if len(names) > maxReportedVolumes {
+		return strings.Join(names[:maxReportedVolumes], ", ") + ", ..."
+	}
+	return strings.Join(names, ", ")
+}
+
+// persistentVolumeDriverNames returns the driver name of a CSI PersistentVolume.
+func persistentVolumeDriverNames(object handler.MapObject) []string {
+	pv, ok := object.Object.(*corev1.PersistentVolume)
+	if !ok || pv.Spec.CSI == nil {
+		return nil
+	}
+	return []string{pv.Spec.CSI.Driver}
+}
+
+// volumeAttachmentDriverNames returns the attacher of a VolumeAttachment.
+func volumeAttachmentDriverNames(object handler.MapObject) []string {
+	va, ok := object.Object.(*storagev1.VolumeAttachment)
+	if !ok {
+		return nil
+	}
+	return []string{va.Spec.Attacher}
+}
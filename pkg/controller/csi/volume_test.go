@@ -0,0 +1,164 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package csi
+
+import (
+	"testing"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/util"
+	"tkestack.io/csi-operator/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func csiVolume(name, driver string, phase corev1.PersistentVolumePhase,
+	policy corev1.PersistentVolumeReclaimPolicy) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: policy,
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: driver, VolumeHandle: name},
+			},
+		},
+		Status: corev1.PersistentVolumeStatus{Phase: phase},
+	}
+}
+
+func TestVolumeNeedsDriver(t *testing.T) {
+	testCases := []struct {
+		phase    corev1.PersistentVolumePhase
+		policy   corev1.PersistentVolumeReclaimPolicy
+		expected bool
+	}{
+		{corev1.VolumePending, corev1.PersistentVolumeReclaimDelete, true},
+		{corev1.VolumePending, corev1.PersistentVolumeReclaimRetain, true},
+		{corev1.VolumeAvailable, corev1.PersistentVolumeReclaimDelete, true},
+		{corev1.VolumeAvailable, corev1.PersistentVolumeReclaimRetain, true},
+		{corev1.VolumeBound, corev1.PersistentVolumeReclaimDelete, true},
+		{corev1.VolumeBound, corev1.PersistentVolumeReclaimRetain, true},
+		{corev1.VolumeReleased, corev1.PersistentVolumeReclaimDelete, true},
+		{corev1.VolumeReleased, corev1.PersistentVolumeReclaimRetain, false},
+		{corev1.VolumeFailed, corev1.PersistentVolumeReclaimDelete, true},
+		{corev1.VolumeFailed, corev1.PersistentVolumeReclaimRetain, false},
+		{"", corev1.PersistentVolumeReclaimRetain, true},
+	}
+
+	for _, tc := range testCases {
+		pv := csiVolume("pv", "csi-rbd", tc.phase, tc.policy)
+		if needed := volumeNeedsDriver(pv); needed != tc.expected {
+			t.Errorf("phase %q with policy %s: expected %t, got %t", tc.phase, tc.policy, tc.expected, needed)
+		}
+	}
+}
+
+func TestCheckVolumesReleased(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		objects     []runtime.Object
+		expected    bool
+	}{
+		{
+			name:     "no volumes",
+			expected: true,
+		},
+		{
+			name: "volumes of other drivers",
+			objects: []runtime.Object{
+				csiVolume("pv-1", "csi-cephfs", corev1.VolumeBound, corev1.PersistentVolumeReclaimDelete),
+				&storagev1.VolumeAttachment{
+					ObjectMeta: metav1.ObjectMeta{Name: "va-1"},
+					Spec:       storagev1.VolumeAttachmentSpec{Attacher: "csi-cephfs"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "retained volumes",
+			objects: []runtime.Object{
+				csiVolume("pv-1", "csi-rbd", corev1.VolumeReleased, corev1.PersistentVolumeReclaimRetain),
+				csiVolume("pv-2", "csi-rbd", corev1.VolumeFailed, corev1.PersistentVolumeReclaimRetain),
+			},
+			expected: true,
+		},
+		{
+			name: "released volume to delete",
+			objects: []runtime.Object{
+				csiVolume("pv-1", "csi-rbd", corev1.VolumeReleased, corev1.PersistentVolumeReclaimDelete),
+			},
+		},
+		{
+			name: "pending volume",
+			objects: []runtime.Object{
+				csiVolume("pv-1", "csi-rbd", corev1.VolumePending, corev1.PersistentVolumeReclaimRetain),
+			},
+		},
+		{
+			name: "attached volume",
+			objects: []runtime.Object{
+				&storagev1.VolumeAttachment{
+					ObjectMeta: metav1.ObjectMeta{Name: "va-1"},
+					Spec:       storagev1.VolumeAttachmentSpec{Attacher: "csi-rbd"},
+				},
+			},
+		},
+		{
+			name:        "forced",
+			annotations: map[string]string{types.ForceDeleteKey: "true"},
+			objects: []runtime.Object{
+				csiVolume("pv-1", "csi-rbd", corev1.VolumeBound, corev1.PersistentVolumeReclaimDelete),
+			},
+			expected: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		csiDeploy := &csiv1.CSI{
+			ObjectMeta: metav1.ObjectMeta{Name: "rbd", Namespace: "kube-system", Annotations: tc.annotations},
+			Spec:       csiv1.CSISpec{DriverName: "csi-rbd"},
+		}
+		r := &ReconcileCSI{
+			client:   fake.NewFakeClientWithScheme(scheme.Scheme, tc.objects...),
+			recorder: record.NewFakeRecorder(10),
+		}
+		released, err := r.checkVolumesReleased(csiDeploy)
+		if err != nil {
+			t.Errorf("case %d(%s): check volumes failed: %v", i, tc.name, err)
+			continue
+		}
+		if released != tc.expected {
+			t.Errorf("case %d(%s): expected released %t, got %t", i, tc.name, tc.expected, released)
+		}
+		condition := util.FindCondition(csiDeploy.Status.Conditions, types.VolumesReleased)
+		expectedStatus := corev1.ConditionFalse
+		if tc.expected {
+			expectedStatus = corev1.ConditionTrue
+		}
+		if condition == nil || condition.Status != expectedStatus {
+			t.Errorf("case %d(%s): expected condition %s, got %+v", i, tc.name, expectedStatus, condition)
+		}
+	}
+}
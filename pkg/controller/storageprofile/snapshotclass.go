@@ -0,0 +1,240 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package storageprofile
+
+import (
+	"fmt"
+	"sort"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/csi/enhancer"
+	"tkestack.io/csi-operator/pkg/controller/util"
+	"tkestack.io/csi-operator/pkg/types"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	snapshotGroup          = "snapshot.storage.k8s.io"
+	snapshotClassKind      = "VolumeSnapshotClass"
+	snapshotterSecretName  = "csi.storage.k8s.io/snapshotter-secret-name"
+	snapshotterSecretNs    = "csi.storage.k8s.io/snapshotter-secret-namespace"
+	defaultDeletionPolicy  = "Delete"
+	snapshotVersionV1beta1 = "v1beta1"
+)
+
+// snapshotVersions are the versions of VolumeSnapshotClass supported, in order of preference.
+var snapshotVersions = []string{snapshotVersionV1beta1, "v1alpha1"}
+
+// syncVolumeSnapshotClasses creates, updates or deletes the VolumeSnapshotClasses of a StorageProfile.
+func (r *ReconcileStorageProfile) syncVolumeSnapshotClasses(
+	profile *csiv1.StorageProfile,
+	csiDeploy *csiv1.CSI) (bool, error) {
+	version := r.snapshotVersion()
+	if version == "" {
+		profile.Status.VolumeSnapshotClasses = nil
+		if len(profile.Spec.VolumeSnapshotClasses) > 0 {
+			return false, fmt.Errorf("%s is not served by the cluster", snapshotClassKind)
+		}
+		return false, nil
+	}
+	gvk := schema.GroupVersionKind{Group: snapshotGroup, Version: version, Kind: snapshotClassKind}
+
+	var (
+		updated bool
+		errs    types.ErrorList
+		names   []string
+	)
+
+	desired := make(map[string]bool, len(profile.Spec.VolumeSnapshotClasses))
+	for i := range profile.Spec.VolumeSnapshotClasses {
+		template := &profile.Spec.VolumeSnapshotClasses[i]
+		desired[template.Name] = true
+		class, err := generateVolumeSnapshotClass(profile, csiDeploy, template, gvk)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		classUpdated, err := r.syncVolumeSnapshotClass(profile, class)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if classUpdated {
+			updated = true
+		}
+		names = append(names, class.GetName())
+	}
+
+	// Delete the VolumeSnapshotClasses no longer declared by the profile.
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(snapshotClassKind + "List"))
+	if err := r.listObjects(list, &client.ListOptions{}); err != nil {
+		errs = append(errs, fmt.Errorf("list %s failed: %s", snapshotClassKind, err.Error()))
+	}
+	for i := range list.Items {
+		exist := &list.Items[i]
+		if desired[exist.GetName()] || !isControlledBy(exist, profile) {
+			continue
+		}
+		klog.Infof("Delete %s %s of StorageProfile %s", snapshotClassKind, exist.GetName(), profile.Name)
+		if err := r.deleteObject(exist); err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("delete %s %s failed: %s", snapshotClassKind, exist.GetName(), err.Error()))
+			continue
+		}
+		updated = true
+	}
+
+	sort.Strings(names)
+	profile.Status.VolumeSnapshotClasses = names
+
+	if len(errs) > 0 {
+		return updated, errs
+	}
+	return updated, nil
+}
+
+// generateVolumeSnapshotClass generates a VolumeSnapshotClass from a template and the CSI object.
+func generateVolumeSnapshotClass(
+	profile *csiv1.StorageProfile,
+	csiDeploy *csiv1.CSI,
+	template *csiv1.VolumeSnapshotClassTemplate,
+	gvk schema.GroupVersionKind) (*unstructured.Unstructured, error) {
+	params := make(map[string]interface{})
+	_, nameSet := template.Parameters[snapshotterSecretName]
+	_, namespaceSet := template.Parameters[snapshotterSecretNs]
+	if !nameSet || !namespaceSet {
+		secretParams, err := csiSecretParameters(csiDeploy)
+		if err != nil {
+			return nil, fmt.Errorf("snapshotter secret of %s template %s must be set: %s",
+				snapshotClassKind, template.Name, err.Error())
+		}
+		nameKey, namespaceKey := enhancer.ProvisionerSecretKeys(csiDeploy)
+		if name, exist := secretParams[nameKey]; exist {
+			params[snapshotterSecretName] = name
+		}
+		if namespace, exist := secretParams[namespaceKey]; exist {
+			params[snapshotterSecretNs] = namespace
+		}
+	}
+	for k, v := range template.Parameters {
+		params[k] = v
+	}
+
+	class := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	class.SetGroupVersionKind(gvk)
+	class.SetName(template.Name)
+	class.SetLabels(template.Labels)
+	class.SetAnnotations(template.Annotations)
+	class.SetOwnerReferences([]metav1.OwnerReference{ownerReference(profile)})
+	if len(params) > 0 {
+		class.Object["parameters"] = params
+	}
+	if gvk.Version == snapshotVersionV1beta1 {
+		policy := template.DeletionPolicy
+		if policy == "" {
+			policy = defaultDeletionPolicy
+		}
+		class.Object["driver"] = csiDeploy.Spec.DriverName
+		class.Object["deletionPolicy"] = policy
+	} else {
+		class.Object["snapshotter"] = csiDeploy.Spec.DriverName
+	}
+	return class, nil
+}
+
+// syncVolumeSnapshotClass creates or updates a single VolumeSnapshotClass of a StorageProfile.
+func (r *ReconcileStorageProfile) syncVolumeSnapshotClass(
+	profile *csiv1.StorageProfile,
+	class *unstructured.Unstructured) (bool, error) {
+	exist := &unstructured.Unstructured{}
+	exist.SetGroupVersionKind(class.GroupVersionKind())
+	if err := r.getObject(k8stypes.NamespacedName{Name: class.GetName()}, exist); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, fmt.Errorf("get %s %s failed: %s", snapshotClassKind, class.GetName(), err.Error())
+		}
+		klog.Infof("Create %s %s for StorageProfile %s", snapshotClassKind, class.GetName(), profile.Name)
+		return true, r.createObject(class)
+	}
+
+	if !isControlledBy(exist, profile) {
+		return false, fmt.Errorf("%s %s is not managed by StorageProfile %s",
+			snapshotClassKind, class.GetName(), profile.Name)
+	}
+
+	// Parameters, driver and deletionPolicy are immutable in most versions, so the
+	// VolumeSnapshotClass is recreated if any of them is changed. Recreating it doesn't
+	// affect existing VolumeSnapshots.
+	changed := false
+	for _, field := range []string{"parameters", "driver", "snapshotter", "deletionPolicy"} {
+		if !equality.Semantic.DeepEqual(exist.Object[field], class.Object[field]) {
+			changed = true
+			break
+		}
+	}
+	if changed {
+		klog.Infof("Recreate %s %s for StorageProfile %s", snapshotClassKind, class.GetName(), profile.Name)
+		if err := r.deleteObject(exist); err != nil && !errors.IsNotFound(err) {
+			return false, fmt.Errorf("delete old %s %s failed: %s", snapshotClassKind, class.GetName(), err.Error())
+		}
+		return true, r.createObject(class)
+	}
+
+	existMeta := metav1.ObjectMeta{
+		Labels:          exist.GetLabels(),
+		Annotations:     exist.GetAnnotations(),
+		OwnerReferences: exist.GetOwnerReferences(),
+	}
+	if !util.MergeObjectMeta(&metav1.ObjectMeta{
+		Labels:          class.GetLabels(),
+		Annotations:     class.GetAnnotations(),
+		OwnerReferences: class.GetOwnerReferences(),
+	}, &existMeta) {
+		return false, nil
+	}
+	exist.SetLabels(existMeta.Labels)
+	exist.SetAnnotations(existMeta.Annotations)
+	exist.SetOwnerReferences(existMeta.OwnerReferences)
+	klog.Infof("Update %s %s for StorageProfile %s", snapshotClassKind, class.GetName(), profile.Name)
+	return true, r.updateObject(exist)
+}
+
+// snapshotVersion returns the preferred version of VolumeSnapshotClass served by the cluster,
+// or an empty string if it is not served.
+func (r *ReconcileStorageProfile) snapshotVersion() string {
+	if r.mapper == nil {
+		return ""
+	}
+	for _, version := range snapshotVersions {
+		gk := schema.GroupKind{Group: snapshotGroup, Kind: snapshotClassKind}
+		if _, err := r.mapper.RESTMapping(gk, version); err == nil {
+			return version
+		} else if !meta.IsNoMatchError(err) {
+			klog.Warningf("Get REST mapping of %s failed: %v", gk.WithVersion(version).String(), err)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,217 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package storageprofile
+
+import (
+	"fmt"
+	"sort"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/csi/enhancer"
+	"tkestack.io/csi-operator/pkg/controller/util"
+	"tkestack.io/csi-operator/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// syncStorageClasses creates, updates or deletes the StorageClasses of a StorageProfile.
+func (r *ReconcileStorageProfile) syncStorageClasses(
+	profile *csiv1.StorageProfile,
+	csiDeploy *csiv1.CSI) (bool, error) {
+	var (
+		updated bool
+		errs    types.ErrorList
+		names   []string
+	)
+
+	desired := make(map[string]bool, len(profile.Spec.StorageClasses))
+	for i := range profile.Spec.StorageClasses {
+		template := &profile.Spec.StorageClasses[i]
+		// Keep the existing StorageClass if the template can't be generated for now.
+		desired[template.Name] = true
+		sc, err := generateStorageClass(profile, csiDeploy, template)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		scUpdated, err := r.syncStorageClass(profile, sc)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if scUpdated {
+			updated = true
+		}
+		names = append(names, sc.Name)
+	}
+
+	// Delete the StorageClasses no longer declared by the profile.
+	storageClasses := &storagev1.StorageClassList{}
+	if err := r.listObjects(storageClasses, &client.ListOptions{}); err != nil {
+		errs = append(errs, fmt.Errorf("list StorageClasses failed: %s", err.Error()))
+	}
+	for i := range storageClasses.Items {
+		exist := &storageClasses.Items[i]
+		if desired[exist.Name] || !isControlledBy(exist, profile) {
+			continue
+		}
+		klog.Infof("Delete StorageClass %s of StorageProfile %s", exist.Name, profile.Name)
+		if err := r.deleteObject(exist); err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("delete StorageClass %s failed: %s", exist.Name, err.Error()))
+			continue
+		}
+		updated = true
+	}
+
+	sort.Strings(names)
+	profile.Status.StorageClasses = names
+
+	if len(errs) > 0 {
+		return updated, errs
+	}
+	return updated, nil
+}
+
+// generateStorageClass generates a StorageClass from a template and the CSI object.
+func generateStorageClass(
+	profile *csiv1.StorageProfile,
+	csiDeploy *csiv1.CSI,
+	template *csiv1.CSIStorageClassTemplate) (*storagev1.StorageClass, error) {
+	if findStorageClass(csiDeploy, template.Name) != nil {
+		return nil, fmt.Errorf("name %s is taken by a StorageClass of CSI %s/%s",
+			template.Name, csiDeploy.Namespace, csiDeploy.Name)
+	}
+
+	sc := &storagev1.StorageClass{}
+	if template.Base != "" {
+		base := findStorageClass(csiDeploy, template.Base)
+		if base == nil {
+			return nil, fmt.Errorf("base StorageClass %s not found in CSI %s/%s",
+				template.Base, csiDeploy.Namespace, csiDeploy.Name)
+		}
+		sc.Parameters = make(map[string]string, len(base.Parameters))
+		for k, v := range base.Parameters {
+			sc.Parameters[k] = v
+		}
+		sc.ReclaimPolicy = base.ReclaimPolicy
+		sc.MountOptions = base.MountOptions
+		sc.AllowVolumeExpansion = base.AllowVolumeExpansion
+		sc.VolumeBindingMode = base.VolumeBindingMode
+		sc.AllowedTopologies = base.AllowedTopologies
+		sc = sc.DeepCopy()
+	} else {
+		params, err := csiSecretParameters(csiDeploy)
+		if err != nil {
+			return nil, fmt.Errorf("base of StorageClass template %s must be set: %s", template.Name, err.Error())
+		}
+		sc.Parameters = params
+	}
+
+	sc.Name = template.Name
+	sc.Provisioner = csiDeploy.Spec.DriverName
+	sc.OwnerReferences = []metav1.OwnerReference{ownerReference(profile)}
+	enhancer.MergeStorageClassTemplate(sc, template)
+	return sc, nil
+}
+
+// syncStorageClass creates or updates a single StorageClass of a StorageProfile.
+func (r *ReconcileStorageProfile) syncStorageClass(
+	profile *csiv1.StorageProfile,
+	sc *storagev1.StorageClass) (bool, error) {
+	exist := &storagev1.StorageClass{}
+	if err := r.getObject(k8stypes.NamespacedName{Name: sc.Name}, exist); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, fmt.Errorf("get StorageClass %s failed: %s", sc.Name, err.Error())
+		}
+		klog.Infof("Create StorageClass %s for StorageProfile %s", sc.Name, profile.Name)
+		return true, r.createObject(sc)
+	}
+
+	if !isControlledBy(exist, profile) {
+		r.recorder.Eventf(profile, corev1.EventTypeWarning, types.StorageClassConflict,
+			"StorageClass %s already exists and is not managed by this StorageProfile", sc.Name)
+		return false, fmt.Errorf("StorageClass %s is not managed by StorageProfile %s", sc.Name, profile.Name)
+	}
+
+	updateObj, recreate := util.DiffStorageClass(exist, sc)
+	if !recreate {
+		if updateObj == nil {
+			return false, nil
+		}
+		klog.Infof("Update StorageClass %s for StorageProfile %s", sc.Name, profile.Name)
+		return true, r.updateObject(updateObj)
+	}
+
+	// Immutable fields changed, the StorageClass can only be recreated.
+	if profile.Annotations[types.RecreateStorageClassKey] != "true" {
+		r.recorder.Eventf(profile, corev1.EventTypeWarning, types.StorageClassChangeBlocked,
+			"Immutable fields of StorageClass %s are changed, set annotation %s to true to recreate it",
+			sc.Name, types.RecreateStorageClassKey)
+		return false, nil
+	}
+	r.recorder.Eventf(profile, corev1.EventTypeNormal, types.StorageClassRecreated,
+		"Immutable fields of StorageClass %s are changed, recreate it", sc.Name)
+	if err := r.deleteObject(exist); err != nil {
+		return false, fmt.Errorf("delete old StorageClass %s of StorageProfile %s failed: %v",
+			sc.Name, profile.Name, err)
+	}
+	klog.Infof("Recreate StorageClass %s for StorageProfile %s", sc.Name, profile.Name)
+	return true, r.createObject(sc)
+}
+
+// findStorageClass returns a StorageClass declared by the CSI object.
+func findStorageClass(csiDeploy *csiv1.CSI, name string) *storagev1.StorageClass {
+	for i := range csiDeploy.Spec.StorageClasses {
+		if csiDeploy.Spec.StorageClasses[i].Name == name {
+			return &csiDeploy.Spec.StorageClasses[i]
+		}
+	}
+	return nil
+}
+
+// csiSecretParameters returns the secret parameters shared by the StorageClasses of the CSI object.
+func csiSecretParameters(csiDeploy *csiv1.CSI) (map[string]string, error) {
+	keys := enhancer.SecretParameterKeys(csiDeploy)
+	var params map[string]string
+	for i := range csiDeploy.Spec.StorageClasses {
+		sc := &csiDeploy.Spec.StorageClasses[i]
+		scParams := make(map[string]string)
+		for _, key := range keys {
+			if value, exist := sc.Parameters[key]; exist {
+				scParams[key] = value
+			}
+		}
+		if params == nil {
+			params = scParams
+		} else if !equality.Semantic.DeepEqual(params, scParams) {
+			return nil, fmt.Errorf("the StorageClasses of CSI %s/%s use different secrets",
+				csiDeploy.Namespace, csiDeploy.Name)
+		}
+	}
+	if params == nil {
+		params = make(map[string]string)
+	}
+	return params, nil
+}
@@ -0,0 +1,214 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package storageprofile
+
+import (
+	"fmt"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/config"
+	"tkestack.io/csi-operator/pkg/controller/util"
+	"tkestack.io/csi-operator/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Add creates a new StorageProfile Controller and adds it to the Manager.
+// The Manager will set fields on the Controller and Start it when the Manager is Started.
+func Add(mgr manager.Manager, cfg *config.Config) error {
+	return add(mgr, newReconciler(mgr, cfg))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager, cfg *config.Config) reconcile.Reconciler {
+	return &ReconcileStorageProfile{
+		client:   mgr.GetClient(),
+		config:   cfg,
+		recorder: mgr.GetEventRecorderFor("csi-operator"),
+		mapper:   mgr.GetRESTMapper(),
+	}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New("storageprofile-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to StorageProfile
+	err = c.Watch(&source.Kind{Type: &csiv1.StorageProfile{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Watch for StorageClasses created by StorageProfiles.
+	err = c.Watch(&source.Kind{Type: &storagev1.StorageClass{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &csiv1.StorageProfile{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for CSI objects which provide the driver and parameters of StorageProfiles.
+	err = c.Watch(&source.Kind{Type: &csiv1.CSI{}}, newCSIHandler(mgr.GetClient()))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// newCSIHandler enqueues Requests for all StorageProfiles referring to a CSI object.
+func newCSIHandler(c client.Client) handler.EventHandler {
+	mapper := func(object handler.MapObject) []reconcile.Request {
+		ctx, cancel := util.GetContext()
+		defer cancel()
+		profiles := &csiv1.StorageProfileList{}
+		if err := c.List(ctx, profiles); err != nil {
+			klog.Errorf("List StorageProfiles failed: %v", err)
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, profile := range profiles.Items {
+			if profile.Spec.CSI.Namespace == object.Meta.GetNamespace() &&
+				profile.Spec.CSI.Name == object.Meta.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: k8stypes.NamespacedName{Name: profile.Name},
+				})
+			}
+		}
+		return requests
+	}
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(mapper),
+	}
+}
+
+var _ reconcile.Reconciler = &ReconcileStorageProfile{}
+
+// ReconcileStorageProfile reconciles a StorageProfile object
+type ReconcileStorageProfile struct {
+	client client.Client
+
+	config   *config.Config
+	recorder record.EventRecorder
+	mapper   meta.RESTMapper
+}
+
+// Reconcile reads that state of the cluster for a StorageProfile object and makes changes based on the state read
+// and what is in the StorageProfile.Spec
+func (r *ReconcileStorageProfile) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	// Fetch the StorageProfile instance
+	profile := &csiv1.StorageProfile{}
+	if err := r.getObject(request.NamespacedName, profile); err != nil {
+		if errors.IsNotFound(err) {
+			// Object not found, return. Created objects are automatically garbage collected.
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object, record the event and requeue the request.
+		r.recorder.Event(profile, corev1.EventTypeWarning, types.FetchError, err.Error())
+		return reconcile.Result{}, err
+	}
+	if profile.DeletionTimestamp != nil {
+		// Children are deleted by the garbage collector.
+		return reconcile.Result{}, nil
+	}
+	klog.V(4).Infof("Start to handle StorageProfile %s", profile.Name)
+
+	return reconcile.Result{}, r.handle(profile)
+}
+
+// handle processes a StorageProfile object.
+func (r *ReconcileStorageProfile) handle(profile *csiv1.StorageProfile) error {
+	newProfile := profile.DeepCopy()
+
+	var syncErr error
+	if validateErr := validateProfile(newProfile); len(validateErr) != 0 {
+		// Not a valid StorageProfile, update the Status.Conditions to reflect this.
+		syncErr = validateErr.ToAggregate()
+		updateCondition(newProfile, types.Validated, syncErr.Error(), corev1.ConditionFalse)
+	} else {
+		updateCondition(newProfile, types.Validated, "", corev1.ConditionTrue)
+		syncErr = r.syncProfile(newProfile)
+	}
+	if syncErr != nil {
+		r.recorder.Event(profile, corev1.EventTypeWarning, types.SyncError, syncErr.Error())
+		updateCondition(newProfile, types.Synced, syncErr.Error(), corev1.ConditionFalse)
+	} else {
+		updateCondition(newProfile, types.Synced, "", corev1.ConditionTrue)
+	}
+	newProfile.Status.ObservedGeneration = newProfile.Generation
+
+	if err := r.updateProfileStatus(profile, newProfile); err != nil {
+		klog.Errorf("Update status of StorageProfile %s failed: %v", profile.Name, err)
+		return err
+	}
+	return syncErr
+}
+
+// syncProfile creates or updates the StorageClasses and VolumeSnapshotClasses of a StorageProfile.
+func (r *ReconcileStorageProfile) syncProfile(profile *csiv1.StorageProfile) error {
+	csiDeploy := &csiv1.CSI{}
+	key := k8stypes.NamespacedName{Namespace: profile.Spec.CSI.Namespace, Name: profile.Spec.CSI.Name}
+	if err := r.getObject(key, csiDeploy); err != nil {
+		if errors.IsNotFound(err) {
+			// The profile will be enqueued again once the CSI object is created.
+			return fmt.Errorf("CSI %s not found", key.String())
+		}
+		return fmt.Errorf("get CSI %s failed: %s", key.String(), err.Error())
+	}
+
+	var errs types.ErrorList
+
+	updated, err := r.syncStorageClasses(profile, csiDeploy)
+	if err != nil {
+		errs = append(errs, err)
+	} else if updated {
+		r.recorder.Event(profile, corev1.EventTypeNormal, types.StorageClassesSynced,
+			"StorageClasses have been synced")
+	}
+
+	updated, err = r.syncVolumeSnapshotClasses(profile, csiDeploy)
+	if err != nil {
+		errs = append(errs, err)
+	} else if updated {
+		r.recorder.Event(profile, corev1.EventTypeNormal, types.VolumeSnapshotClassesSynced,
+			"VolumeSnapshotClasses have been synced")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
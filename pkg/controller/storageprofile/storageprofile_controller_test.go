@@ -0,0 +1,296 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package storageprofile
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/util"
+	"tkestack.io/csi-operator/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatalf("add k8s objects to scheme failed: %v", err)
+	}
+	if err := csiv1.AddToScheme(s); err != nil {
+		t.Fatalf("add CSI objects to scheme failed: %v", err)
+	}
+	return s
+}
+
+func newProfile(templates ...csiv1.CSIStorageClassTemplate) *csiv1.StorageProfile {
+	return &csiv1.StorageProfile{
+		ObjectMeta: metav1.ObjectMeta{Name: "rbd-profile", UID: "profile-uid"},
+		Spec: csiv1.StorageProfileSpec{
+			CSI:            csiv1.CSIReference{Namespace: "kube-system", Name: "rbd"},
+			StorageClasses: templates,
+		},
+	}
+}
+
+func TestReconcileStorageProfile(t *testing.T) {
+	csiDeploy := &csiv1.CSI{
+		ObjectMeta: metav1.ObjectMeta{Name: "rbd", Namespace: "kube-system"},
+		Spec: csiv1.CSISpec{
+			DriverName: csiv1.CSIDriverCephRBD,
+			StorageClasses: []storagev1.StorageClass{{
+				ObjectMeta: metav1.ObjectMeta{Name: "rbd"},
+				Parameters: map[string]string{
+					"pool": "rbd",
+					"csi.storage.k8s.io/provisioner-secret-name":      "rbd-secret",
+					"csi.storage.k8s.io/provisioner-secret-namespace": "kube-system",
+				},
+			}},
+		},
+	}
+	owned := func(name string) *storagev1.StorageClass {
+		profile := newProfile()
+		return &storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				OwnerReferences: []metav1.OwnerReference{ownerReference(profile)},
+			},
+			Provisioner: csiv1.CSIDriverCephRBD,
+		}
+	}
+
+	testCases := []struct {
+		name             string
+		profile          *csiv1.StorageProfile
+		objects          []runtime.Object
+		expectErr        bool
+		expectCondition  string
+		expectStatus     []string
+		expectParameters map[string]map[string]string
+		expectDeleted    []string
+	}{
+		{
+			name: "create from base",
+			profile: newProfile(csiv1.CSIStorageClassTemplate{
+				Name:       "rbd-ssd",
+				Base:       "rbd",
+				Parameters: map[string]string{"pool": "ssd"},
+			}),
+			objects:         []runtime.Object{csiDeploy},
+			expectCondition: types.Synced,
+			expectStatus:    []string{"rbd-ssd"},
+			expectParameters: map[string]map[string]string{
+				"rbd-ssd": {
+					"pool": "ssd",
+					"csi.storage.k8s.io/provisioner-secret-name":      "rbd-secret",
+					"csi.storage.k8s.io/provisioner-secret-namespace": "kube-system",
+				},
+			},
+		},
+		{
+			name: "create with the secrets of the driver",
+			profile: newProfile(csiv1.CSIStorageClassTemplate{
+				Name:       "rbd-hdd",
+				Parameters: map[string]string{"pool": "hdd"},
+			}),
+			objects:         []runtime.Object{csiDeploy},
+			expectCondition: types.Synced,
+			expectStatus:    []string{"rbd-hdd"},
+			expectParameters: map[string]map[string]string{
+				"rbd-hdd": {
+					"pool": "hdd",
+					"csi.storage.k8s.io/provisioner-secret-name":      "rbd-secret",
+					"csi.storage.k8s.io/provisioner-secret-namespace": "kube-system",
+				},
+			},
+		},
+		{
+			name:            "delete StorageClasses no longer declared",
+			profile:         newProfile(csiv1.CSIStorageClassTemplate{Name: "rbd-ssd", Base: "rbd"}),
+			objects:         []runtime.Object{csiDeploy, owned("rbd-ssd"), owned("rbd-old")},
+			expectCondition: types.Synced,
+			expectStatus:    []string{"rbd-ssd"},
+			expectDeleted:   []string{"rbd-old"},
+		},
+		{
+			name:    "keep StorageClasses of others",
+			profile: newProfile(csiv1.CSIStorageClassTemplate{Name: "rbd-ssd", Base: "rbd"}),
+			objects: []runtime.Object{
+				csiDeploy,
+				&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "rbd-ssd"}, Provisioner: "other"},
+			},
+			expectErr:        true,
+			expectCondition:  types.Synced,
+			expectParameters: map[string]map[string]string{"rbd-ssd": nil},
+		},
+		{
+			name:            "name taken by the CSI",
+			profile:         newProfile(csiv1.CSIStorageClassTemplate{Name: "rbd", Base: "rbd"}),
+			objects:         []runtime.Object{csiDeploy},
+			expectErr:       true,
+			expectCondition: types.Synced,
+		},
+		{
+			name:            "name not set",
+			profile:         newProfile(csiv1.CSIStorageClassTemplate{Base: "rbd"}),
+			objects:         []runtime.Object{csiDeploy},
+			expectErr:       true,
+			expectCondition: types.Validated,
+		},
+		{
+			name:            "CSI not found",
+			profile:         newProfile(csiv1.CSIStorageClassTemplate{Name: "rbd-ssd", Base: "rbd"}),
+			expectErr:       true,
+			expectCondition: types.Synced,
+		},
+	}
+
+	for i, tc := range testCases {
+		objects := append([]runtime.Object{tc.profile}, tc.objects...)
+		r := &ReconcileStorageProfile{
+			client:   fake.NewFakeClientWithScheme(newTestScheme(t), objects...),
+			recorder: record.NewFakeRecorder(10),
+		}
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: k8stypes.NamespacedName{Name: tc.profile.Name}})
+		if (err != nil) != tc.expectErr {
+			t.Errorf("case %d(%s): expect error %t, got %v", i, tc.name, tc.expectErr, err)
+		}
+
+		profile := &csiv1.StorageProfile{}
+		if err := r.getObject(k8stypes.NamespacedName{Name: tc.profile.Name}, profile); err != nil {
+			t.Errorf("case %d(%s): get StorageProfile failed: %v", i, tc.name, err)
+			continue
+		}
+		expectStatus := corev1.ConditionTrue
+		if tc.expectErr {
+			expectStatus = corev1.ConditionFalse
+		}
+		if condition := util.FindCondition(profile.Status.Conditions, tc.expectCondition); condition == nil ||
+			condition.Status != expectStatus {
+			t.Errorf("case %d(%s): expect condition %s %s, got %+v",
+				i, tc.name, tc.expectCondition, expectStatus, profile.Status.Conditions)
+		}
+		if !reflect.DeepEqual(profile.Status.StorageClasses, tc.expectStatus) {
+			t.Errorf("case %d(%s): expect StorageClasses %v, got %v",
+				i, tc.name, tc.expectStatus, profile.Status.StorageClasses)
+		}
+
+		for name, expected := range tc.expectParameters {
+			sc := &storagev1.StorageClass{}
+			if err := r.getObject(k8stypes.NamespacedName{Name: name}, sc); err != nil {
+				t.Errorf("case %d(%s): get StorageClass %s failed: %v", i, tc.name, name, err)
+				continue
+			}
+			if !reflect.DeepEqual(sc.Parameters, expected) {
+				t.Errorf("case %d(%s): expect parameters of %s %v, got %v", i, tc.name, name, expected, sc.Parameters)
+			}
+		}
+
+		list := &storagev1.StorageClassList{}
+		if err := r.listObjects(list, &client.ListOptions{}); err != nil {
+			t.Errorf("case %d(%s): list StorageClasses failed: %v", i, tc.name, err)
+			continue
+		}
+		var names []string
+		for _, sc := range list.Items {
+			names = append(names, sc.Name)
+		}
+		sort.Strings(names)
+		for _, name := range tc.expectDeleted {
+			if index := sort.SearchStrings(names, name); index < len(names) && names[index] == name {
+				t.Errorf("case %d(%s): expect StorageClass %s deleted", i, tc.name, name)
+			}
+		}
+	}
+}
+
+func TestReconcileDeletingStorageProfile(t *testing.T) {
+	now := metav1.Now()
+	profile := newProfile(csiv1.CSIStorageClassTemplate{Name: "rbd-ssd", Base: "rbd"})
+	profile.DeletionTimestamp = &now
+	r := &ReconcileStorageProfile{
+		client:   fake.NewFakeClientWithScheme(newTestScheme(t), profile),
+		recorder: record.NewFakeRecorder(10),
+	}
+
+	// The CSI object is not found, but a deleting profile is left to the garbage collector.
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: k8stypes.NamespacedName{Name: profile.Name}}); err != nil {
+		t.Errorf("reconcile deleting StorageProfile failed: %v", err)
+	}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: k8stypes.NamespacedName{Name: "deleted"}}); err != nil {
+		t.Errorf("reconcile deleted StorageProfile failed: %v", err)
+	}
+}
+
+func TestValidateProfile(t *testing.T) {
+	testCases := []struct {
+		name      string
+		profile   *csiv1.StorageProfile
+		expectErr bool
+	}{
+		{
+			name:    "valid",
+			profile: newProfile(csiv1.CSIStorageClassTemplate{Name: "a"}, csiv1.CSIStorageClassTemplate{Name: "b", Base: "rbd"}),
+		},
+		{
+			name:      "name not set",
+			profile:   newProfile(csiv1.CSIStorageClassTemplate{Base: "rbd"}),
+			expectErr: true,
+		},
+		{
+			name:      "duplicate names",
+			profile:   newProfile(csiv1.CSIStorageClassTemplate{Name: "a"}, csiv1.CSIStorageClassTemplate{Name: "a"}),
+			expectErr: true,
+		},
+		{
+			name: "snapshot class name not set",
+			profile: func() *csiv1.StorageProfile {
+				profile := newProfile()
+				profile.Spec.VolumeSnapshotClasses = []csiv1.VolumeSnapshotClassTemplate{{}}
+				return profile
+			}(),
+			expectErr: true,
+		},
+		{
+			name: "CSI not set",
+			profile: func() *csiv1.StorageProfile {
+				profile := newProfile()
+				profile.Spec.CSI = csiv1.CSIReference{}
+				return profile
+			}(),
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		if errs := validateProfile(tc.profile); (len(errs) > 0) != tc.expectErr {
+			t.Errorf("case %d(%s): expect error %t, got %v", i, tc.name, tc.expectErr, errs)
+		}
+	}
+}
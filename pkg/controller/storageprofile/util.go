@@ -0,0 +1,81 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package storageprofile
+
+import (
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+	"tkestack.io/csi-operator/pkg/controller/util"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getObject returns a specific object from k8s.
+func (r *ReconcileStorageProfile) getObject(key k8stypes.NamespacedName, object runtime.Object) error {
+	return util.GetObject(r.client, key, object)
+}
+
+// createObject creates an object to k8s.
+func (r *ReconcileStorageProfile) createObject(object runtime.Object) error {
+	return util.CreateObject(r.client, object)
+}
+
+// updateObject updates an object to k8s.
+func (r *ReconcileStorageProfile) updateObject(object runtime.Object) error {
+	return util.UpdateObject(r.client, object)
+}
+
+// listObjects list objects from k8s.
+func (r *ReconcileStorageProfile) listObjects(list runtime.Object, opts *client.ListOptions) error {
+	return util.ListObjects(r.client, list, opts)
+}
+
+// deleteObject deletes an object in k8s.
+func (r *ReconcileStorageProfile) deleteObject(obj runtime.Object) error {
+	return util.DeleteObject(r.client, obj)
+}
+
+// updateProfileStatus updates StorageProfile's status.
+func (r *ReconcileStorageProfile) updateProfileStatus(oldProfile, newProfile *csiv1.StorageProfile) error {
+	if equality.Semantic.DeepEqual(oldProfile.Status, newProfile.Status) {
+		return nil
+	}
+	return util.UpdateStatus(r.client, newProfile)
+}
+
+// ownerReference generates an OwnerReference pointing to a StorageProfile.
+func ownerReference(profile *csiv1.StorageProfile) metav1.OwnerReference {
+	return *metav1.NewControllerRef(profile, csiv1.SchemeGroupVersion.WithKind("StorageProfile"))
+}
+
+// isControlledBy returns true if obj is controlled by the StorageProfile.
+func isControlledBy(obj metav1.Object, profile *csiv1.StorageProfile) bool {
+	ref := metav1.GetControllerOf(obj)
+	return ref != nil && ref.UID == profile.UID
+}
+
+// updateCondition updates a StorageProfile object's condition.
+func updateCondition(
+	profile *csiv1.StorageProfile,
+	typ, message string, status corev1.ConditionStatus) {
+	util.UpdateCondition(&profile.Status.Conditions, typ, message, status)
+}
@@ -0,0 +1,70 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package storageprofile
+
+import (
+	csiv1 "tkestack.io/csi-operator/pkg/apis/storage/v1"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validateProfile checks whether a StorageProfile is valid.
+func validateProfile(profile *csiv1.StorageProfile) field.ErrorList {
+	var errs field.ErrorList
+	fieldPath := field.NewPath("spec")
+
+	if profile.Spec.CSI.Namespace == "" {
+		errs = append(errs, field.Required(fieldPath.Child("csi", "namespace"), "namespace of CSI must be set"))
+	}
+	if profile.Spec.CSI.Name == "" {
+		errs = append(errs, field.Required(fieldPath.Child("csi", "name"), "name of CSI must be set"))
+	}
+
+	scNames := make([]string, 0, len(profile.Spec.StorageClasses))
+	for i := range profile.Spec.StorageClasses {
+		scNames = append(scNames, profile.Spec.StorageClasses[i].Name)
+	}
+	errs = append(errs, validateNames(scNames, fieldPath.Child("storageClasses"))...)
+
+	snapshotClassNames := make([]string, 0, len(profile.Spec.VolumeSnapshotClasses))
+	for i := range profile.Spec.VolumeSnapshotClasses {
+		snapshotClassNames = append(snapshotClassNames, profile.Spec.VolumeSnapshotClasses[i].Name)
+	}
+	errs = append(errs, validateNames(snapshotClassNames, fieldPath.Child("volumeSnapshotClasses"))...)
+
+	return errs
+}
+
+// validateNames checks whether the names of the objects of a StorageProfile are set and unique.
+func validateNames(names []string, fieldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	set := make(map[string]bool, len(names))
+	for i, name := range names {
+		if name == "" {
+			errs = append(errs, field.Required(fieldPath.Index(i).Child("name"), "name must be set"))
+			continue
+		}
+		if set[name] {
+			errs = append(errs, field.Duplicate(fieldPath.Index(i).Child("name"), name))
+		}
+		set[name] = true
+	}
+
+	return errs
+}
@@ -0,0 +1,79 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// TODO: Make this configurable.
+	apiTimeout = time.Minute
+)
+
+// GetObject returns a specific object from k8s.
+func GetObject(c client.Client, key k8stypes.NamespacedName, object runtime.Object) error {
+	ctx, cancel := GetContext()
+	defer cancel()
+	return c.Get(ctx, key, object)
+}
+
+// CreateObject creates an object to k8s.
+func CreateObject(c client.Client, object runtime.Object) error {
+	ctx, cancel := GetContext()
+	defer cancel()
+	return c.Create(ctx, object)
+}
+
+// UpdateObject updates an object to k8s.
+func UpdateObject(c client.Client, object runtime.Object) error {
+	ctx, cancel := GetContext()
+	defer cancel()
+	return c.Update(ctx, object)
+}
+
+// ListObjects list objects from k8s.
+func ListObjects(c client.Client, list runtime.Object, opts *client.ListOptions) error {
+	ctx, cancel := GetContext()
+	defer cancel()
+	return c.List(ctx, list, opts)
+}
+
+// DeleteObject deletes an object in k8s.
+func DeleteObject(c client.Client, object runtime.Object) error {
+	ctx, cancel := GetContext()
+	defer cancel()
+	return c.Delete(ctx, object)
+}
+
+// UpdateStatus updates the status of an object to k8s.
+func UpdateStatus(c client.Client, object runtime.Object) error {
+	ctx, cancel := GetContext()
+	defer cancel()
+	return c.Status().Update(ctx, object)
+}
+
+// GetContext creates a Context with a specified timeout.
+func GetContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), apiTimeout)
+}
@@ -0,0 +1,65 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MergeObjectMeta merges src into dst and returns true if dst is changed.
+func MergeObjectMeta(src, dst *metav1.ObjectMeta) bool {
+	changed := false
+
+	if !equality.Semantic.DeepEqual(src.OwnerReferences, dst.OwnerReferences) {
+		changed = true
+		dst.OwnerReferences = src.OwnerReferences
+	}
+
+	// We can't just copy labels as it maybe remove system added labels.
+	if dst.Labels == nil {
+		if src.Labels != nil {
+			changed = true
+			dst.Labels = src.Labels
+		}
+	} else {
+		for k, v := range src.Labels {
+			if dst.Labels[k] != v {
+				changed = true
+				dst.Labels[k] = v
+			}
+		}
+	}
+
+	// We can't just copy annotations as it maybe remove system added annotations.
+	if dst.Annotations == nil {
+		if src.Annotations != nil {
+			changed = true
+			dst.Annotations = src.Annotations
+		}
+	} else {
+		for k, v := range src.Annotations {
+			if dst.Annotations[k] != v {
+				changed = true
+				dst.Annotations[k] = v
+			}
+		}
+	}
+
+	return changed
+}
@@ -0,0 +1,69 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package util
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// DiffStorageClass compares an existing StorageClass with the desired one. If only mutable
+// fields differ, it returns the object to update in place, or nil if nothing changes.
+// If immutable fields differ, the StorageClass can only be recreated and recreate is true.
+func DiffStorageClass(exist, sc *storagev1.StorageClass) (update *storagev1.StorageClass, recreate bool) {
+	desired := sc.DeepCopy()
+	filterSCDefaultFields(desired, exist)
+	if !equality.Semantic.DeepEqual(immutableSCFields(desired), immutableSCFields(exist)) {
+		return nil, true
+	}
+
+	updateObj := exist.DeepCopy()
+	updated := MergeObjectMeta(&sc.ObjectMeta, &updateObj.ObjectMeta)
+	if !equality.Semantic.DeepEqual(desired.AllowVolumeExpansion, exist.AllowVolumeExpansion) ||
+		!equality.Semantic.DeepEqual(desired.MountOptions, exist.MountOptions) ||
+		!equality.Semantic.DeepEqual(desired.AllowedTopologies, exist.AllowedTopologies) {
+		updated = true
+		updateObj.AllowVolumeExpansion = desired.AllowVolumeExpansion
+		updateObj.MountOptions = desired.MountOptions
+		updateObj.AllowedTopologies = desired.AllowedTopologies
+	}
+	if !updated {
+		return nil, false
+	}
+	return updateObj, false
+}
+
+// immutableSCFields returns the fields of a StorageClass which can't be updated.
+func immutableSCFields(sc *storagev1.StorageClass) []interface{} {
+	return []interface{}{sc.Provisioner, sc.Parameters, sc.ReclaimPolicy, sc.VolumeBindingMode}
+}
+
+// Clear unconcerned fields when comparing two StorageClass object.
+func filterSCDefaultFields(sc, ref *storagev1.StorageClass) {
+	if sc.ReclaimPolicy == nil &&
+		ref.ReclaimPolicy != nil &&
+		*ref.ReclaimPolicy == corev1.PersistentVolumeReclaimDelete {
+		sc.ReclaimPolicy = ref.ReclaimPolicy
+	}
+	if sc.VolumeBindingMode == nil &&
+		ref.VolumeBindingMode != nil &&
+		*ref.VolumeBindingMode == storagev1.VolumeBindingImmediate {
+		sc.VolumeBindingMode = ref.VolumeBindingMode
+	}
+}
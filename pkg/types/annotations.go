@@ -19,3 +19,21 @@ package types
 
 // LivenessProbePortKey is the annotation key to set liveness probe port in CSI object.
 const LivenessProbePortKey = "storage.tkestack.io/liveness-probe-port"
+
+// ExtraCreateMetadataKey is the parameter key of the provisioner to pass the names of the PVC and PV
+// to the driver, which are used by the drivers such as NFS to name the volumes.
+const ExtraCreateMetadataKey = "storage.tkestack.io/extra-create-metadata"
+
+// StorageCapacityKey is the parameter key of the provisioner to publish the capacity reported by the driver
+// in CSIStorageCapacity objects, which are used by the scheduler to place pods on nodes with enough capacity.
+const StorageCapacityKey = "storage.tkestack.io/storage-capacity"
+
+// ForceDeleteKey is the annotation key to delete a CSI object even if volumes of the driver still exist.
+const ForceDeleteKey = "storage.tkestack.io/force-delete"
+
+// DeletionPolicyKey is the annotation key to override the deletion policy of a StorageClass or Secret.
+const DeletionPolicyKey = "storage.tkestack.io/deletion-policy"
+
+// RecreateStorageClassKey is the annotation key to allow recreating StorageClasses whose immutable
+// fields are changed. It can be set on a CSI object or a StorageClass in its spec.
+const RecreateStorageClassKey = "storage.tkestack.io/recreate-storageclass"
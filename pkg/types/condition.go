@@ -26,4 +26,10 @@ const (
 	NodeAvailable = "NodeAvailable"
 	// ControllerAvailable means node drivers are running normally.
 	ControllerAvailable = "ControllerAvailable"
+	// NodeRegistered means kubelet has registered the driver on all nodes running the node driver.
+	NodeRegistered = "NodeRegistered"
+	// VolumesReleased means no volumes of the driver are in use, so the CSI object can be deleted.
+	VolumesReleased = "VolumesReleased"
+	// DefaultStorageClass means the StorageClass named by the CSI object is the only default StorageClass.
+	DefaultStorageClass = "DefaultStorageClass"
 )
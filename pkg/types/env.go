@@ -0,0 +1,27 @@
+/*
+ * Tencent is pleased to support the open source community by making TKEStack available.
+ *
+ * Copyright (C) 2012-2019 Tencent. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use
+ * this file except in compliance with the License. You may obtain a copy of the
+ * License at
+ *
+ * https://opensource.org/licenses/Apache-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OF ANY KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package types
+
+const (
+	// MetricsAddressEnv is the env of the driver container holding the address of its metrics endpoint,
+	// in the format of :<port>. It is only set if the metrics of the CSI object are enabled.
+	MetricsAddressEnv = "METRICS_ADDRESS"
+	// MetricsPortEnv is the env of the driver container holding the port of its metrics endpoint.
+	// It is only set if the metrics of the CSI object are enabled.
+	MetricsPortEnv = "METRICS_PORT"
+)
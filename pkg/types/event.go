@@ -34,4 +34,22 @@ const (
 	NodeDriverSynced = "NodeDriverSynced"
 	// ControllerDriverSynced means the controller driver daemonSet has been synced.
 	ControllerDriverSynced = "ControllerDriverSynced"
+	// MetricsServicesSynced means the metrics Services and monitors have been synced.
+	MetricsServicesSynced = "MetricsServicesSynced"
+	// ContainerFailed means a container of the node driver or controller driver is failing.
+	ContainerFailed = "ContainerFailed"
+	// DeletionBlocked means the CSI object can't be deleted as volumes of the driver still exist.
+	DeletionBlocked = "DeletionBlocked"
+	// ObjectAdopted means an object installed without the operator has been adopted.
+	ObjectAdopted = "ObjectAdopted"
+	// AdoptionRefused means an object can't be adopted as it is owned by another CSI object.
+	AdoptionRefused = "AdoptionRefused"
+	// StorageClassChangeBlocked means immutable fields of a StorageClass are changed, but recreating it is not allowed.
+	StorageClassChangeBlocked = "StorageClassChangeBlocked"
+	// StorageClassRecreated means a StorageClass is recreated as its immutable fields are changed.
+	StorageClassRecreated = "StorageClassRecreated"
+	// VolumeSnapshotClassesSynced means the volumeSnapshotClasses have been synced.
+	VolumeSnapshotClassesSynced = "VolumeSnapshotClassesSynced"
+	// StorageClassConflict means a StorageClass with the same name is not managed by the StorageProfile.
+	StorageClassConflict = "StorageClassConflict"
 )